@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/internal/enumflag"
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+)
+
+// action runs a parsed subcommand on a session.
+type action func(ctx context.Context, s *pkg.Session, out io.Writer) error
+
+// subcommand is a subcommand of enocean-ctl.
+type subcommand struct {
+	name  string
+	args  string
+	help  string
+	parse func(args []string) (action, error)
+}
+
+// subcommands lists the subcommands in the order of the usage.
+var subcommands = []subcommand{
+	{"version", "", "read the firmware and chip versions", noArgs(readVersion)},
+	{"idbase", "", "read the ID base", noArgs(readIDBase)},
+	{"dutycycle", "", "read the duty cycle budget", noArgs(readDutyCycle)},
+	{"secure-devices", "[inbound|outbound|broadcast]", "read a table of secure devices", parseSecureDevices},
+	{"repeater", "[off | on|selective [LEVEL]]", "read or write the repeater mode", parseRepeater},
+	{"learnmode", "[off | on [TIMEOUT [CHANNEL]]]", "read or write the learn mode", parseLearnMode},
+	{"filters", "", "read the receive filters", noArgs(readFilters)},
+	{"filter-add", "[-block] [-repeat] CRITERION VALUE", "add a receive filter", parseFilter(true)},
+	{"filter-del", "[-block] [-repeat] CRITERION VALUE", "delete a receive filter", parseFilter(false)},
+	{"filter-clear", "", "delete all receive filters", noArgs(clearFilters)},
+	{"filter-enable", "[or|and|OPERATOR]", "enable the receive filters", parseFilterEnable},
+	{"filter-disable", "", "disable the receive filters", noArgs(disableFilters)},
+	{"baudrate", "57600|115200|230400|460800", "set the baud rate of the serial line", parseBaudrate},
+	{"reset", "", "reset the transceiver", noArgs(reset)},
+	{"bist", "", "run the built-in self test", noArgs(bist)},
+	{"mem-read", "TYPE ADDRESS LENGTH", "hexdump transceiver memory", parseMemRead},
+	{"mem-write", "TYPE ADDRESS HEX", "write transceiver memory", parseMemWrite},
+}
+
+// findSubcommand returns the subcommand called name.
+func findSubcommand(name string) (subcommand, bool) {
+	for _, sub := range subcommands {
+		if sub.name == name {
+			return sub, true
+		}
+	}
+	return subcommand{}, false
+}
+
+// noArgs parses subcommands taking no arguments.
+func noArgs(a action) func([]string) (action, error) {
+	return func(args []string) (action, error) {
+		if len(args) > 0 {
+			return nil, errors.New("unexpected arguments")
+		}
+		return a, nil
+	}
+}
+
+// exec runs cmd and prints its parsed RESPONSE.
+func exec[R any](ctx context.Context, s *pkg.Session, out io.Writer, cmd commoncommand.Command[R]) error {
+	result, err := pkg.Exec(ctx, s, cmd)
+	if err != nil {
+		return err
+	}
+	printResponse(out, result)
+	return nil
+}
+
+func readVersion(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewRdVersion()
+	return exec(ctx, s, out, &cmd)
+}
+
+func readIDBase(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewRdIDBase()
+	return exec(ctx, s, out, &cmd)
+}
+
+func readDutyCycle(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewRdDutyCycleLimit()
+	return exec(ctx, s, out, &cmd)
+}
+
+func readFilters(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewRdFilter()
+	return exec(ctx, s, out, &cmd)
+}
+
+func clearFilters(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewWrFilterDelAll()
+	return exec(ctx, s, out, &cmd)
+}
+
+func disableFilters(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewWrFilterEnable(false, enums.FilerOperatorOR_ALL_FILTERS)
+	return exec(ctx, s, out, &cmd)
+}
+
+func reset(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewWrReset()
+	return exec(ctx, s, out, &cmd)
+}
+
+func bist(ctx context.Context, s *pkg.Session, out io.Writer) error {
+	cmd, _ := commoncommand.NewBist()
+	return exec(ctx, s, out, &cmd)
+}
+
+// parseSecureDevices parses "secure-devices [TABLE]". The action reads the
+// number of devices of the table, then every device by index.
+func parseSecureDevices(args []string) (action, error) {
+	if len(args) > 1 {
+		return nil, errors.New("unexpected arguments")
+	}
+	direction := enums.SecureDeviceDirectionINBOUND_TABLE
+	if len(args) == 1 {
+		var ok bool
+		if direction, ok = directions[strings.ToLower(args[0])]; !ok {
+			return nil, fmt.Errorf("invalid table %q", args[0])
+		}
+	}
+
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		count, _ := commoncommand.NewRdNumSecureDevices(direction)
+		n, err := pkg.Exec(ctx, s, &count)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s: %d devices\n", direction, n.NumSecureDevices)
+		for i := range n.NumSecureDevices {
+			cmd, err := commoncommand.NewRdSecureDeviceByIndex(i, direction)
+			if err != nil {
+				return err
+			}
+			device, err := pkg.Exec(ctx, s, &cmd)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "\n[%d]\n", i)
+			printResponse(out, device)
+		}
+		return nil
+	}, nil
+}
+
+// directions maps the table names of secure-devices to their direction.
+var directions = map[string]enums.SecureDeviceDirection{
+	"inbound":   enums.SecureDeviceDirectionINBOUND_TABLE,
+	"outbound":  enums.SecureDeviceDirectionOUTBOUND_TABLE,
+	"broadcast": enums.SecureDeviceDirectionOUTBOUND_BROADCAST_TABLE,
+}
+
+// parseRepeater parses "repeater [MODE [LEVEL]]"; without arguments the
+// repeater settings are read. LEVEL defaults to 1 unless the mode is off.
+func parseRepeater(args []string) (action, error) {
+	if len(args) == 0 {
+		return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+			cmd, _ := commoncommand.NewRdRepeater()
+			return exec(ctx, s, out, &cmd)
+		}, nil
+	}
+	if len(args) > 2 {
+		return nil, errors.New("unexpected arguments")
+	}
+
+	mode, err := enumflag.Parse[enums.RepeaterMode](args[0])
+	if err != nil {
+		return nil, err
+	}
+	level := enums.RepeaterLevelNO_REPETITION
+	if mode != enums.RepeaterModeOFF {
+		level = enums.RepeaterLevel1_REPETITION
+	}
+	if len(args) == 2 {
+		n, err := strconv.ParseUint(args[1], 10, 8)
+		if err != nil || !enums.RepeaterLevel(n).Valid() {
+			return nil, fmt.Errorf("invalid level %q", args[1])
+		}
+		level = enums.RepeaterLevel(n)
+	}
+	cmd, err := commoncommand.NewWrRepeater(mode, level)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		return exec(ctx, s, out, &cmd)
+	}, nil
+}
+
+// parseLearnMode parses "learnmode [on|off [TIMEOUT [CHANNEL]]]"; without
+// arguments the learn mode is read. A zero TIMEOUT keeps the transceiver
+// default of 60 s.
+func parseLearnMode(args []string) (action, error) {
+	if len(args) == 0 {
+		return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+			cmd, _ := commoncommand.NewRdLearnMode()
+			return exec(ctx, s, out, &cmd)
+		}, nil
+	}
+	if len(args) > 3 {
+		return nil, errors.New("unexpected arguments")
+	}
+
+	var enable bool
+	switch strings.ToLower(args[0]) {
+	case "on":
+		enable = true
+	case "off":
+	default:
+		return nil, fmt.Errorf("invalid learn mode %q", args[0])
+	}
+	var timeout time.Duration
+	if len(args) > 1 {
+		var err error
+		if timeout, err = time.ParseDuration(args[1]); err != nil || timeout < 0 || timeout.Milliseconds() > 0xffffffff {
+			return nil, fmt.Errorf("invalid timeout %q", args[1])
+		}
+	}
+	var channel uint64
+	if len(args) > 2 {
+		var err error
+		if channel, err = strconv.ParseUint(args[2], 0, 8); err != nil {
+			return nil, fmt.Errorf("invalid channel %q", args[2])
+		}
+	}
+
+	cmd, err := commoncommand.NewWrLearnMode(enable, uint32(timeout.Milliseconds()), uint8(channel))
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		return exec(ctx, s, out, &cmd)
+	}, nil
+}
+
+// parseFilter parses "filter-add" or "filter-del". Filters forward what
+// they match unless -block is given.
+func parseFilter(add bool) func([]string) (action, error) {
+	return func(args []string) (action, error) {
+		fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		block := fs.Bool("block", false, "drop the matching telegrams")
+		repeat := fs.Bool("repeat", false, "apply the filter to repeating")
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() != 2 {
+			return nil, errors.New("want a criterion and a value")
+		}
+
+		criterion, err := enumflag.Parse[enums.FilterCriterion](fs.Arg(0))
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseFilterValue(criterion, fs.Arg(1))
+		if err != nil {
+			return nil, err
+		}
+
+		if add {
+			cmd, err := commoncommand.NewWrFilterAdd(criterion, value, !*block, *repeat)
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+				return exec(ctx, s, out, &cmd)
+			}, nil
+		}
+		cmd, err := commoncommand.NewWrFilterDel(criterion, value, !*block, *repeat)
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+			return exec(ctx, s, out, &cmd)
+		}, nil
+	}
+}
+
+// parseFilterValue parses the value of a filter: a device ID, a RORG or
+// an RSSI in dBm, e.g. -70.
+func parseFilterValue(criterion enums.FilterCriterion, s string) (uint32, error) {
+	switch criterion {
+	case enums.FilterCriterionSENDER_ID, enums.FilterCriterionDESTINATION_ID:
+		id, err := deviceid.FromHexString(s)
+		return uint32(id), err
+	case enums.FilterCriterionRORG:
+		rorg, err := enumflag.Parse[enums.Rorg](s)
+		return uint32(rorg), err
+	default:
+		dBm, err := strconv.Atoi(s)
+		if err != nil || dBm > 0 || dBm < -255 {
+			return 0, fmt.Errorf("invalid RSSI %q, want dBm", s)
+		}
+		return uint32(-dBm), nil
+	}
+}
+
+// parseFilterEnable parses "filter-enable [OPERATOR]"; "or" and "and"
+// combine all filters.
+func parseFilterEnable(args []string) (action, error) {
+	if len(args) > 1 {
+		return nil, errors.New("unexpected arguments")
+	}
+	operator := enums.FilerOperatorOR_ALL_FILTERS
+	if len(args) == 1 {
+		switch strings.ToLower(args[0]) {
+		case "or":
+		case "and":
+			operator = enums.FilerOperatorAND_ALL_FILTERS
+		default:
+			var err error
+			if operator, err = enumflag.Parse[enums.FilerOperator](args[0]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	cmd, err := commoncommand.NewWrFilterEnable(true, operator)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		return exec(ctx, s, out, &cmd)
+	}, nil
+}
+
+// parseBaudrate parses "baudrate RATE".
+func parseBaudrate(args []string) (action, error) {
+	if len(args) != 1 {
+		return nil, errors.New("want a baud rate")
+	}
+	rate, err := enumflag.Parse[enums.TCMBaudrate](args[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := commoncommand.NewSetBaudrate(rate)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		return exec(ctx, s, out, &cmd)
+	}, nil
+}
+
+// parseMemRead parses "mem-read TYPE ADDRESS LENGTH".
+func parseMemRead(args []string) (action, error) {
+	if len(args) != 3 {
+		return nil, errors.New("want a memory type, an address and a length")
+	}
+	memoryType, address, err := parseMemory(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.ParseUint(args[2], 0, 16)
+	if err != nil || length == 0 {
+		return nil, fmt.Errorf("invalid length %q", args[2])
+	}
+	cmd, err := commoncommand.NewRdMem(memoryType, address, uint16(length))
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		mem, err := pkg.Exec(ctx, s, &cmd)
+		if err != nil {
+			return err
+		}
+		hexdump(out, address, mem.Data)
+		return nil
+	}, nil
+}
+
+// parseMemWrite parses "mem-write TYPE ADDRESS HEX".
+func parseMemWrite(args []string) (action, error) {
+	if len(args) != 3 {
+		return nil, errors.New("want a memory type, an address and hex data")
+	}
+	memoryType, address, err := parseMemory(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.ReplaceAll(args[2], " ", ""))
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("invalid data %q", args[2])
+	}
+	cmd, err := commoncommand.NewWrMem(memoryType, address, data)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, s *pkg.Session, out io.Writer) error {
+		return exec(ctx, s, out, &cmd)
+	}, nil
+}
+
+// parseMemory parses the memory type and address of mem-read and
+// mem-write.
+func parseMemory(memoryType, address string) (enums.MemoryType, uint32, error) {
+	t, err := enumflag.Parse[enums.MemoryType](memoryType)
+	if err != nil {
+		return 0, 0, err
+	}
+	a, err := strconv.ParseUint(address, 0, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid address %q", address)
+	}
+	return t, uint32(a), nil
+}
+
+// printResponse prints the fields of a parsed RESPONSE, one per line.
+// Commands answering with a bare return code print OK.
+func printResponse(w io.Writer, result any) {
+	v := reflect.ValueOf(result)
+	if v.Kind() != reflect.Struct || v.NumField() == 0 {
+		fmt.Fprintln(w, "OK")
+		return
+	}
+	for i := range v.NumField() {
+		name := v.Type().Field(i).Name
+		fmt.Fprintf(w, "%-36s %s\n", name+":", formatField(name, v.Field(i)))
+	}
+}
+
+// formatField formats a response field: IDs and addresses in hex, byte
+// strings as hex, versions dotted and lists one element per line.
+func formatField(name string, v reflect.Value) string {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch v.Kind() {
+	case reflect.Uint32:
+		return fmt.Sprintf("0x%08x", v.Uint())
+	case reflect.Array, reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			if strings.HasSuffix(name, "Version") && len(b) == 4 {
+				return fmt.Sprintf("%d.%d.%d.%d", b[0], b[1], b[2], b[3])
+			}
+			return fmt.Sprintf("% x", b)
+		}
+		if v.Len() == 0 {
+			return "none"
+		}
+		var b strings.Builder
+		for i := range v.Len() {
+			b.WriteString("\n ")
+			elem := v.Index(i)
+			if elem.Kind() != reflect.Struct {
+				fmt.Fprintf(&b, " %s", formatField(name, elem))
+				continue
+			}
+			for j := range elem.NumField() {
+				field := elem.Type().Field(j).Name
+				fmt.Fprintf(&b, " %s=%s", field, formatField(field, elem.Field(j)))
+			}
+		}
+		return b.String()
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+// hexdump prints data as offset, hex and ASCII columns, the offsets
+// starting at address.
+func hexdump(w io.Writer, address uint32, data []byte) {
+	for off := 0; off < len(data); off += 16 {
+		line := data[off:min(off+16, len(data))]
+		ascii := make([]byte, len(line))
+		for i, c := range line {
+			ascii[i] = '.'
+			if c >= 0x20 && c < 0x7f {
+				ascii[i] = c
+			}
+		}
+		fmt.Fprintf(w, "%08x  %-47s  |%s|\n", address+uint32(off), fmt.Sprintf("% x", line), ascii)
+	}
+}
@@ -0,0 +1,94 @@
+// Command enocean-ctl reads and writes the settings of an EnOcean
+// transceiver with ESP3 common commands.
+//
+//	enocean-ctl -port /dev/ttyUSB0 version
+//	enocean-ctl -port /dev/ttyUSB0 repeater on 1
+//	enocean-ctl -port /dev/ttyUSB0 mem-read FLASH 0x1000 64
+//
+// Run it without a subcommand to list them.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+)
+
+// dial returns the dialer of the transceiver at path; tests replace it.
+var dial = pkg.SerialDialer
+
+// main runs the command.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, flag.CommandLine, os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run executes the command, writing the results to out.
+func run(ctx context.Context, fs *flag.FlagSet, args []string, out io.Writer) error {
+	port := fs.String("port", "", "serial port of the transceiver")
+	timeout := fs.Duration("timeout", 2*time.Second, "time to wait for the transceiver to answer")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		usage(out)
+		return errors.New("missing subcommand")
+	}
+	name, subArgs := fs.Arg(0), fs.Args()[1:]
+	sub, ok := findSubcommand(name)
+	if !ok {
+		usage(out)
+		return fmt.Errorf("unknown subcommand %q", name)
+	}
+	if *port == "" {
+		return errors.New("-port is required")
+	}
+
+	// Commands are validated before the port is opened, so typos do not
+	// touch the transceiver.
+	exec, err := sub.parse(subArgs)
+	if err != nil {
+		return fmt.Errorf("%s: %w (usage: %s %s)", sub.name, err, sub.name, sub.args)
+	}
+
+	s, err := pkg.DialSession(ctx, dial(*port))
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	return describeError(exec(ctx, s, out))
+}
+
+// describeError spells out the return code of a refused command.
+func describeError(err error) error {
+	var codeErr *commoncommand.ReturnCodeError
+	if errors.As(err, &codeErr) {
+		return fmt.Errorf("%s refused: return code %s (0x%02x)", codeErr.Command, codeErr.Code, byte(codeErr.Code))
+	}
+	return err
+}
+
+// usage lists the subcommands on w.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: enocean-ctl -port PATH [-timeout DURATION] SUBCOMMAND [ARGS]")
+	fmt.Fprintln(w)
+	for _, sub := range subcommands {
+		fmt.Fprintf(w, "  %-16s %-40s %s\n", sub.name, sub.args, sub.help)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/emulator"
+)
+
+// ctl runs the command against a fresh emulator and returns its output.
+func ctl(t *testing.T, args ...string) (*emulator.Emulator, string, error) {
+	t.Helper()
+	e := emulator.New()
+	t.Cleanup(func() { e.Close() })
+
+	saved := dial
+	dial = func(string) pkg.Dialer {
+		return func(context.Context) (pkg.Transport, error) { return e.Host(), nil }
+	}
+	t.Cleanup(func() { dial = saved })
+
+	var out bytes.Buffer
+	err := run(context.Background(), flag.NewFlagSet("enocean-ctl", flag.ContinueOnError), append([]string{"-port", "emulated"}, args...), &out)
+	return e, out.String(), err
+}
+
+// TestCtlReadsResponses verifies CtlReadsResponses behavior.
+func TestCtlReadsResponses(t *testing.T) {
+	_, out, err := ctl(t, "version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"AppVersion:", "2.11.1.0", "ChipID:", "0x01807ab3", "GATEWAYCTRL"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("version output lacks %q:\n%s", want, out)
+		}
+	}
+
+	if _, out, err = ctl(t, "idbase"); err != nil || !strings.Contains(out, "ff9fa580") {
+		t.Fatalf("idbase = %q, %v", out, err)
+	}
+	if _, out, err = ctl(t, "filters"); err != nil || !strings.Contains(out, "none") {
+		t.Fatalf("filters = %q, %v", out, err)
+	}
+
+	_, out, err = ctl(t, "mem-read", "ram0", "0x10", "20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "00000010  00 00") || !strings.HasPrefix(lines[1], "00000020  00 00 00 00  ") {
+		t.Fatalf("hexdump:\n%s", out)
+	}
+}
+
+// TestCtlWritesSettings verifies CtlWritesSettings behavior.
+func TestCtlWritesSettings(t *testing.T) {
+	e, out, err := ctl(t, "learnmode", "on", "10s")
+	if err != nil || out != "OK\n" || !e.Learning() {
+		t.Fatalf("learnmode = %q, %v", out, err)
+	}
+	for _, args := range [][]string{
+		{"repeater", "on", "2"},
+		{"filter-add", "-block", "sender_id", "01020304"},
+		{"filter-add", "rssi", "-70"},
+		{"filter-enable", "and"},
+		{"mem-write", "RAM0", "0x10", "dead"},
+	} {
+		if _, out, err := ctl(t, args...); err != nil || out != "OK\n" {
+			t.Errorf("%q = %q, %v", args, out, err)
+		}
+	}
+}
+
+// TestCtlReportsReturnCodes verifies CtlReportsReturnCodes behavior.
+func TestCtlReportsReturnCodes(t *testing.T) {
+	_, _, err := ctl(t, "bist")
+	if err == nil || !strings.Contains(err.Error(), "WR_BIST refused: return code NOT_SUPPORTED (0x02)") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// TestCtlInvalidArguments verifies CtlInvalidArguments behavior.
+func TestCtlInvalidArguments(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"frobnicate"},
+		{"version", "now"},
+		{"repeater", "sometimes"},
+		{"repeater", "on", "3"},
+		{"learnmode", "on", "forever"},
+		{"filter-add", "sender_id"},
+		{"filter-add", "rssi", "70"},
+		{"baudrate", "9600"},
+		{"mem-read", "ROM", "0", "16"},
+		{"mem-write", "RAM0", "0", "xyz"},
+	} {
+		if _, _, err := ctl(t, args...); err == nil {
+			t.Errorf("%q succeeded", args)
+		}
+	}
+}
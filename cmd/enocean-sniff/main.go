@@ -0,0 +1,147 @@
+// Command enocean-sniff prints the ESP3 traffic of a transceiver or of a
+// capture file, decoded down to the EEP of known senders.
+//
+//	enocean-sniff -list
+//	enocean-sniff -port /dev/ttyUSB0 -devices devices.yaml
+//	enocean-sniff -capture dump.pcapng -rorg 4BS,F6 -format json
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/capture"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+)
+
+// main runs the command.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, flag.CommandLine, os.Args[1:], os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run executes the command, writing the decoded traffic to out.
+func run(ctx context.Context, fs *flag.FlagSet, args []string, out io.Writer) error {
+	list := fs.Bool("list", false, "list the serial ports and exit")
+	port := fs.String("port", "", "serial port of the transceiver")
+	capturePath := fs.String("capture", "", "capture file to read (JSON lines or .pcapng)")
+	devicesPath := fs.String("devices", "", "device registry mapping senders to EEPs (JSON or YAML)")
+	senders := fs.String("sender", "", "comma-separated sender IDs to show, e.g. 0180A1B2")
+	rorgs := fs.String("rorg", "", "comma-separated RORGs to show, by name or hex, e.g. 4BS,F6")
+	types := fs.String("type", "", "comma-separated packet types to show, by name or hex, e.g. RADIO_ERP1,04")
+	format := fs.String("format", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *list {
+		ports, err := pkg.GetSerialPortList()
+		if err != nil {
+			return err
+		}
+		for _, p := range ports {
+			fmt.Fprintln(out, p)
+		}
+		return nil
+	}
+
+	f, err := parseFilter(*senders, *rorgs, *types)
+	if err != nil {
+		return err
+	}
+	var devices *registry.Registry
+	if *devicesPath != "" {
+		if devices, err = registry.Load(*devicesPath); err != nil {
+			return err
+		}
+	}
+	p, err := newPrinter(out, *format)
+	if err != nil {
+		return err
+	}
+	s := &sniffer{devices: devices, filter: f, print: p}
+
+	switch {
+	case *port != "" && *capturePath != "":
+		return errors.New("-port and -capture are exclusive")
+	case *port != "":
+		return sniffPort(ctx, *port, s)
+	case *capturePath != "":
+		return sniffCapture(ctx, *capturePath, s)
+	default:
+		return errors.New("one of -port, -capture or -list is required")
+	}
+}
+
+// sniffPort prints the telegrams received on the serial port at path until
+// ctx is cancelled.
+func sniffPort(ctx context.Context, path string, s *sniffer) error {
+	t, err := pkg.OpenSerialTransport(path)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	return sniffTransport(ctx, t, s)
+}
+
+// sniffTransport prints the telegrams read from t until ctx is cancelled or
+// t fails. The ESP3 stream blocks the parser instead of dropping telegrams
+// when printing falls behind; the unread streams hold a single message.
+func sniffTransport(ctx context.Context, t pkg.Transport, s *sniffer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	channels := pkg.StartParser(ctx, t, pkg.ParserConfig{Streams: pkg.StreamConfig{
+		BufferSize:  1,
+		BufferSizes: map[pkg.Stream]int{pkg.StreamESP3: 64},
+		Overflows:   map[pkg.Stream]pkg.Overflow{pkg.StreamESP3: pkg.OverflowBlock},
+	}})
+
+	for {
+		select {
+		case telegram, ok := <-channels.ESP3:
+			if !ok {
+				return nil
+			}
+			if err := s.telegram(time.Now(), capture.DirectionRx, telegram); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// sniffCapture prints the records of the capture file at path.
+func sniffCapture(ctx context.Context, path string, s *sniffer) error {
+	r, err := capture.Open(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for rec, err := range r.Records() {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err := s.record(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
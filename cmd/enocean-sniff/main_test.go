@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/capture"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// writeFixtures writes a capture file and a device registry to a temporary
+// directory and returns their paths.
+func writeFixtures(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	devices := filepath.Join(dir, "devices.json")
+	registryJSON := `{"devices":[{"id":"01a0b0c0","eep":"A5-02-05","name":"office"}]}`
+	if err := os.WriteFile(devices, []byte(registryJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, _ := eep.FromString("A5-02-05")
+	userData, status, err := profiles.Encode(profile, map[string]uint64{"TMP": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userData[3] |= 0x08
+	sensor := erp1.Packet{Rorg: enums.Rorg4BS, UserData: userData, Status: status, SenderID: 0x01a0b0c0, DestinationID: deviceid.BroadcastId(), SubTelNum: 1}
+	button := erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x30}, Status: 0x30, SenderID: 0xfef00001, DestinationID: deviceid.BroadcastId(), SubTelNum: 1}
+	sensorTelegram, buttonTelegram := sensor.ToEsp3(), button.ToEsp3()
+	sensorTelegram.OptData[5] = 0x45
+	ready := esp3.NewTelegramFromData(enums.PacketTypeEVENT, []byte{byte(enums.EventCodeCO_READY), 0x00}, []byte{0x00})
+	ok := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS)}, nil)
+
+	path := filepath.Join(dir, "dump.jsonl")
+	rec, err := capture.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i, r := range []capture.Record{
+		{Direction: capture.DirectionRx, Raw: ready.Serialize()},
+		{Direction: capture.DirectionTx, Raw: esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{0x03}, nil).Serialize()},
+		{Direction: capture.DirectionRx, Raw: ok.Serialize()},
+		{Direction: capture.DirectionRx, Raw: sensorTelegram.Serialize()},
+		{Direction: capture.DirectionRejected, Raw: []byte{0x00, 0x07}, Note: "CRC8H mismatch"},
+		{Direction: capture.DirectionRx, Raw: buttonTelegram.Serialize()},
+	} {
+		r.Time = start.Add(time.Duration(i) * time.Second)
+		if err := rec.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path, devices
+}
+
+// sniff runs the command with args and returns its output.
+func sniff(t *testing.T, args ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := run(context.Background(), flag.NewFlagSet("enocean-sniff", flag.ContinueOnError), args, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+// TestSniffCaptureTable verifies SniffCaptureTable behavior.
+func TestSniffCaptureTable(t *testing.T) {
+	path, devices := writeFixtures(t)
+
+	out := sniff(t, "-capture", path, "-devices", devices)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("got %d lines:\n%s", len(lines), out)
+	}
+	for i, want := range []string{
+		"SUMMARY",
+		"COReady",
+		"COMMON_COMMAND",
+		"SUCCESS",
+		"office: A5-02-05 LRNB=DataTelegram TMP=",
+		"CRC8H mismatch",
+		"fef00001",
+	} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %q, want %q", i, lines[i], want)
+		}
+	}
+	if !strings.Contains(lines[4], "-69") {
+		t.Errorf("line 4 = %q, want the RSSI", lines[4])
+	}
+}
+
+// TestSniffCaptureJSONFiltered verifies SniffCaptureJSONFiltered behavior.
+func TestSniffCaptureJSONFiltered(t *testing.T) {
+	path, devices := writeFixtures(t)
+
+	out := sniff(t, "-capture", path, "-devices", devices, "-format", "json", "-type", "radio_erp1", "-rorg", "A5,RPS", "-sender", "01A0B0C0")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines:\n%s", len(lines), out)
+	}
+	var e struct {
+		Kind   string                    `json:"kind"`
+		Device string                    `json:"device"`
+		Sender string                    `json:"sender"`
+		Values map[string]map[string]any `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Kind != kindEEP || e.Device != "office" || e.Sender != "01a0b0c0" || e.Values["TMP"]["Raw"] != 100.0 {
+		t.Fatalf("entry = %+v", e)
+	}
+
+	out = sniff(t, "-capture", path, "-format", "json", "-type", "EVENT,0x02")
+	if n := strings.Count(out, "\n"); n != 2 {
+		t.Fatalf("got %d lines:\n%s", n, out)
+	}
+}
+
+// TestSniffTransmittedRadioHasNoRSSI verifies SniffTransmittedRadioHasNoRSSI behavior.
+func TestSniffTransmittedRadioHasNoRSSI(t *testing.T) {
+	var got []entry
+	s := &sniffer{print: func(e entry) error {
+		got = append(got, e)
+		return nil
+	}}
+	button := erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x30}, Status: 0x30, SenderID: 0xfef00001, DestinationID: deviceid.BroadcastId(), SubTelNum: 1}
+	rec := capture.Record{Time: time.Now(), Direction: capture.DirectionTx, Raw: button.ToEsp3().Serialize()}
+	if err := s.record(rec); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Kind != kindERP1 || got[0].DBm != 0 {
+		t.Fatalf("entries = %+v", got)
+	}
+}
+
+// TestSniffInvalidArguments verifies SniffInvalidArguments behavior.
+func TestSniffInvalidArguments(t *testing.T) {
+	path, _ := writeFixtures(t)
+	for _, args := range [][]string{
+		{},
+		{"-capture", path, "-port", "/dev/null"},
+		{"-capture", path, "-format", "xml"},
+		{"-capture", path, "-rorg", "XYZ"},
+		{"-capture", path, "-type", "08"},
+		{"-capture", path, "-sender", "123456789"},
+	} {
+		if err := run(context.Background(), flag.NewFlagSet("enocean-sniff", flag.ContinueOnError), args, &bytes.Buffer{}); err == nil {
+			t.Errorf("run(%q) succeeded", args)
+		}
+	}
+}
+
+// TestSniffTransportIsLossless verifies SniffTransportIsLossless behavior.
+func TestSniffTransportIsLossless(t *testing.T) {
+	const count = 300
+	host, device := pkg.NewLoopback()
+	go func() {
+		frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS)}, nil).Serialize()
+		for range count {
+			if _, err := host.Write(frame); err != nil {
+				return
+			}
+		}
+		host.Close()
+	}()
+
+	printed := 0
+	s := &sniffer{print: func(entry) error {
+		time.Sleep(100 * time.Microsecond) // a slow terminal
+		printed++
+		return nil
+	}}
+	if err := sniffTransport(context.Background(), device, s); err != nil {
+		t.Fatal(err)
+	}
+	if printed != count {
+		t.Fatalf("printed %d telegrams, want %d", printed, count)
+	}
+}
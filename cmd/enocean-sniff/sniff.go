@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/internal/enumflag"
+	"github.com/edlundin/enocean-esp3/pkg/capture"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/radioerp2"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+	"github.com/edlundin/enocean-esp3/pkg/reman"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+	"github.com/edlundin/enocean-esp3/pkg/smartack"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
+)
+
+// entry is one decoded telegram, as printed.
+type entry struct {
+	Time        time.Time `json:"time"`
+	Direction   string    `json:"dir"`
+	Type        string    `json:"type,omitempty"`
+	Rorg        string    `json:"rorg,omitempty"`
+	Sender      string    `json:"sender,omitempty"`
+	Destination string    `json:"destination,omitempty"`
+	DBm         int       `json:"dbm,omitempty"`
+	Device      string    `json:"device,omitempty"`
+	Kind        string    `json:"kind"`
+	Summary     string    `json:"summary"`
+	Values      any       `json:"values,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Raw         string    `json:"hex"`
+
+	sender deviceid.DeviceID
+	rorg   enums.Rorg
+	radio  bool
+	packet enums.PacketType
+}
+
+// filter selects the telegrams to print. Empty sets match everything.
+type filter struct {
+	senders map[deviceid.DeviceID]bool
+	rorgs   map[enums.Rorg]bool
+	types   map[enums.PacketType]bool
+}
+
+// parseFilter parses the comma-separated lists of the filter flags.
+func parseFilter(senders, rorgs, types string) (filter, error) {
+	f := filter{senders: map[deviceid.DeviceID]bool{}, rorgs: map[enums.Rorg]bool{}, types: map[enums.PacketType]bool{}}
+	for _, s := range splitList(senders) {
+		id, err := deviceid.FromHexString(s)
+		if err != nil {
+			return filter{}, fmt.Errorf("invalid sender %q: %w", s, err)
+		}
+		f.senders[id] = true
+	}
+	for _, s := range splitList(rorgs) {
+		rorg, err := enumflag.Parse[enums.Rorg](s)
+		if err != nil {
+			return filter{}, fmt.Errorf("invalid RORG %q", s)
+		}
+		f.rorgs[rorg] = true
+	}
+	for _, s := range splitList(types) {
+		packetType, err := enumflag.Parse[enums.PacketType](s)
+		if err != nil {
+			return filter{}, fmt.Errorf("invalid packet type %q", s)
+		}
+		f.types[packetType] = true
+	}
+	return f, nil
+}
+
+// splitList splits a comma-separated flag value, ignoring blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// match reports whether e passes the filter. Rejected input has no packet
+// type or sender, so it only shows without filters.
+func (f filter) match(e entry) bool {
+	if len(f.types) > 0 && (e.Kind == kindRejected || !f.types[e.packet]) {
+		return false
+	}
+	if len(f.senders) > 0 && (!e.radio || !f.senders[e.sender]) {
+		return false
+	}
+	if len(f.rorgs) > 0 && (!e.radio || !f.rorgs[e.rorg]) {
+		return false
+	}
+	return true
+}
+
+// Kinds of entries, naming the layer the summary was decoded with.
+const (
+	kindERP1     = "erp1"
+	kindERP2     = "erp2"
+	kindEEP      = "eep"
+	kindTeachIn  = "teach_in"
+	kindSmartAck = "smart_ack"
+	kindReMan    = "reman_part"
+	kindResponse = "response"
+	kindEvent    = "event"
+	kindUnparsed = "unparsed"
+	kindError    = "parse_error"
+	kindRejected = "rejected"
+)
+
+// sniffer decodes telegrams and prints those passing its filter.
+type sniffer struct {
+	devices *registry.Registry
+	filter  filter
+	print   func(entry) error
+}
+
+// record prints a capture record.
+func (s *sniffer) record(rec capture.Record) error {
+	if rec.Direction == capture.DirectionRejected {
+		return s.emit(entry{Time: rec.Time, Direction: rec.Direction.String(), Kind: kindRejected, Summary: rec.Note, Raw: rec.Raw.String()})
+	}
+	t, err := rec.Telegram()
+	if err != nil {
+		return s.emit(entry{Time: rec.Time, Direction: rec.Direction.String(), Kind: kindError, Error: err.Error(), Raw: rec.Raw.String()})
+	}
+	return s.telegram(rec.Time, rec.Direction, t)
+}
+
+// telegram decodes and prints t.
+func (s *sniffer) telegram(at time.Time, dir capture.Direction, t esp3.Telegram) error {
+	e := entry{Time: at, Direction: dir.String(), Type: t.PacketType.String(), Raw: hex.EncodeToString(t.Serialize()), packet: t.PacketType}
+	if err := s.decode(&e, t); err != nil {
+		e.Kind, e.Error = kindError, err.Error()
+	}
+	return s.emit(e)
+}
+
+// emit prints e if it passes the filter.
+func (s *sniffer) emit(e entry) error {
+	if !s.filter.match(e) {
+		return nil
+	}
+	return s.print(e)
+}
+
+// decode fills the decoded fields of e from t.
+func (s *sniffer) decode(e *entry, t esp3.Telegram) error {
+	switch t.PacketType {
+	case enums.PacketTypeRADIO_ERP1:
+		p, err := erp1.NewPacketFromEsp3(t)
+		if err != nil {
+			return err
+		}
+		return s.decodeERP1(e, p)
+	case enums.PacketTypeRADIO_SUB_TEL:
+		p, err := subtel.NewPacketFromEsp3(t)
+		if err != nil {
+			return err
+		}
+		return s.decodeERP1(e, p.ERP1())
+	case enums.PacketTypeRADIO_ERP2:
+		p, err := radioerp2.NewPacketFromEsp3(t)
+		if err != nil {
+			return err
+		}
+		telegram, err := erp2.Parse(p.Data)
+		if err != nil {
+			return err
+		}
+		radio, err := telegram.ERP1()
+		if err != nil {
+			e.Kind, e.Summary = kindERP2, hex.EncodeToString(telegram.Data)
+			return nil
+		}
+		radio.Rssi = p.Dbm
+		return s.decodeERP1(e, radio)
+	case enums.PacketTypeRESPONSE:
+		p, err := response.NewPacketFromEsp3(t)
+		if err != nil {
+			return err
+		}
+		e.Kind, e.Summary = kindResponse, p.Code.String()
+		if len(p.Data) > 0 {
+			e.Summary += " " + hex.EncodeToString(p.Data)
+		}
+	case enums.PacketTypeEVENT:
+		ev, err := event.NewPacketFromEsp3(t)
+		if err != nil {
+			return err
+		}
+		e.Kind, e.Summary, e.Values = kindEvent, describe(ev), ev
+	default:
+		e.Kind, e.Summary = kindUnparsed, hex.EncodeToString(t.Data)
+		if len(t.OptData) > 0 {
+			e.Summary += " opt " + hex.EncodeToString(t.OptData)
+		}
+	}
+	return nil
+}
+
+// decodeERP1 decodes p with the EEP of its sender, or as Smart Ack or
+// remote management traffic.
+func (s *sniffer) decodeERP1(e *entry, p erp1.Packet) error {
+	e.radio, e.sender, e.rorg = true, p.SenderID, p.Rorg
+	e.Rorg, e.Sender, e.Destination = p.Rorg.String(), p.SenderID.String(), p.DestinationID.String()
+	if p.Rssi != erp1.RssiUnknown {
+		e.DBm = -int(p.Rssi)
+	}
+	e.Kind, e.Summary = kindERP1, hex.EncodeToString(p.UserData)
+
+	if s.devices != nil {
+		if device, ok := s.devices.Lookup(p.SenderID); ok {
+			e.Device = device.Name
+			switch {
+			case registry.IsTeachIn(p):
+				e.Kind, e.Summary = kindTeachIn, "teach-in"
+				if profile, ok := registry.TeachIn(p); ok {
+					e.Summary += " " + profile.String()
+				}
+				return nil
+			case p.Rorg == device.EEP.Rorg:
+				telegram, err := profiles.ParsePacket(p, device.EEP)
+				if err != nil {
+					return fmt.Errorf("%s: %w", device.EEP, err)
+				}
+				e.Kind, e.Summary, e.Values = kindEEP, telegram.Format(), values(telegram)
+				return nil
+			}
+		}
+	}
+
+	if p.Rorg == enums.RorgSYS_EX {
+		part, err := reman.ParsePacket(p)
+		if err != nil {
+			return err
+		}
+		e.Kind, e.Summary, e.Values = kindReMan, describe(part), part
+		return nil
+	}
+	if msg, err := smartack.Parse(p); err == nil {
+		e.Kind, e.Summary, e.Values = kindSmartAck, describe(msg), msg
+	}
+	return nil
+}
+
+// values returns the JSON form of the fields of t.
+func values(t profiles.Telegram) any {
+	if d, ok := t.(profiles.Decoded); ok {
+		return d.Values
+	}
+	return t
+}
+
+// describe formats v with its type name, e.g. "COReady {Cause:...}".
+func describe(v any) string {
+	return fmt.Sprintf("%s %+v", reflect.TypeOf(v).Name(), v)
+}
+
+// newPrinter returns the function printing entries to w in format.
+func newPrinter(w io.Writer, format string) (func(entry) error, error) {
+	switch format {
+	case "table":
+		header := true
+		return func(e entry) error {
+			if header {
+				header = false
+				if _, err := fmt.Fprintf(w, tableFormat, "TIME", "DIR", "TYPE", "RORG", "SENDER", "DBM", "SUMMARY"); err != nil {
+					return err
+				}
+			}
+			summary := e.Summary
+			if e.Device != "" {
+				summary = e.Device + ": " + summary
+			}
+			if e.Error != "" {
+				summary = strings.TrimSpace(summary + " error: " + e.Error)
+			}
+			dBm := ""
+			if e.DBm != 0 {
+				dBm = strconv.Itoa(e.DBm)
+			}
+			_, err := fmt.Fprintf(w, tableFormat, e.Time.Format("15:04:05.000"), e.Direction, e.Type, e.Rorg, e.Sender, dBm, summary)
+			return err
+		}, nil
+	case "json":
+		enc := json.NewEncoder(w)
+		return func(e entry) error { return enc.Encode(e) }, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q, want table or json", format)
+	}
+}
+
+// tableFormat lays out the columns of the table format.
+const tableFormat = "%-12s %-8s %-17s %-10s %-8s %4s  %s\n"
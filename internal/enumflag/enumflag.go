@@ -0,0 +1,32 @@
+// Package enumflag parses the byte enums of package enums given on the
+// command line.
+package enumflag
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Enum is a byte enum of package enums.
+type Enum interface {
+	~uint8
+	String() string
+	Valid() bool
+}
+
+// Parse parses an enum value by name, case-insensitively, or as a hex byte
+// with an optional 0x prefix.
+func Parse[T Enum](s string) (T, error) {
+	for b := range 256 {
+		if v := T(b); v.Valid() && strings.EqualFold(v.String(), s) {
+			return v, nil
+		}
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if n, err := strconv.ParseUint(hex, 16, 8); err == nil && T(n).Valid() {
+		return T(n), nil
+	}
+	var zero T
+	return zero, fmt.Errorf("invalid %T %q", zero, s)
+}
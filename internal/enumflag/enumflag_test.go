@@ -0,0 +1,30 @@
+package enumflag
+
+import (
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+)
+
+// TestParse verifies Parse behavior.
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want enums.Rorg
+	}{
+		{"4BS", enums.Rorg4BS},
+		{"rps", enums.RorgRPS},
+		{"F6", enums.RorgRPS},
+		{"0xa5", enums.Rorg4BS},
+	} {
+		if got, err := Parse[enums.Rorg](tc.in); err != nil || got != tc.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tc.in, got, err, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "4B", "0x", "100", "0x00"} {
+		if got, err := Parse[enums.Rorg](in); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", in, got)
+		}
+	}
+}
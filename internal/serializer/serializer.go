@@ -26,6 +26,10 @@ type SerializerConfig struct {
 	// ByteOrder controls how numeric values are encoded.
 	// If nil, binary.BigEndian is used.
 	ByteOrder binary.ByteOrder
+
+	// PacketType is the packet type of the telegram.
+	// If zero, enums.PacketTypeCOMMON_COMMAND is used.
+	PacketType enums.PacketType
 }
 
 // sanitize ensures we always have a non-nil map and byte order.
@@ -36,6 +40,9 @@ func (c SerializerConfig) sanitize() SerializerConfig {
 	if c.ByteOrder == nil {
 		c.ByteOrder = binary.BigEndian
 	}
+	if c.PacketType == 0 {
+		c.PacketType = enums.PacketTypeCOMMON_COMMAND
+	}
 	return c
 }
 
@@ -43,6 +50,7 @@ func (c SerializerConfig) sanitize() SerializerConfig {
 // Later configs override earlier ones for the same fields.
 // Serializers maps are merged (later entries override earlier ones for the same type).
 // ByteOrder uses the last non-nil value, or defaults to BigEndian if all are nil.
+// PacketType uses the last non-zero value.
 func mergeConfigs(configs []SerializerConfig) SerializerConfig {
 	var merged SerializerConfig
 
@@ -61,6 +69,10 @@ func mergeConfigs(configs []SerializerConfig) SerializerConfig {
 		if cfg.ByteOrder != nil {
 			merged.ByteOrder = cfg.ByteOrder
 		}
+
+		if cfg.PacketType != 0 {
+			merged.PacketType = cfg.PacketType
+		}
 	}
 
 	return merged
@@ -132,7 +144,7 @@ func CommandToTelegram(cmd any, cfg ...SerializerConfig) (esp3.Telegram, error)
 		optData = nil
 	}
 
-	return esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, bufData.Bytes(), optData), nil
+	return esp3.NewTelegramFromData(config.PacketType, bufData.Bytes(), optData), nil
 }
 
 // serializeValue serializes a value using the provided configuration.
@@ -1297,6 +1297,33 @@ func TestCommandToTelegram_VariadicConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("merges multiple configs - PacketType override", func(t *testing.T) {
+		type CodeStruct struct {
+			Code uint8 `enocean-esp3:"data"`
+		}
+
+		cmd := CodeStruct{Code: 0x01}
+
+		telegram, err := CommandToTelegram(cmd)
+		if err != nil {
+			t.Fatalf("failed to serialize: %v", err)
+		}
+		if telegram.PacketType != enums.PacketTypeCOMMON_COMMAND {
+			t.Errorf("default packet type: got %s, expected COMMON_COMMAND", telegram.PacketType)
+		}
+
+		cfg1 := SerializerConfig{PacketType: enums.PacketTypeSMART_ACK_COMMAND}
+		cfg2 := SerializerConfig{ByteOrder: binary.LittleEndian}
+
+		telegram, err = CommandToTelegram(cmd, cfg1, cfg2)
+		if err != nil {
+			t.Fatalf("failed to serialize: %v", err)
+		}
+		if telegram.PacketType != enums.PacketTypeSMART_ACK_COMMAND {
+			t.Errorf("packet type: got %s, expected SMART_ACK_COMMAND (last non-zero)", telegram.PacketType)
+		}
+	})
+
 	t.Run("merges multiple configs - later Serializers override earlier", func(t *testing.T) {
 		type CustomInt int32
 
@@ -0,0 +1,122 @@
+// Package capture records raw ESP3 traffic to capture files and reads them
+// back.
+//
+// A capture file is UTF-8 JSON lines. The first line is a header, every
+// following line a record:
+//
+//	{"format":"esp3-capture","version":1,"started":"2024-05-01T09:00:00.123Z"}
+//	{"time":"2024-05-01T09:00:01.5Z","dir":"rx","hex":"55000707017a..."}
+//	{"time":"2024-05-01T09:00:02Z","dir":"tx","hex":"5500010005700838"}
+//	{"time":"2024-05-01T09:00:03Z","dir":"rejected","hex":"000707","note":"CRC8H mismatch: ..."}
+//
+// rx and tx records hold one complete frame, sync byte and checksums
+// included. rejected records hold bytes the parser dropped, when known,
+// and the reason in note.
+//
+// Files named *.pcapng are written and read as pcapng instead, for Wireshark;
+// see LinkTypeESP3.
+package capture
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+const (
+	formatName    = "esp3-capture"
+	formatVersion = 1
+)
+
+// Direction tells where the bytes of a record came from.
+type Direction uint8
+
+const (
+	// DirectionRx is a frame received from the transceiver.
+	DirectionRx Direction = iota
+	// DirectionTx is a frame written to the transceiver.
+	DirectionTx
+	// DirectionRejected is input the parser dropped.
+	DirectionRejected
+)
+
+// String returns the string representation of Direction.
+func (dir Direction) String() string {
+	switch dir {
+	case DirectionRx:
+		return "rx"
+	case DirectionTx:
+		return "tx"
+	case DirectionRejected:
+		return "rejected"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the direction as it appears in capture files.
+func (dir Direction) MarshalText() ([]byte, error) {
+	if dir > DirectionRejected {
+		return nil, fmt.Errorf("invalid direction %d", dir)
+	}
+	return []byte(dir.String()), nil
+}
+
+// UnmarshalText decodes a direction from a capture file.
+func (dir *Direction) UnmarshalText(b []byte) error {
+	for _, d := range []Direction{DirectionRx, DirectionTx, DirectionRejected} {
+		if string(b) == d.String() {
+			*dir = d
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid direction %q", b)
+}
+
+type header struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Started time.Time `json:"started"`
+}
+
+// Record is one line of a capture file.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	Raw       HexBytes  `json:"hex"`
+	Note      string    `json:"note,omitempty"`
+}
+
+// Telegram decodes the frame of an rx or tx record.
+func (r Record) Telegram() (esp3.Telegram, error) {
+	if r.Direction == DirectionRejected {
+		return esp3.Telegram{}, errors.New("rejected record holds no frame")
+	}
+	return esp3.NewEsp3TelegramFromHexString(r.Raw.String())
+}
+
+// HexBytes is a byte slice encoded as a hex string in JSON.
+type HexBytes []byte
+
+// String returns the lower-case hex encoding of b.
+func (b HexBytes) String() string {
+	return hex.EncodeToString(b)
+}
+
+// MarshalText encodes b as hex.
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalText decodes hex into b.
+func (b *HexBytes) UnmarshalText(text []byte) error {
+	raw, err := hex.DecodeString(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid hex %q: %w", text, err)
+	}
+	*b = raw
+	return nil
+}
@@ -0,0 +1,121 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestRecorderCapturesParserTraffic verifies RecorderCapturesParserTraffic behavior.
+func TestRecorderCapturesParserTraffic(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	var file bytes.Buffer
+	rec, err := NewRecorder(&file, RecorderConfig{Rejected: true, Now: func() time.Time { return start }})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil)
+	corrupted := response.Serialize()
+	corrupted[len(corrupted)-1] ^= 0xff
+	input := append(append([]byte{0x01, 0x02}, corrupted...), response.Serialize()...)
+
+	channels := pkg.StartParser(context.Background(), bytes.NewReader(input), pkg.ParserConfig{Hooks: rec})
+	for range channels.All {
+	}
+	rec.Written([]byte{0x55, 0x00, 0x01, 0x00, 0x05, 0x70, 0x08, 0x38})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(strings.NewReader(file.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Started.Equal(start) {
+		t.Fatalf("started = %s", r.Started)
+	}
+	var dirs []Direction
+	var notes []string
+	for record, err := range r.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, record.Direction)
+		notes = append(notes, record.Note)
+	}
+	want := []Direction{DirectionRejected, DirectionRejected, DirectionRx, DirectionTx}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Fatalf("directions = %v, want %v\n%s", dirs, want, file.String())
+	}
+	if notes[0] != "resync: skipped 2 bytes" || !strings.HasPrefix(notes[1], "CRC8D mismatch") {
+		t.Fatalf("notes = %q", notes)
+	}
+	if !strings.Contains(file.String(), `"dir":"rx","hex":"`+HexBytes(response.Serialize()).String()+`"`) {
+		t.Fatalf("capture = %s", file.String())
+	}
+}
+
+// TestReaderIteratesTelegrams verifies ReaderIteratesTelegrams behavior.
+func TestReaderIteratesTelegrams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.jsonl")
+	rec, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil)
+	second := esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{0x08}, nil)
+	rec.Frame(first.Serialize())
+	rec.Record(Record{Time: time.Now(), Direction: DirectionRejected, Raw: []byte{0x01}})
+	rec.Record(Record{Time: time.Now(), Direction: DirectionRx, Raw: []byte{0x55, 0x00}})
+	rec.Written(second.Serialize())
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var frames [][]byte
+	var errs int
+	for telegram, err := range r.Telegrams() {
+		if err != nil {
+			errs++
+			continue
+		}
+		frames = append(frames, telegram.Serialize())
+	}
+	if errs != 1 || !reflect.DeepEqual(frames, [][]byte{first.Serialize(), second.Serialize()}) {
+		t.Fatalf("frames = %x, errors = %d", frames, errs)
+	}
+}
+
+// TestNewReaderRejectsForeignFiles verifies NewReaderRejectsForeignFiles behavior.
+func TestNewReaderRejectsForeignFiles(t *testing.T) {
+	for _, doc := range []string{
+		"",
+		"hello\n",
+		`{"format":"pcap","version":1}` + "\n",
+		`{"format":"esp3-capture","version":9}` + "\n",
+	} {
+		if _, err := NewReader(strings.NewReader(doc)); err == nil {
+			t.Fatalf("NewReader(%q) succeeded", doc)
+		}
+	}
+
+	r, _ := NewReader(strings.NewReader(`{"format":"esp3-capture","version":1}` + "\n" + `{"dir":"sideways"}` + "\n"))
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("err = %v", err)
+	}
+}
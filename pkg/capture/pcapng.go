@@ -0,0 +1,334 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// LinkTypeESP3 is the pcapng link type of ESP3 captures, LINKTYPE_USER0.
+// Each packet holds one ESP3 frame, sync byte and checksums included. In
+// Wireshark, load wireshark/esp3.lua to dissect it.
+const LinkTypeESP3 = 147
+
+const (
+	pcapngSectionHeader   = 0x0a0d0d0a
+	pcapngInterface       = 0x00000001
+	pcapngSimplePacket    = 0x00000003
+	pcapngEnhancedPacket  = 0x00000006
+	pcapngByteOrderMagic  = 0x1a2b3c4d
+	pcapngOptEnd          = 0
+	pcapngOptComment      = 1
+	pcapngOptShbUserAppl  = 4
+	pcapngOptIfName       = 2
+	pcapngOptIfTsresol    = 9
+	pcapngOptEpbFlags     = 2
+	pcapngFlagInbound     = 1
+	pcapngFlagOutbound    = 2
+	pcapngFlagDirection   = 3
+	pcapngFlagCRCError    = 1 << 31
+	pcapngNanoseconds     = 9
+	pcapngDefaultTsresol  = 6
+	pcapngMaxBlockSize    = 1 << 20
+	pcapngBlockFramingLen = 12
+)
+
+// pcapngEarliest and pcapngLatest bound the record times the writer can
+// store as nanoseconds since 1970 in an int64.
+var (
+	pcapngEarliest = time.Unix(0, 0)
+	pcapngLatest   = time.Unix(0, math.MaxInt64)
+)
+
+// PcapngWriter writes ESP3 traffic as a pcapng section with one LinkTypeESP3
+// interface. Timestamps have nanosecond resolution; the direction is stored
+// in the packet flags and rejected input is flagged as a CRC error.
+type PcapngWriter struct {
+	w *bufio.Writer
+}
+
+// NewPcapngWriter writes the section header and interface description to w.
+func NewPcapngWriter(w io.Writer) (*PcapngWriter, error) {
+	pw := &PcapngWriter{w: bufio.NewWriter(w)}
+
+	var shb []byte
+	shb = binary.LittleEndian.AppendUint32(shb, pcapngByteOrderMagic)
+	shb = binary.LittleEndian.AppendUint16(shb, 1)
+	shb = binary.LittleEndian.AppendUint16(shb, 0)
+	shb = binary.LittleEndian.AppendUint64(shb, ^uint64(0)) // section length unknown
+	shb = appendPcapngOption(shb, pcapngOptShbUserAppl, []byte("enocean-esp3"))
+	shb = appendPcapngOption(shb, pcapngOptEnd, nil)
+
+	var idb []byte
+	idb = binary.LittleEndian.AppendUint16(idb, LinkTypeESP3)
+	idb = binary.LittleEndian.AppendUint16(idb, 0)
+	idb = binary.LittleEndian.AppendUint32(idb, 0) // no snapshot length limit
+	idb = appendPcapngOption(idb, pcapngOptIfName, []byte("esp3"))
+	idb = appendPcapngOption(idb, pcapngOptIfTsresol, []byte{pcapngNanoseconds})
+	idb = appendPcapngOption(idb, pcapngOptEnd, nil)
+
+	if err := pw.writeBlock(pcapngSectionHeader, shb); err != nil {
+		return nil, err
+	}
+	if err := pw.writeBlock(pcapngInterface, idb); err != nil {
+		return nil, err
+	}
+	return pw, pw.w.Flush()
+}
+
+// WriteTelegram writes telegram as a packet sent in direction dir at t.
+func (pw *PcapngWriter) WriteTelegram(t time.Time, dir Direction, telegram esp3.Telegram) error {
+	return pw.WriteRecord(Record{Time: t, Direction: dir, Raw: telegram.Serialize()})
+}
+
+// WriteRecord writes rec as a packet, with its note as the packet comment.
+// Times before 1970 or after 2262 cannot be stored and are rejected.
+func (pw *PcapngWriter) WriteRecord(rec Record) error {
+	if rec.Time.Before(pcapngEarliest) || rec.Time.After(pcapngLatest) {
+		return fmt.Errorf("pcapng: record time %s out of range", rec.Time)
+	}
+	flags := uint32(pcapngFlagInbound)
+	switch rec.Direction {
+	case DirectionTx:
+		flags = pcapngFlagOutbound
+	case DirectionRejected:
+		flags |= pcapngFlagCRCError
+	}
+	ts := uint64(rec.Time.UnixNano())
+
+	var epb []byte
+	epb = binary.LittleEndian.AppendUint32(epb, 0) // interface ID
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts>>32))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(ts))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(rec.Raw)))
+	epb = binary.LittleEndian.AppendUint32(epb, uint32(len(rec.Raw)))
+	epb = appendPadded(epb, rec.Raw)
+	epb = appendPcapngOption(epb, pcapngOptEpbFlags, binary.LittleEndian.AppendUint32(nil, flags))
+	if rec.Note != "" {
+		epb = appendPcapngOption(epb, pcapngOptComment, []byte(rec.Note))
+	}
+	epb = appendPcapngOption(epb, pcapngOptEnd, nil)
+
+	if err := pw.writeBlock(pcapngEnhancedPacket, epb); err != nil {
+		return err
+	}
+	return pw.w.Flush()
+}
+
+// writeBlock writes a block of type blockType around body.
+func (pw *PcapngWriter) writeBlock(blockType uint32, body []byte) error {
+	total := uint32(len(body) + pcapngBlockFramingLen)
+	block := make([]byte, 0, total)
+	block = binary.LittleEndian.AppendUint32(block, blockType)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	block = append(block, body...)
+	block = binary.LittleEndian.AppendUint32(block, total)
+	_, err := pw.w.Write(block)
+	return err
+}
+
+// appendPcapngOption appends an option with its value padded to 32 bits.
+func appendPcapngOption(b []byte, code uint16, value []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, code)
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(value)))
+	return appendPadded(b, value)
+}
+
+// appendPadded appends value and zero bytes up to a 32-bit boundary.
+func appendPadded(b []byte, value []byte) []byte {
+	b = append(b, value...)
+	return append(b, make([]byte, -len(value)&3)...)
+}
+
+type pcapngInterfaceInfo struct {
+	linkType       uint16
+	unitsPerSecond uint64
+}
+
+type pcapngReader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterfaceInfo
+}
+
+// NewPcapngReader reads a pcapng capture from r. Packets of interfaces with
+// another link type than LinkTypeESP3 are skipped.
+func NewPcapngReader(r io.Reader) (*Reader, error) {
+	pr := &pcapngReader{r: bufio.NewReader(r)}
+	magic, err := pr.r.Peek(4)
+	if err != nil || binary.LittleEndian.Uint32(magic) != pcapngSectionHeader {
+		return nil, errors.New("not a pcapng capture")
+	}
+	return &Reader{next: pr.next}, nil
+}
+
+// next returns the next ESP3 packet as a record.
+func (pr *pcapngReader) next() (Record, error) {
+	for {
+		blockType, body, err := pr.readBlock()
+		if err != nil {
+			return Record{}, err
+		}
+
+		switch blockType {
+		case pcapngSectionHeader:
+			pr.interfaces = nil
+		case pcapngInterface:
+			if len(body) < 8 {
+				return Record{}, errors.New("pcapng: short interface description")
+			}
+			tsresol := byte(pcapngDefaultTsresol)
+			pr.options(body[8:], func(code uint16, value []byte) {
+				if code == pcapngOptIfTsresol && len(value) == 1 {
+					tsresol = value[0]
+				}
+			})
+			unitsPerSecond, err := pcapngUnitsPerSecond(tsresol)
+			if err != nil {
+				return Record{}, err
+			}
+			pr.interfaces = append(pr.interfaces, pcapngInterfaceInfo{linkType: pr.order.Uint16(body), unitsPerSecond: unitsPerSecond})
+		case pcapngEnhancedPacket:
+			rec, ok, err := pr.enhancedPacket(body)
+			if err != nil || ok {
+				return rec, err
+			}
+		case pcapngSimplePacket:
+			if len(pr.interfaces) == 0 || pr.interfaces[0].linkType != LinkTypeESP3 || len(body) < 4 {
+				continue
+			}
+			n := min(int(pr.order.Uint32(body)), len(body)-4)
+			return Record{Direction: DirectionRx, Raw: append([]byte(nil), body[4:4+n]...)}, nil
+		}
+	}
+}
+
+// enhancedPacket decodes an enhanced packet block body and reports whether
+// it belongs to an ESP3 interface.
+func (pr *pcapngReader) enhancedPacket(body []byte) (Record, bool, error) {
+	if len(body) < 20 {
+		return Record{}, false, errors.New("pcapng: short packet block")
+	}
+	ifID := pr.order.Uint32(body)
+	if int(ifID) >= len(pr.interfaces) {
+		return Record{}, false, fmt.Errorf("pcapng: packet of undeclared interface %d", ifID)
+	}
+	info := pr.interfaces[ifID]
+	if info.linkType != LinkTypeESP3 {
+		return Record{}, false, nil
+	}
+
+	ts := uint64(pr.order.Uint32(body[4:]))<<32 | uint64(pr.order.Uint32(body[8:]))
+	capLen := int(pr.order.Uint32(body[12:]))
+	dataEnd := 20 + capLen + (-capLen & 3)
+	if capLen < 0 || dataEnd > len(body) {
+		return Record{}, false, errors.New("pcapng: packet data exceeds block")
+	}
+
+	rec := Record{Time: pcapngTime(ts, info.unitsPerSecond), Direction: DirectionRx, Raw: append([]byte(nil), body[20:20+capLen]...)}
+	pr.options(body[dataEnd:], func(code uint16, value []byte) {
+		switch {
+		case code == pcapngOptEpbFlags && len(value) == 4:
+			flags := pr.order.Uint32(value)
+			if flags&pcapngFlagDirection == pcapngFlagOutbound {
+				rec.Direction = DirectionTx
+			}
+			if flags&pcapngFlagCRCError != 0 {
+				rec.Direction = DirectionRejected
+			}
+		case code == pcapngOptComment:
+			rec.Note = string(value)
+		}
+	})
+	return rec, true, nil
+}
+
+// readBlock reads the next block, switching byte order at section headers.
+func (pr *pcapngReader) readBlock() (uint32, []byte, error) {
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(pr.r, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("pcapng: truncated block")
+		}
+		return 0, nil, err
+	}
+
+	if binary.LittleEndian.Uint32(head) == pcapngSectionHeader {
+		magic, err := pr.r.Peek(4)
+		if err != nil {
+			return 0, nil, errors.New("pcapng: truncated section header")
+		}
+		switch {
+		case binary.LittleEndian.Uint32(magic) == pcapngByteOrderMagic:
+			pr.order = binary.LittleEndian
+		case binary.BigEndian.Uint32(magic) == pcapngByteOrderMagic:
+			pr.order = binary.BigEndian
+		default:
+			return 0, nil, errors.New("pcapng: invalid byte-order magic")
+		}
+	}
+	if pr.order == nil {
+		return 0, nil, errors.New("pcapng: missing section header")
+	}
+
+	blockType, total := pr.order.Uint32(head), pr.order.Uint32(head[4:])
+	if total < pcapngBlockFramingLen || total%4 != 0 || total > pcapngMaxBlockSize {
+		return 0, nil, fmt.Errorf("pcapng: invalid block length %d", total)
+	}
+	rest := make([]byte, total-8)
+	if _, err := io.ReadFull(pr.r, rest); err != nil {
+		return 0, nil, errors.New("pcapng: truncated block")
+	}
+	return blockType, rest[:len(rest)-4], nil
+}
+
+// options calls f for every option in b.
+func (pr *pcapngReader) options(b []byte, f func(code uint16, value []byte)) {
+	for len(b) >= 4 {
+		code, n := pr.order.Uint16(b), int(pr.order.Uint16(b[2:]))
+		if code == pcapngOptEnd || 4+n > len(b) {
+			return
+		}
+		f(code, b[4:4+n])
+		b = b[min(len(b), 4+n+(-n&3)):]
+	}
+}
+
+// pcapngUnitsPerSecond returns the timestamp units per second of an
+// if_tsresol value, a power of 10 or, with the top bit set, of 2.
+func pcapngUnitsPerSecond(tsresol byte) (uint64, error) {
+	const maxDecimalExponent = 19 // 10^19 < 2^64 < 10^20
+
+	exponent := tsresol & 0x7f
+	if tsresol&0x80 != 0 {
+		if exponent >= 64 {
+			return 0, fmt.Errorf("pcapng: if_tsresol %#02x overflows 64 bits", tsresol)
+		}
+		return 1 << exponent, nil
+	}
+	if exponent > maxDecimalExponent {
+		return 0, fmt.Errorf("pcapng: if_tsresol %#02x overflows 64 bits", tsresol)
+	}
+	var unitsPerSecond uint64 = 1
+	for range exponent {
+		unitsPerSecond *= 10
+	}
+	return unitsPerSecond, nil
+}
+
+// pcapngTime converts a timestamp counted in units per second to a time.
+func pcapngTime(ts, unitsPerSecond uint64) time.Time {
+	sec := ts / unitsPerSecond
+	// The remainder is below unitsPerSecond, so the quotient is below a
+	// second and fits in 64 bits.
+	hi, lo := bits.Mul64(ts%unitsPerSecond, uint64(time.Second))
+	nsec, _ := bits.Div64(hi, lo, unitsPerSecond)
+	return time.Unix(int64(sec), int64(nsec)).UTC()
+}
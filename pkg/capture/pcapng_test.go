@@ -0,0 +1,231 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestPcapngRoundTrip verifies PcapngRoundTrip behavior.
+func TestPcapngRoundTrip(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 123456789, time.UTC)
+	response := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil)
+	command := esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{0x08}, nil)
+	want := []Record{
+		{Time: start, Direction: DirectionRx, Raw: response.Serialize()},
+		{Time: start.Add(time.Millisecond), Direction: DirectionTx, Raw: command.Serialize()},
+		{Time: start.Add(2 * time.Millisecond), Direction: DirectionRejected, Raw: []byte{0x55, 0x00, 0x01}, Note: "CRC8H mismatch"},
+		{Time: start.Add(3 * time.Millisecond), Direction: DirectionRejected, Note: "resync: skipped 2 bytes"},
+	}
+
+	var file bytes.Buffer
+	rec, err := NewPcapngRecorder(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range want {
+		if err := rec.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := file.Bytes()
+	if binary.LittleEndian.Uint32(data) != pcapngSectionHeader || binary.LittleEndian.Uint32(data[8:]) != pcapngByteOrderMagic {
+		t.Fatalf("section header = % x", data[:12])
+	}
+
+	r, err := NewPcapngReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Record
+	for record, err := range r.Records() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(record.Raw) == 0 {
+			record.Raw = nil
+		}
+		got = append(got, record)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("records = %+v, want %+v", got, want)
+	}
+}
+
+// TestPcapngWriterRejectsOutOfRangeTimes verifies PcapngWriterRejectsOutOfRangeTimes behavior.
+func TestPcapngWriterRejectsOutOfRangeTimes(t *testing.T) {
+	var file bytes.Buffer
+	pw, err := NewPcapngWriter(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := file.Len()
+	for _, ts := range []time.Time{
+		{},
+		time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		if err := pw.WriteRecord(Record{Time: ts, Direction: DirectionRx, Raw: []byte{0x55}}); err == nil {
+			t.Errorf("time %s: err = nil", ts)
+		}
+	}
+	if file.Len() != header {
+		t.Fatalf("wrote %d bytes for rejected records", file.Len()-header)
+	}
+
+	epoch := time.Unix(0, 0).UTC()
+	if err := pw.WriteRecord(Record{Time: epoch, Direction: DirectionRx, Raw: []byte{0x55}}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewPcapngReader(bytes.NewReader(file.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rec, err := range r.Records() {
+		if err != nil || !rec.Time.Equal(epoch) {
+			t.Fatalf("record time = %s, err = %v", rec.Time, err)
+		}
+	}
+}
+
+// TestPcapngReaderSkipsForeignInterfaces verifies PcapngReaderSkipsForeignInterfaces behavior.
+func TestPcapngReaderSkipsForeignInterfaces(t *testing.T) {
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+
+	// A big-endian section with an Ethernet interface at microsecond
+	// resolution, then an ESP3 interface without options.
+	b := appendBigEndianBlock(nil, pcapngSectionHeader, bigEndianSectionHeader)
+	b = appendBigEndianBlock(b, pcapngInterface, []byte{0, 1, 0, 0, 0, 0, 0, 0})
+	b = appendBigEndianBlock(b, pcapngInterface, []byte{0, LinkTypeESP3, 0, 0, 0, 0, 0, 0})
+	b = appendBigEndianBlock(b, pcapngEnhancedPacket, bigEndianPacket(0, 1, []byte{0xde, 0xad}))
+	b = appendBigEndianBlock(b, pcapngEnhancedPacket, bigEndianPacket(1, 1_500_000, frame))
+
+	r, err := NewPcapngReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rec.Raw, frame) || rec.Direction != DirectionRx || !rec.Time.Equal(time.Unix(1, 500_000_000)) {
+		t.Fatalf("record = %+v", rec)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("err = %v, want EOF", err)
+	}
+}
+
+// TestPcapngReaderTsresol verifies PcapngReaderTsresol behavior.
+func TestPcapngReaderTsresol(t *testing.T) {
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+	read := func(tsresol byte, ts uint64) (Record, error) {
+		idb := []byte{0, LinkTypeESP3, 0, 0, 0, 0, 0, 0, 0, pcapngOptIfTsresol, 0, 1, tsresol, 0, 0, 0}
+		b := appendBigEndianBlock(nil, pcapngSectionHeader, bigEndianSectionHeader)
+		b = appendBigEndianBlock(b, pcapngInterface, idb)
+		b = appendBigEndianBlock(b, pcapngEnhancedPacket, bigEndianPacket(0, ts, frame))
+		r, err := NewPcapngReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Next()
+	}
+
+	for _, tc := range []struct {
+		tsresol byte
+		ts      uint64
+	}{
+		{9, 1_500_000_000},
+		{19, 15_000_000_000_000_000_000},
+		{0x80 | 63, 3 << 62},
+	} {
+		rec, err := read(tc.tsresol, tc.ts)
+		if err != nil || !rec.Time.Equal(time.Unix(1, 500_000_000)) {
+			t.Errorf("if_tsresol %#02x: time = %s, err = %v", tc.tsresol, rec.Time, err)
+		}
+	}
+	for _, tsresol := range []byte{20, 0x80 | 64, 0xff} {
+		if _, err := read(tsresol, 1); err == nil || err == io.EOF {
+			t.Errorf("if_tsresol %#02x: err = %v", tsresol, err)
+		}
+	}
+}
+
+// TestPcapngFilesReplay verifies PcapngFilesReplay behavior.
+func TestPcapngFilesReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump.PCAPNG")
+	rec, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+	rec.Frame(frame)
+	rec.Written([]byte{0x55})
+	rec.Frame(frame)
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := OpenReplay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer replay.Close()
+	got, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, append(append([]byte(nil), frame...), frame...)) {
+		t.Fatalf("replayed % x", got)
+	}
+}
+
+// TestNewPcapngReaderRejectsForeignFiles verifies NewPcapngReaderRejectsForeignFiles behavior.
+func TestNewPcapngReaderRejectsForeignFiles(t *testing.T) {
+	for _, doc := range []string{"", `{"format":"esp3-capture","version":1}`, "\xd4\xc3\xb2\xa1"} {
+		if _, err := NewPcapngReader(strings.NewReader(doc)); err == nil {
+			t.Fatalf("NewPcapngReader(%q) succeeded", doc)
+		}
+	}
+
+	truncated := []byte{0x0a, 0x0d, 0x0d, 0x0a, 0x1c, 0, 0, 0, 0x4d, 0x3c, 0x2b, 0x1a}
+	r, err := NewPcapngReader(bytes.NewReader(truncated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || err == io.EOF {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// bigEndianSectionHeader is the body of a big-endian section header block
+// of unknown length.
+var bigEndianSectionHeader = []byte{0x1a, 0x2b, 0x3c, 0x4d, 0, 1, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// appendBigEndianBlock appends a big-endian pcapng block to b.
+func appendBigEndianBlock(b []byte, blockType uint32, body []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, blockType)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)+12))
+	b = append(b, body...)
+	return binary.BigEndian.AppendUint32(b, uint32(len(body)+12))
+}
+
+// bigEndianPacket returns the body of a big-endian enhanced packet block.
+func bigEndianPacket(ifID uint32, ts uint64, data []byte) []byte {
+	var p []byte
+	p = binary.BigEndian.AppendUint32(p, ifID)
+	p = binary.BigEndian.AppendUint32(p, uint32(ts>>32))
+	p = binary.BigEndian.AppendUint32(p, uint32(ts))
+	p = binary.BigEndian.AppendUint32(p, uint32(len(data)))
+	p = binary.BigEndian.AppendUint32(p, uint32(len(data)))
+	return append(p, append(data, make([]byte, -len(data)&3)...)...)
+}
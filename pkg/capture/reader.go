@@ -0,0 +1,141 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// maxLineSize bounds a capture line; the largest ESP3 frame is below 64 KiB,
+// i.e. 128 KiB of hex.
+const maxLineSize = 256 << 10
+
+// Reader reads the records of a capture file.
+type Reader struct {
+	// Started is when the recording started, if the format tells.
+	Started time.Time
+
+	next   func() (Record, error)
+	closer io.Closer
+}
+
+// NewReader reads the JSON lines capture header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty capture")
+	}
+
+	var h header
+	if err := json.Unmarshal(scanner.Bytes(), &h); err != nil || h.Format != formatName {
+		return nil, errors.New("not an ESP3 capture")
+	}
+	if h.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported capture version %d", h.Version)
+	}
+
+	line := 1
+	next := func() (Record, error) {
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+			var rec Record
+			if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+				return Record{}, fmt.Errorf("line %d: %w", line, err)
+			}
+			return rec, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return Record{}, err
+		}
+		return Record{}, io.EOF
+	}
+	return &Reader{Started: h.Started, next: next}, nil
+}
+
+// Open opens the capture file path, in pcapng when path ends in .pcapng
+// and in JSON lines otherwise. Close closes it.
+func Open(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	newReader := NewReader
+	if isPcapng(path) {
+		newReader = NewPcapngReader
+	}
+	r, err := newReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Close closes the file opened by Open.
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Next returns the next record, or io.EOF after the last one.
+func (r *Reader) Next() (Record, error) {
+	return r.next()
+}
+
+// Records iterates over the remaining records. Iteration stops after an
+// error is yielded.
+func (r *Reader) Records() iter.Seq2[Record, error] {
+	return func(yield func(Record, error) bool) {
+		for {
+			rec, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if !yield(rec, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Telegrams iterates over the frames of the remaining rx and tx records,
+// skipping rejected input. A frame that does not decode yields an error
+// and iteration continues; a broken file stops it.
+func (r *Reader) Telegrams() iter.Seq2[esp3.Telegram, error] {
+	return func(yield func(esp3.Telegram, error) bool) {
+		for rec, err := range r.Records() {
+			if err != nil {
+				yield(esp3.Telegram{}, err)
+				return
+			}
+			if rec.Direction == DirectionRejected {
+				continue
+			}
+			t, err := rec.Telegram()
+			if err != nil {
+				err = fmt.Errorf("record at %s: %w", rec.Time.Format(time.RFC3339Nano), err)
+			}
+			if !yield(t, err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+)
+
+// RecorderConfig tunes a Recorder. Zero fields keep their defaults.
+type RecorderConfig struct {
+	// Rejected also records CRC failures, resyncs and inter-byte timeouts.
+	Rejected bool
+
+	// Now stamps the records; defaults to time.Now.
+	Now func() time.Time
+}
+
+// Recorder writes a capture file. It implements pkg.Hooks, so it records
+// a session or parser it is installed on; combine it with other hooks with
+// pkg.MultiHooks. It is safe for concurrent use.
+type Recorder struct {
+	pkg.NopHooks
+
+	rejected bool
+	now      func() time.Time
+	closer   io.Closer
+
+	mu     sync.Mutex
+	encode func(Record) error
+	err    error
+}
+
+// newRecorder constructs a Recorder without an encoding.
+func newRecorder(cfg []RecorderConfig) *Recorder {
+	r := &Recorder{now: time.Now}
+	for _, o := range cfg {
+		r.rejected = r.rejected || o.Rejected
+		if o.Now != nil {
+			r.now = o.Now
+		}
+	}
+	return r
+}
+
+// NewRecorder writes the JSON lines capture header to w and returns a
+// Recorder appending records to it.
+func NewRecorder(w io.Writer, cfg ...RecorderConfig) (*Recorder, error) {
+	r := newRecorder(cfg)
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	r.encode = func(rec Record) error {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+
+	if err := enc.Encode(header{Format: formatName, Version: formatVersion, Started: r.now().UTC()}); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewPcapngRecorder writes a pcapng section to w and returns a Recorder
+// appending records to it as packets.
+func NewPcapngRecorder(w io.Writer, cfg ...RecorderConfig) (*Recorder, error) {
+	r := newRecorder(cfg)
+	pw, err := NewPcapngWriter(w)
+	if err != nil {
+		return nil, err
+	}
+	r.encode = pw.WriteRecord
+	return r, nil
+}
+
+// Create creates the capture file path and returns a Recorder writing to
+// it, in pcapng when path ends in .pcapng and in JSON lines otherwise.
+// Close closes the file.
+func Create(path string, cfg ...RecorderConfig) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	newRec := NewRecorder
+	if isPcapng(path) {
+		newRec = NewPcapngRecorder
+	}
+	r, err := newRec(f, cfg...)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	r.closer = f
+	return r, nil
+}
+
+// isPcapng reports whether path names a pcapng file.
+func isPcapng(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".pcapng")
+}
+
+// Record appends rec. Records are flushed immediately, so a capture stays
+// readable if the process dies.
+func (r *Recorder) Record(rec Record) error {
+	rec.Time = rec.Time.UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	r.err = r.encode(rec)
+	return r.err
+}
+
+// Err returns the first error that stopped the recording.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Close closes the file opened by Create and returns the first recording
+// error. It does not close writers passed to NewRecorder.
+func (r *Recorder) Close() error {
+	err := r.Err()
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Frame records a received frame.
+func (r *Recorder) Frame(frame []byte) {
+	r.Record(Record{Time: r.now(), Direction: DirectionRx, Raw: frame})
+}
+
+// Written records a frame written to the transceiver.
+func (r *Recorder) Written(frame []byte) {
+	r.Record(Record{Time: r.now(), Direction: DirectionTx, Raw: frame})
+}
+
+// CRCError records the bytes dropped with a frame that failed a checksum,
+// sync byte included.
+func (r *Recorder) CRCError(err pkg.CRCError) {
+	if r.rejected {
+		r.Record(Record{Time: r.now(), Direction: DirectionRejected, Raw: err.Dropped, Note: err.Error()})
+	}
+}
+
+// Resync records the bytes skipped before a sync byte.
+func (r *Recorder) Resync(skipped []byte) {
+	if r.rejected {
+		r.Record(Record{Time: r.now(), Direction: DirectionRejected, Raw: skipped, Note: fmt.Sprintf("resync: skipped %d bytes", len(skipped))})
+	}
+}
+
+// InterByteTimeout records an abandoned partial frame.
+func (r *Recorder) InterByteTimeout(pending []byte) {
+	if r.rejected {
+		r.Record(Record{Time: r.now(), Direction: DirectionRejected, Raw: pending, Note: fmt.Sprintf("inter-byte timeout: dropped %d bytes", len(pending))})
+	}
+}
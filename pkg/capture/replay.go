@@ -0,0 +1,160 @@
+package capture
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultSpeed is the speed-up of PaceAccelerated unless configured.
+const defaultSpeed = 10
+
+// Pace selects how fast a Replay delivers the recorded frames.
+type Pace uint8
+
+const (
+	// PaceASAP delivers frames as fast as they are read.
+	PaceASAP Pace = iota
+	// PaceRealtime keeps the recorded gaps between frames.
+	PaceRealtime
+	// PaceAccelerated divides the recorded gaps by ReplayConfig.Speed.
+	PaceAccelerated
+)
+
+// String returns the string representation of Pace.
+func (pace Pace) String() string {
+	switch pace {
+	case PaceASAP:
+		return "ASAP"
+	case PaceRealtime:
+		return "REALTIME"
+	case PaceAccelerated:
+		return "ACCELERATED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ReplayConfig tunes a Replay. Zero fields keep their defaults.
+type ReplayConfig struct {
+	// Pace defaults to PaceASAP.
+	Pace Pace
+
+	// Speed is the speed-up of PaceAccelerated. Defaults to 10.
+	Speed float64
+
+	// Rejected also feeds the bytes of rejected records, so the parser
+	// meets the same corrupted input as it did live: a capture recorded
+	// with RecorderConfig.Rejected holds every received byte. Inter-byte
+	// timeouts depend on the gap before the next byte, so they only recur
+	// when the pace keeps that gap above the parser's timeout.
+	Rejected bool
+}
+
+// Replay is a pkg.Transport that reads the received frames of a capture,
+// so sessions and parsers run on recorded traffic exactly as they did live.
+// Writes are accepted and discarded. Read returns io.EOF after the last
+// frame.
+type Replay struct {
+	reader *Reader
+	cfg    ReplayConfig
+
+	pending  []byte
+	lastTime time.Time
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// NewReplay constructs a Replay reading the records of r.
+func NewReplay(r *Reader, cfg ...ReplayConfig) *Replay {
+	c := ReplayConfig{Speed: defaultSpeed}
+	for _, o := range cfg {
+		c.Pace = o.Pace
+		if o.Speed > 0 {
+			c.Speed = o.Speed
+		}
+		c.Rejected = c.Rejected || o.Rejected
+	}
+	return &Replay{reader: r, cfg: c, closed: make(chan struct{})}
+}
+
+// OpenReplay opens the capture file path for replay. Close closes it.
+func OpenReplay(path string, cfg ...ReplayConfig) (*Replay, error) {
+	r, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplay(r, cfg...), nil
+}
+
+// Read returns the bytes of the next frame, waiting out the recorded gap
+// before it when paced.
+func (r *Replay) Read(b []byte) (int, error) {
+	for len(r.pending) == 0 {
+		select {
+		case <-r.closed:
+			return 0, io.ErrClosedPipe
+		default:
+		}
+
+		rec, err := r.reader.Next()
+		if err != nil {
+			return 0, err
+		}
+		if rec.Direction == DirectionTx || (rec.Direction == DirectionRejected && !r.cfg.Rejected) {
+			continue
+		}
+		if err := r.wait(rec.Time); err != nil {
+			return 0, err
+		}
+		r.pending = rec.Raw
+	}
+
+	n := copy(b, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// wait sleeps for the recorded gap between the previous frame and t.
+func (r *Replay) wait(t time.Time) error {
+	last := r.lastTime
+	r.lastTime = t
+	if r.cfg.Pace == PaceASAP || last.IsZero() || !t.After(last) {
+		return nil
+	}
+
+	gap := t.Sub(last)
+	if r.cfg.Pace == PaceAccelerated {
+		gap = time.Duration(float64(gap) / r.cfg.Speed)
+	}
+	timer := time.NewTimer(gap)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.closed:
+		return io.ErrClosedPipe
+	}
+}
+
+// Write discards b.
+func (r *Replay) Write(b []byte) (int, error) {
+	select {
+	case <-r.closed:
+		return 0, io.ErrClosedPipe
+	default:
+		return len(b), nil
+	}
+}
+
+// Close stops the replay, interrupting a pending Read, and closes the file
+// opened by OpenReplay.
+func (r *Replay) Close() error {
+	var err error
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		err = r.reader.Close()
+	})
+	return err
+}
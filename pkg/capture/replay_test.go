@@ -0,0 +1,187 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+var _ pkg.Transport = (*Replay)(nil)
+
+// writeCapture records records into a capture and returns a Reader on it.
+func writeCapture(t *testing.T, records ...Record) *Reader {
+	t.Helper()
+	var file bytes.Buffer
+	rec, err := NewRecorder(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := rec.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := NewReader(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// TestReplayFeedsSession verifies ReplayFeedsSession behavior.
+func TestReplayFeedsSession(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	rocker := erp1.Packet{DestinationID: deviceid.BroadcastId(), Rorg: enums.RorgRPS, SenderID: 0x0100000a, UserData: []byte{0x10}, Status: 0x30}
+	event := esp3.NewTelegramFromData(enums.PacketTypeEVENT, []byte{byte(enums.EventCodeCO_READY), 0x00}, []byte{0x00})
+	replay := NewReplay(writeCapture(t,
+		Record{Time: start, Direction: DirectionTx, Raw: esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{0x03}, nil).Serialize()},
+		Record{Time: start, Direction: DirectionRx, Raw: rocker.Serialize()},
+		Record{Time: start, Direction: DirectionRejected, Raw: []byte{0x55, 0x00}, Note: "CRC8H mismatch"},
+		Record{Time: start.Add(time.Hour), Direction: DirectionRx, Raw: event.Serialize()},
+	))
+
+	s := pkg.NewSession(context.Background(), replay)
+	defer s.Close()
+
+	select {
+	case p := <-s.Channels.ERP1:
+		if p.SenderID != rocker.SenderID {
+			t.Fatalf("packet = %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no ERP1 packet")
+	}
+	select {
+	case e := <-s.Channels.Event:
+		if e.Description() != enums.EventCodeCO_READY {
+			t.Fatalf("event = %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+}
+
+// TestReplayPacing verifies ReplayPacing behavior.
+func TestReplayPacing(t *testing.T) {
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		cfg      ReplayConfig
+		gap      time.Duration
+		min, max time.Duration
+	}{
+		{"asap", ReplayConfig{}, time.Hour, 0, 50 * time.Millisecond},
+		{"realtime", ReplayConfig{Pace: PaceRealtime}, 80 * time.Millisecond, 80 * time.Millisecond, time.Second},
+		{"accelerated", ReplayConfig{Pace: PaceAccelerated, Speed: 20}, 2 * time.Second, 100 * time.Millisecond, time.Second},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			replay := NewReplay(writeCapture(t,
+				Record{Time: start, Direction: DirectionRx, Raw: frame},
+				Record{Time: start.Add(tc.gap), Direction: DirectionRx, Raw: frame},
+			), tc.cfg)
+			defer replay.Close()
+
+			buf := make([]byte, 64)
+			began := time.Now()
+			for range 2 {
+				if n, err := replay.Read(buf); err != nil || n != len(frame) {
+					t.Fatalf("read = %d, %v", n, err)
+				}
+			}
+			if elapsed := time.Since(began); elapsed < tc.min || elapsed > tc.max {
+				t.Fatalf("elapsed = %s, want %s..%s", elapsed, tc.min, tc.max)
+			}
+		})
+	}
+}
+
+// TestReplayRejectedInputAndClose verifies ReplayRejectedInputAndClose behavior.
+func TestReplayRejectedInputAndClose(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+	corrupted := bytes.Clone(frame)
+	corrupted[len(corrupted)-1] ^= 0xff
+	replay := NewReplay(writeCapture(t,
+		Record{Time: start, Direction: DirectionRejected, Raw: corrupted},
+		Record{Time: start, Direction: DirectionRx, Raw: frame},
+		Record{Time: start.Add(time.Hour), Direction: DirectionRx, Raw: frame},
+	), ReplayConfig{Rejected: true, Pace: PaceRealtime})
+
+	var again bytes.Buffer
+	rec, _ := NewRecorder(&again, RecorderConfig{Rejected: true})
+	channels := pkg.StartParser(context.Background(), replay, pkg.ParserConfig{Hooks: rec})
+	<-channels.Response
+	if err := replay.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for range channels.All {
+	}
+
+	if !strings.Contains(again.String(), `"note":"CRC8D mismatch`) || strings.Count(again.String(), `"dir":"rx"`) != 1 {
+		t.Fatalf("re-recorded capture = %s", again.String())
+	}
+	if _, err := replay.Write([]byte{0x55}); err == nil {
+		t.Fatal("write after close succeeded")
+	}
+}
+
+// TestReplayRejectedReproducesLiveStream verifies ReplayRejectedReproducesLiveStream behavior.
+func TestReplayRejectedReproducesLiveStream(t *testing.T) {
+	frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00}, nil).Serialize()
+	badData := bytes.Clone(frame)
+	badData[len(badData)-1] ^= 0xff
+	badHeader := bytes.Clone(frame)
+	badHeader[2] = 0x55 // the parser resumes on this sync byte
+	live := bytes.Join([][]byte{{0x01, 0x02}, badData, badHeader, {0x03}, frame}, nil)
+
+	record := func(r io.Reader) (*bytes.Buffer, []string) {
+		var file bytes.Buffer
+		rec, _ := NewRecorder(&file, RecorderConfig{Rejected: true})
+		channels := pkg.StartParser(context.Background(), r, pkg.ParserConfig{Hooks: rec})
+		for range channels.All {
+		}
+		reader, err := NewReader(bytes.NewReader(file.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var notes []string
+		for record, err := range reader.Records() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			notes = append(notes, record.Direction.String()+" "+record.Note)
+		}
+		return &file, notes
+	}
+
+	captured, liveNotes := record(bytes.NewReader(live))
+	reader, err := NewReader(bytes.NewReader(captured.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := io.ReadAll(NewReplay(reader, ReplayConfig{Rejected: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(replayed, live) {
+		t.Fatalf("replayed % x, want % x", replayed, live)
+	}
+
+	reader, _ = NewReader(bytes.NewReader(captured.Bytes()))
+	if _, notes := record(NewReplay(reader, ReplayConfig{Rejected: true})); !reflect.DeepEqual(notes, liveNotes) {
+		t.Fatalf("replayed records %q, want %q", notes, liveNotes)
+	}
+}
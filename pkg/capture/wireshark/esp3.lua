@@ -0,0 +1,230 @@
+-- Wireshark dissector for ESP3 captures written by capture.PcapngWriter.
+--
+-- The captures use link type LINKTYPE_USER0 (147), one ESP3 frame per packet.
+-- Copy this file to the Wireshark personal plugins folder (Help > About >
+-- Folders), or load it once with: wireshark -X lua_script:esp3.lua dump.pcapng
+
+local esp3 = Proto("esp3", "EnOcean Serial Protocol 3")
+
+local packet_types = {
+	[0x01] = "RADIO_ERP1",
+	[0x02] = "RESPONSE",
+	[0x03] = "RADIO_SUB_TEL",
+	[0x04] = "EVENT",
+	[0x05] = "COMMON_COMMAND",
+	[0x06] = "SMART_ACK_COMMAND",
+	[0x07] = "REMOTE_MAN_COMMAND",
+	[0x09] = "RADIO_MESSAGE",
+	[0x0a] = "RADIO_ERP2",
+	[0x0b] = "CONFIG_COMMAND",
+	[0x0c] = "COMMAND_ACCEPTED",
+	[0x10] = "RADIO_802_15_4",
+	[0x11] = "COMMAND_2_4",
+}
+
+local rorgs = {
+	[0xf6] = "RPS",
+	[0xd5] = "1BS",
+	[0xa5] = "4BS",
+	[0xd2] = "VLD",
+	[0xd1] = "MSC",
+	[0xa6] = "ADT",
+	[0xb0] = "GP_TI",
+	[0xb1] = "GP_TR",
+	[0xb2] = "GP_CD",
+	[0xb3] = "GP_SD",
+	[0xc6] = "SM_LRN_REQ",
+	[0xc7] = "SM_LRN_ANS",
+	[0xa7] = "SM_REC",
+	[0xc5] = "SYS_EX",
+	[0x30] = "SEC",
+	[0x31] = "SEC_R",
+	[0x32] = "SEC_D",
+	[0x33] = "SEC_CDM",
+	[0x34] = "SEC_MAN",
+	[0x35] = "SEC_TI",
+	[0xd0] = "SIGNAL",
+	[0xd4] = "UTE",
+}
+
+local return_codes = {
+	[0x00] = "SUCCESS",
+	[0x01] = "ERROR",
+	[0x02] = "NOT_SUPPORTED",
+	[0x03] = "WRONG_ARGUMENT",
+	[0x04] = "OPERATION_DENIED",
+	[0x05] = "LOCK_SET",
+	[0x06] = "BUFFER_TO_SMALL",
+	[0x07] = "NO_FREE_BUFFER",
+	[0x90] = "BASEID_OUT_OF_RANGE",
+	[0x91] = "BASEID_MAX_REACHED",
+}
+
+local event_codes = {
+	[0x01] = "SA_RECLAIM_NOT_SUCCESSFUL",
+	[0x02] = "SA_CONFIRM_LEARN",
+	[0x03] = "SA_LEARN_ACK",
+	[0x04] = "CO_READY",
+	[0x05] = "CO_EVENT_SECUREDEVICES",
+	[0x06] = "CO_DUTYCYCLE_LIMIT",
+	[0x07] = "CO_TRANSMIT_FAILED",
+	[0x08] = "CO_TX_DONE",
+	[0x09] = "CO_LRN_MODE_DISABLED",
+}
+
+local f = {
+	sync = ProtoField.uint8("esp3.sync", "Sync byte", base.HEX),
+	data_length = ProtoField.uint16("esp3.data_length", "Data length", base.DEC),
+	optional_length = ProtoField.uint8("esp3.optional_length", "Optional length", base.DEC),
+	packet_type = ProtoField.uint8("esp3.packet_type", "Packet type", base.HEX, packet_types),
+	crc8h = ProtoField.uint8("esp3.crc8h", "CRC8H", base.HEX),
+	data = ProtoField.bytes("esp3.data", "Data"),
+	optional = ProtoField.bytes("esp3.optional", "Optional data"),
+	crc8d = ProtoField.uint8("esp3.crc8d", "CRC8D", base.HEX),
+
+	rorg = ProtoField.uint8("esp3.erp1.rorg", "RORG", base.HEX, rorgs),
+	user_data = ProtoField.bytes("esp3.erp1.user_data", "User data"),
+	sender = ProtoField.uint32("esp3.erp1.sender", "Sender ID", base.HEX),
+	status = ProtoField.uint8("esp3.erp1.status", "Status", base.HEX),
+	repeater = ProtoField.uint8("esp3.erp1.repeater", "Repeater count", base.DEC, nil, 0x0f),
+	subtel = ProtoField.uint8("esp3.erp1.subtel", "Subtelegrams", base.DEC),
+	destination = ProtoField.uint32("esp3.erp1.destination", "Destination ID", base.HEX),
+	dbm = ProtoField.int16("esp3.erp1.dbm", "RSSI (dBm)", base.DEC),
+	security = ProtoField.uint8("esp3.erp1.security", "Security level", base.DEC),
+
+	return_code = ProtoField.uint8("esp3.response.return_code", "Return code", base.HEX, return_codes),
+	event_code = ProtoField.uint8("esp3.event.code", "Event code", base.HEX, event_codes),
+	command_code = ProtoField.uint8("esp3.command.code", "Command code", base.HEX),
+}
+esp3.fields = {
+	f.sync, f.data_length, f.optional_length, f.packet_type, f.crc8h, f.data, f.optional, f.crc8d,
+	f.rorg, f.user_data, f.sender, f.status, f.repeater, f.subtel, f.destination, f.dbm, f.security,
+	f.return_code, f.event_code, f.command_code,
+}
+
+local ef_bad_crc = ProtoExpert.new("esp3.bad_crc", "Checksum mismatch", expert.group.CHECKSUM, expert.severity.ERROR)
+local ef_short = ProtoExpert.new("esp3.short", "Truncated frame", expert.group.MALFORMED, expert.severity.ERROR)
+esp3.experts = { ef_bad_crc, ef_short }
+
+-- xor8 returns a XOR b for bytes, without relying on the Lua version's
+-- bitwise operators.
+local function xor8(a, b)
+	local r, bit = 0, 1
+	for _ = 1, 8 do
+		if (a % 2) ~= (b % 2) then
+			r = r + bit
+		end
+		a, b, bit = math.floor(a / 2), math.floor(b / 2), bit * 2
+	end
+	return r
+end
+
+-- CRC8 with polynomial x^8 + x^2 + x + 1, as in the ESP3 specification.
+local crc_table = {}
+for i = 0, 255 do
+	local c = i
+	for _ = 1, 8 do
+		if c >= 0x80 then
+			c = xor8((c * 2) % 256, 0x07)
+		else
+			c = c * 2
+		end
+	end
+	crc_table[i] = c
+end
+
+local function crc8(tvb, offset, length)
+	local c = 0
+	for i = offset, offset + length - 1 do
+		c = crc_table[xor8(c, tvb(i, 1):uint())]
+	end
+	return c
+end
+
+local function check_crc(item, tvb, offset, length, crc)
+	if crc8(tvb, offset, length) ~= crc then
+		item:add_proto_expert_info(ef_bad_crc)
+	end
+end
+
+local function dissect_erp1(tvb, data, optional, tree, pinfo)
+	local len = data:len()
+	if len < 6 then
+		return
+	end
+	local rorg = data(0, 1):uint()
+	tree:add(f.rorg, data(0, 1))
+	if len > 6 then
+		tree:add(f.user_data, data(1, len - 6))
+	end
+	tree:add(f.sender, data(len - 5, 4))
+	local status = tree:add(f.status, data(len - 1, 1))
+	status:add(f.repeater, data(len - 1, 1))
+
+	local info = string.format("%s from %08X", rorgs[rorg] or string.format("RORG 0x%02X", rorg), data(len - 5, 4):uint())
+	if optional and optional:len() >= 7 then
+		tree:add(f.subtel, optional(0, 1))
+		tree:add(f.destination, optional(1, 4))
+		local dbm = optional(5, 1):uint()
+		tree:add(f.dbm, optional(5, 1), -dbm)
+		tree:add(f.security, optional(6, 1))
+		info = string.format("%s, -%d dBm", info, dbm)
+	end
+	pinfo.cols.info:set(info)
+end
+
+function esp3.dissector(tvb, pinfo, root)
+	pinfo.cols.protocol:set("ESP3")
+	local tree = root:add(esp3, tvb())
+	if tvb:len() < 6 then
+		tree:add_proto_expert_info(ef_short)
+		return
+	end
+
+	local data_length = tvb(1, 2):uint()
+	local optional_length = tvb(3, 1):uint()
+	local packet_type = tvb(4, 1):uint()
+	tree:add(f.sync, tvb(0, 1))
+	tree:add(f.data_length, tvb(1, 2))
+	tree:add(f.optional_length, tvb(3, 1))
+	tree:add(f.packet_type, tvb(4, 1))
+	check_crc(tree:add(f.crc8h, tvb(5, 1)), tvb, 1, 4, tvb(5, 1):uint())
+
+	local name = packet_types[packet_type] or string.format("packet type 0x%02X", packet_type)
+	pinfo.cols.info:set(name)
+	tree:append_text(", " .. name)
+
+	if tvb:len() < 7 + data_length + optional_length then
+		tree:add_proto_expert_info(ef_short)
+		return
+	end
+	local data, optional
+	if data_length > 0 then
+		data = tvb(6, data_length)
+		tree:add(f.data, data)
+	end
+	if optional_length > 0 then
+		optional = tvb(6 + data_length, optional_length)
+		tree:add(f.optional, optional)
+	end
+	local crc_offset = 6 + data_length + optional_length
+	check_crc(tree:add(f.crc8d, tvb(crc_offset, 1)), tvb, 6, data_length + optional_length, tvb(crc_offset, 1):uint())
+	if not data then
+		return
+	end
+
+	if packet_type == 0x01 then
+		dissect_erp1(tvb, data, optional, tree, pinfo)
+	elseif packet_type == 0x02 then
+		tree:add(f.return_code, data(0, 1))
+		pinfo.cols.info:append(" " .. (return_codes[data(0, 1):uint()] or string.format("0x%02X", data(0, 1):uint())))
+	elseif packet_type == 0x04 then
+		tree:add(f.event_code, data(0, 1))
+		pinfo.cols.info:append(" " .. (event_codes[data(0, 1):uint()] or string.format("0x%02X", data(0, 1):uint())))
+	elseif packet_type == 0x05 or packet_type == 0x06 or packet_type == 0x0b then
+		tree:add(f.command_code, data(0, 1))
+		pinfo.cols.info:append(string.format(" 0x%02X", data(0, 1):uint()))
+	end
+end
+
+DissectorTable.get("wtap_encap"):add(wtap.USER0, esp3)
@@ -0,0 +1,49 @@
+// Package command24 parses and builds COMMAND_2_4 telegrams, which configure
+// the 2.4 GHz radio of a transceiver.
+package command24
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// Codes of the 2.4 GHz commands.
+const (
+	R802WrChannel byte = 0x01
+	R802RdChannel byte = 0x02
+)
+
+type Packet struct {
+	Code byte
+	Data []byte
+}
+
+// NewPacketFromEsp3 parses a 2.4 GHz command from an ESP3 telegram.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const codeOffset = 0
+
+	if telegram.PacketType != enums.PacketTypeCOMMAND_2_4 {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) == 0 {
+		return Packet{}, errors.New("data too short")
+	}
+
+	return Packet{
+		Code: telegram.Data[codeOffset],
+		Data: telegram.Data[codeOffset+1:],
+	}, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	return esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_2_4, append([]byte{p.Code}, p.Data...), nil)
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
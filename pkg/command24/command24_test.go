@@ -0,0 +1,35 @@
+package command24
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_2_4, []byte{R802WrChannel, 0x0f}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Packet{Code: R802WrChannel, Data: []byte{0x0f}}); !reflect.DeepEqual(p, want) {
+		t.Errorf("packet = %+v", p)
+	}
+
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCONFIG_COMMAND, []byte{0x01}, nil)); err == nil {
+		t.Error("config command accepted")
+	}
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_2_4, nil, nil)); err == nil {
+		t.Error("empty command accepted")
+	}
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	telegram := Packet{Code: R802RdChannel}.ToEsp3()
+	if telegram.PacketType != enums.PacketTypeCOMMAND_2_4 || !reflect.DeepEqual(telegram.Data, []byte{R802RdChannel}) {
+		t.Fatalf("telegram = %+v", telegram)
+	}
+}
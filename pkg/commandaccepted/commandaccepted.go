@@ -0,0 +1,51 @@
+// Package commandaccepted parses and builds COMMAND_ACCEPTED telegrams, with
+// which the transceiver confirms it took a command it carries out later.
+package commandaccepted
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// Responses tell whether the transceiver blocks until the command is done.
+const (
+	ResponseBlocking    byte = 0x00
+	ResponseNonBlocking byte = 0x01
+)
+
+type Packet struct {
+	Response    byte
+	CommandCode byte
+}
+
+// NewPacketFromEsp3 parses a command acceptance from an ESP3 telegram.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const dataLen = 2 // 1 response + 1 command code
+	const responseOffset = 0
+	const commandCodeOffset = 1
+
+	if telegram.PacketType != enums.PacketTypeCOMMAND_ACCEPTED {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) < dataLen {
+		return Packet{}, errors.New("data too short")
+	}
+
+	return Packet{
+		Response:    telegram.Data[responseOffset],
+		CommandCode: telegram.Data[commandCodeOffset],
+	}, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	return esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_ACCEPTED, []byte{p.Response, p.CommandCode}, nil)
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
@@ -0,0 +1,34 @@
+package commandaccepted
+
+import (
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_ACCEPTED, []byte{ResponseNonBlocking, byte(enums.CommonCommandWR_BIST)}, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != (Packet{Response: ResponseNonBlocking, CommandCode: byte(enums.CommonCommandWR_BIST)}) {
+		t.Errorf("packet = %+v", p)
+	}
+
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{0x00, 0x01}, nil)); err == nil {
+		t.Error("response accepted")
+	}
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_ACCEPTED, []byte{0x00}, nil)); err == nil {
+		t.Error("short packet accepted")
+	}
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	p := Packet{Response: ResponseBlocking, CommandCode: 0x03}
+	if parsed, err := NewPacketFromEsp3(p.ToEsp3()); err != nil || parsed != p {
+		t.Fatalf("round trip = %+v, err = %v", parsed, err)
+	}
+}
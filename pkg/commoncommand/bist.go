@@ -20,6 +20,11 @@ func (cmd *WrBist) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to WrBist.
+func (cmd *WrBist) ParseResponse(p response.Packet) (WrBistResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseWrBistResponseOK)
+}
+
 // NewBist constructs Bist.
 func NewBist() (WrBist, error) {
 	return WrBist{
@@ -0,0 +1,46 @@
+package commoncommand
+
+import (
+	"fmt"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// Command pairs a common command with the typed result of its RESPONSE.
+type Command[R any] interface {
+	Serialize() (esp3.Telegram, error)
+	ParseResponse(response.Packet) (R, error)
+}
+
+// Ack is the result of commands whose RESPONSE only carries a return code.
+type Ack struct{}
+
+// ReturnCodeError reports a RESPONSE whose return code is not SUCCESS.
+type ReturnCodeError struct {
+	Command enums.CommonCommand
+	Code    enums.ReturnCode
+}
+
+// Error returns the error message.
+func (e *ReturnCodeError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.Command, e.Code)
+}
+
+// ack checks that p reports SUCCESS for command.
+func ack(command enums.CommonCommand, p response.Packet) (Ack, error) {
+	if p.Code != enums.ReturnCodeSUCCESS {
+		return Ack{}, &ReturnCodeError{Command: command, Code: p.Code}
+	}
+	return Ack{}, nil
+}
+
+// parseOK checks the return code of p before handing it to parse.
+func parseOK[R any](command enums.CommonCommand, p response.Packet, parse func(response.Packet) (R, error)) (R, error) {
+	if _, err := ack(command, p); err != nil {
+		var zero R
+		return zero, err
+	}
+	return parse(p)
+}
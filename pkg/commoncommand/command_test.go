@@ -0,0 +1,97 @@
+package commoncommand
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+var (
+	_ Command[RdVersionResponse]               = (*RdVersion)(nil)
+	_ Command[RdIDBaseResponse]                = (*RdIDBase)(nil)
+	_ Command[Ack]                             = (*WrIDBase)(nil)
+	_ Command[RdRepeaterResponse]              = (*RdRepeater)(nil)
+	_ Command[Ack]                             = (*WrRepeater)(nil)
+	_ Command[RdFilterResponse]                = (*RdFilter)(nil)
+	_ Command[Ack]                             = (*WrFilterAdd)(nil)
+	_ Command[RdLearnModeResponse]             = (*RdLearnMode)(nil)
+	_ Command[Ack]                             = (*WrLearnMode)(nil)
+	_ Command[WrBistResponse]                  = (*WrBist)(nil)
+	_ Command[RdMemResponse]                   = (*RdMem)(nil)
+	_ Command[RdMemAddressResponse]            = (*RdMemAddress)(nil)
+	_ Command[RdSecureDeviceV2ByIndexResponse] = (*RdSecureDeviceV2ByIndex)(nil)
+	_ Command[RdDutyCycleLimitResponse]        = (*RdDutyCycleLimit)(nil)
+	_ Command[GetFrequencyInfoResponse]        = (*GetFrequencyInfo)(nil)
+	_ Command[RdTxOnlyModeResponse]            = (*RdTxOnlyMode)(nil)
+	_ Command[Ack]                             = (*WrReset)(nil)
+	_ Command[Ack]                             = (*WrSleep)(nil)
+)
+
+// TestParseResponseReturnsTypedResult verifies ParseResponseReturnsTypedResult behavior.
+func TestParseResponseReturnsTypedResult(t *testing.T) {
+	cmd, _ := NewRdIDBase()
+	got, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{0xff, 0x80, 0x00, 0x00}, OptData: []byte{7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.BaseID != deviceid.DeviceID(0xff800000) || got.RemainingWriteCount != 7 {
+		t.Fatalf("response = %+v", got)
+	}
+}
+
+// TestParseResponseReturnCodeError verifies ParseResponseReturnCodeError behavior.
+func TestParseResponseReturnCodeError(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func(response.Packet) error
+		want  enums.CommonCommand
+	}{
+		{"typed", func(p response.Packet) error {
+			cmd, _ := NewRdVersion()
+			_, err := cmd.ParseResponse(p)
+			return err
+		}, enums.CommonCommandRD_VERSION},
+		{"ack", func(p response.Packet) error {
+			cmd, _ := NewWrIDBase(0xff800000)
+			_, err := cmd.ParseResponse(p)
+			return err
+		}, enums.CommonCommandWR_IDBASE},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.parse(response.Packet{Code: enums.ReturnCodeBASEID_MAX_REACHED})
+			var rcErr *ReturnCodeError
+			if !errors.As(err, &rcErr) {
+				t.Fatalf("err = %v, want ReturnCodeError", err)
+			}
+			if rcErr.Command != tc.want || rcErr.Code != enums.ReturnCodeBASEID_MAX_REACHED {
+				t.Fatalf("error = %+v", rcErr)
+			}
+			if rcErr.Error() == "" {
+				t.Fatal("empty error message")
+			}
+		})
+	}
+}
+
+// TestParseResponseAck verifies ParseResponseAck behavior.
+func TestParseResponseAck(t *testing.T) {
+	cmd, _ := NewWrReset()
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
+
+// TestParseResponseKeepsPayloadErrors verifies ParseResponseKeepsPayloadErrors behavior.
+func TestParseResponseKeepsPayloadErrors(t *testing.T) {
+	cmd, _ := NewRdRepeater()
+	_, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{0x07, 0x00}})
+	var rcErr *ReturnCodeError
+	if err == nil || errors.As(err, &rcErr) {
+		t.Fatalf("err = %v, want payload error", err)
+	}
+}
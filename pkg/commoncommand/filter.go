@@ -22,6 +22,11 @@ func (cmd *WrFilterAdd) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrFilterAdd.
+func (cmd *WrFilterAdd) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrFilterAdd constructs WrFilterAdd.
 func NewWrFilterAdd(criterion enums.FilterCriterion, value uint32, forward bool, repeat bool) (WrFilterAdd, error) {
 	filterAction := byte(0)
@@ -58,6 +63,11 @@ func (cmd *WrFilterDel) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrFilterDel.
+func (cmd *WrFilterDel) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrFilterDel constructs WrFilterDel.
 func NewWrFilterDel(criterion enums.FilterCriterion, value uint32, forward bool, repeat bool) (WrFilterDel, error) {
 	filterAction := byte(0)
@@ -91,6 +101,11 @@ func (cmd *WrFilterDelAll) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrFilterDelAll.
+func (cmd *WrFilterDelAll) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrFilterDelAll constructs WrFilterDelAll.
 func NewWrFilterDelAll() (WrFilterDelAll, error) {
 	return WrFilterDelAll{
@@ -109,6 +124,11 @@ func (cmd *WrFilterEnable) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrFilterEnable.
+func (cmd *WrFilterEnable) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrFilterEnable constructs WrFilterEnable.
 func NewWrFilterEnable(toggle bool, operator enums.FilerOperator) (WrFilterEnable, error) {
 	return WrFilterEnable{
@@ -127,6 +147,11 @@ func (cmd *RdFilter) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdFilter.
+func (cmd *RdFilter) ParseResponse(p response.Packet) (RdFilterResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdFilterResponseOK)
+}
+
 // NewRdFilter constructs RdFilter.
 func NewRdFilter() (RdFilter, error) {
 	return RdFilter{
@@ -26,6 +26,11 @@ func (cmd *WrIDBase) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrIDBase.
+func (cmd *WrIDBase) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrIDBase constructs WrIDBase.
 func NewWrIDBase(deviceID deviceid.DeviceID) (WrIDBase, error) {
 	if deviceID < minBaseID || deviceID > maxBaseID {
@@ -51,6 +56,11 @@ func (cmd *RdIDBase) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdIDBase.
+func (cmd *RdIDBase) ParseResponse(p response.Packet) (RdIDBaseResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdIDBaseResponseOK)
+}
+
 // NewRdIDBase constructs RdIDBase.
 func NewRdIDBase() (RdIDBase, error) {
 	return RdIDBase{
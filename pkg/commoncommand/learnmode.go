@@ -21,6 +21,11 @@ func (cmd *WrLearnMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrLearnMode.
+func (cmd *WrLearnMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrLearnMode constructs WrLearnMode.
 func NewWrLearnMode(enableLearnMode bool, timeout uint32, channel uint8) (WrLearnMode, error) {
 	return WrLearnMode{
@@ -31,6 +36,27 @@ func NewWrLearnMode(enableLearnMode bool, timeout uint32, channel uint8) (WrLear
 	}, nil
 }
 
+type RdLearnMode struct {
+	CommandCode enums.CommonCommand `enocean-esp3:"data"`
+}
+
+// Serialize encodes RdLearnMode into its wire representation.
+func (cmd *RdLearnMode) Serialize() (esp3.Telegram, error) {
+	return serializer.CommandToTelegram(cmd)
+}
+
+// ParseResponse parses the RESPONSE to RdLearnMode.
+func (cmd *RdLearnMode) ParseResponse(p response.Packet) (RdLearnModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdLearnModeResponseOK)
+}
+
+// NewRdLearnMode constructs RdLearnMode.
+func NewRdLearnMode() (RdLearnMode, error) {
+	return RdLearnMode{
+		CommandCode: enums.CommonCommandRD_LEARNMODE,
+	}, nil
+}
+
 type RdLearnModeResponse struct {
 	LearnModeStatus bool
 	Channel         uint8
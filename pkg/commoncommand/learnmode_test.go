@@ -148,3 +148,20 @@ func TestParseRdLearnModeResponseOK(t *testing.T) {
 		}
 	})
 }
+
+// TestNewRdLearnMode verifies NewRdLearnMode behavior.
+func TestNewRdLearnMode(t *testing.T) {
+	cmd, err := NewRdLearnMode()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(telegram.Data) != 1 || telegram.Data[0] != byte(enums.CommonCommandRD_LEARNMODE) {
+		t.Errorf("expected Data [0x%02x], got %v", enums.CommonCommandRD_LEARNMODE, telegram.Data)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/edlundin/enocean-esp3/internal/serializer"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
 )
 
 type WrWaitMaturity struct {
@@ -16,6 +17,11 @@ func (cmd *WrWaitMaturity) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrWaitMaturity.
+func (cmd *WrWaitMaturity) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrWaitMaturity constructs WrWaitMaturity.
 func NewWrWaitMaturity(maturity enums.Maturity) (WrWaitMaturity, error) {
 	return WrWaitMaturity{
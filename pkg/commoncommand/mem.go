@@ -21,6 +21,11 @@ func (cmd *WrMem) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrMem.
+func (cmd *WrMem) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrMem only supported for TCM3xx and TCM4xx
 func NewWrMem(memoryType enums.MemoryType, address uint32, data []byte) (WrMem, error) {
 	return WrMem{
@@ -43,6 +48,11 @@ func (cmd *RdMem) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdMem.
+func (cmd *RdMem) ParseResponse(p response.Packet) (RdMemResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdMemResponseOK)
+}
+
 // NewRdMem constructs RdMem.
 func NewRdMem(memoryType enums.MemoryType, address uint32, dataLength uint16) (RdMem, error) {
 	return RdMem{
@@ -78,6 +88,11 @@ func (cmd *RdMemAddress) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdMemAddress.
+func (cmd *RdMemAddress) ParseResponse(p response.Packet) (RdMemAddressResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdMemAddressResponseOK)
+}
+
 // NewRdMemAddress constructs RdMemAddress.
 func NewRdMemAddress(area enums.MemoryArea) (RdMemAddress, error) {
 	return RdMemAddress{
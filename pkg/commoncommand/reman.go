@@ -19,6 +19,11 @@ func (cmd *WrRemanCode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRemanCode.
+func (cmd *WrRemanCode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRemanCode constructs WrRemanCode.
 func NewWrRemanCode(secureCode uint32) (WrRemanCode, error) {
 	return WrRemanCode{
@@ -40,6 +45,11 @@ func (cmd *WrRemanRepeating) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRemanRepeating.
+func (cmd *WrRemanRepeating) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRemanRepeating constructs WrRemanRepeating.
 func NewWrRemanRepeating(setRemanRepetition bool) (WrRemanRepeating, error) {
 	return WrRemanRepeating{
@@ -57,6 +67,11 @@ func (cmd *RdRemanRepeating) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdRemanRepeating.
+func (cmd *RdRemanRepeating) ParseResponse(p response.Packet) (RdRemanRepeatingResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdRemanRepeatingResponseOK)
+}
+
 // NewRdRemanRepeating constructs RdRemanRepeating.
 func NewRdRemanRepeating() (RdRemanRepeating, error) {
 	return RdRemanRepeating{
@@ -20,6 +20,11 @@ func (cmd *WrRepeater) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRepeater.
+func (cmd *WrRepeater) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRepeater constructs WrRepeater.
 func NewWrRepeater(repeaterMode enums.RepeaterMode, repeaterLevel enums.RepeaterLevel) (WrRepeater, error) {
 	return WrRepeater{
@@ -29,6 +34,27 @@ func NewWrRepeater(repeaterMode enums.RepeaterMode, repeaterLevel enums.Repeater
 	}, nil
 }
 
+type RdRepeater struct {
+	CommandCode enums.CommonCommand `enocean-esp3:"data"`
+}
+
+// Serialize encodes RdRepeater into its wire representation.
+func (cmd *RdRepeater) Serialize() (esp3.Telegram, error) {
+	return serializer.CommandToTelegram(cmd)
+}
+
+// ParseResponse parses the RESPONSE to RdRepeater.
+func (cmd *RdRepeater) ParseResponse(p response.Packet) (RdRepeaterResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdRepeaterResponseOK)
+}
+
+// NewRdRepeater constructs RdRepeater.
+func NewRdRepeater() (RdRepeater, error) {
+	return RdRepeater{
+		CommandCode: enums.CommonCommandRD_REPEATER,
+	}, nil
+}
+
 type RdRepeaterResponse struct {
 	RepeaterMode  enums.RepeaterMode
 	RepeaterLevel enums.RepeaterLevel
@@ -178,3 +178,20 @@ func TestParseRdRepeaterResponseOK(t *testing.T) {
 		}
 	})
 }
+
+// TestNewRdRepeater verifies NewRdRepeater behavior.
+func TestNewRdRepeater(t *testing.T) {
+	cmd, err := NewRdRepeater()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(telegram.Data) != 1 || telegram.Data[0] != byte(enums.CommonCommandRD_REPEATER) {
+		t.Errorf("expected Data [0x%02x], got %v", enums.CommonCommandRD_REPEATER, telegram.Data)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/edlundin/enocean-esp3/internal/serializer"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
 )
 
 type WrReset struct {
@@ -15,6 +16,11 @@ func (c *WrReset) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(c)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrReset.
+func (c *WrReset) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(c.CommandCode, p)
+}
+
 // NewWrReset constructs WrReset.
 func NewWrReset() (WrReset, error) {
 	return WrReset{
@@ -27,6 +27,11 @@ func (cmd *WrSecureDeviceAdd) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceAdd.
+func (cmd *WrSecureDeviceAdd) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceAdd constructs WrSecureDeviceAdd.
 func NewWrSecureDeviceAdd(securityLevelFormat uint8, deviceID deviceid.DeviceID, securityKey [16]byte, rollingCode [3]byte, direction enums.SecureDeviceDirection, ptmModule uint8, teachInfo uint8) (WrSecureDeviceAdd, error) {
 	if teachInfo > 0x0f {
@@ -56,6 +61,11 @@ func (cmd *WrSecureDeviceDel) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceDel.
+func (cmd *WrSecureDeviceDel) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceDel constructs WrSecureDeviceDel.
 func NewWrSecureDeviceDel(deviceID deviceid.DeviceID, direction enums.SecureDeviceDirection) (WrSecureDeviceDel, error) {
 	return WrSecureDeviceDel{
@@ -76,6 +86,11 @@ func (cmd *RdSecureDeviceByIndex) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSecureDeviceByIndex.
+func (cmd *RdSecureDeviceByIndex) ParseResponse(p response.Packet) (RdSecureDeviceByIndexResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSecureDeviceByIndexResponseOK)
+}
+
 // NewRdSecureDeviceByIndex constructs RdSecureDeviceByIndex.
 func NewRdSecureDeviceByIndex(index uint8, direction enums.SecureDeviceDirection) (RdSecureDeviceByIndex, error) {
 	if index > 0xfe {
@@ -126,6 +141,11 @@ func (cmd *RdNumSecureDevices) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdNumSecureDevices.
+func (cmd *RdNumSecureDevices) ParseResponse(p response.Packet) (RdNumSecureDevicesResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdNumSecureDevicesResponseOK)
+}
+
 // NewRdNumSecureDevices constructs RdNumSecureDevices.
 func NewRdNumSecureDevices(direction enums.SecureDeviceDirection) (RdNumSecureDevices, error) {
 	return RdNumSecureDevices{
@@ -163,6 +183,11 @@ func (cmd *RdSecureDeviceByID) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSecureDeviceByID.
+func (cmd *RdSecureDeviceByID) ParseResponse(p response.Packet) (RdSecureDeviceByIDResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSecureDeviceByIDResponseOK)
+}
+
 // NewRdSecureDeviceByID constructs RdSecureDeviceByID.
 func NewRdSecureDeviceByID(deviceID deviceid.DeviceID, direction enums.SecureDeviceDirection) (RdSecureDeviceByID, error) {
 	return RdSecureDeviceByID{
@@ -206,6 +231,11 @@ func (cmd *WrSecureDeviceAddPSK) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceAddPSK.
+func (cmd *WrSecureDeviceAddPSK) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceAddPSK constructs WrSecureDeviceAddPSK.
 func NewWrSecureDeviceAddPSK(deviceID deviceid.DeviceID, psk [16]byte) (WrSecureDeviceAddPSK, error) {
 	return WrSecureDeviceAddPSK{
@@ -226,6 +256,11 @@ func (cmd *WrSecureDeviceSendTeachIn) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceSendTeachIn.
+func (cmd *WrSecureDeviceSendTeachIn) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceSendTeachIn constructs WrSecureDeviceSendTeachIn.
 func NewWrSecureDeviceSendTeachIn(deviceID deviceid.DeviceID, teachInInfo uint8) (WrSecureDeviceSendTeachIn, error) {
 	return WrSecureDeviceSendTeachIn{
@@ -246,6 +281,11 @@ func (cmd *WrTemporaryRLCWindow) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrTemporaryRLCWindow.
+func (cmd *WrTemporaryRLCWindow) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrTemporaryRLCWindow constructs WrTemporaryRLCWindow.
 func NewWrTemporaryRLCWindow(enable bool, rlcWindow uint32) (WrTemporaryRLCWindow, error) {
 	return WrTemporaryRLCWindow{
@@ -265,6 +305,11 @@ func (cmd *RdSecureDevicePSK) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSecureDevicePSK.
+func (cmd *RdSecureDevicePSK) ParseResponse(p response.Packet) (RdSecureDevicePSKResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSecureDevicePSKResponseOK)
+}
+
 // NewRdSecureDevicePSK constructs RdSecureDevicePSK.
 func NewRdSecureDevicePSK(deviceID deviceid.DeviceID, direction enums.SecureDeviceDirection) (RdSecureDevicePSK, error) {
 	return RdSecureDevicePSK{
@@ -301,6 +346,11 @@ func (cmd *WrRLCSavePeriod) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRLCSavePeriod.
+func (cmd *WrRLCSavePeriod) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRLCSavePeriod constructs WrRLCSavePeriod.
 func NewWrRLCSavePeriod(savePeriod uint8) (WrRLCSavePeriod, error) {
 	return WrRLCSavePeriod{
@@ -319,6 +369,11 @@ func (cmd *WrRLCLegacyMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRLCLegacyMode.
+func (cmd *WrRLCLegacyMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRLCLegacyMode constructs WrRLCLegacyMode.
 func NewWrRLCLegacyMode(rlcMode enums.RLCMode) (WrRLCLegacyMode, error) {
 	if !rlcMode.Valid() {
@@ -351,6 +406,11 @@ func (cmd *WrSecureDeviceV2Add) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceV2Add.
+func (cmd *WrSecureDeviceV2Add) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceV2Add constructs WrSecureDeviceV2Add.
 func NewWrSecureDeviceV2Add(securityLevelFormat uint8, deviceID deviceid.DeviceID, privateKey [16]byte, rollingCode uint32, direction enums.SecureDeviceDirection) (WrSecureDeviceV2Add, error) {
 	return WrSecureDeviceV2Add{
@@ -382,6 +442,11 @@ func (cmd *RdSecureDeviceV2ByIndex) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSecureDeviceV2ByIndex.
+func (cmd *RdSecureDeviceV2ByIndex) ParseResponse(p response.Packet) (RdSecureDeviceV2ByIndexResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSecureDeviceV2ByIndexResponseOK)
+}
+
 // NewRdSecureDeviceV2ByIndex constructs RdSecureDeviceV2ByIndex.
 func NewRdSecureDeviceV2ByIndex(index uint8, direction enums.SecureDeviceDirection) (RdSecureDeviceV2ByIndex, error) {
 	if index > 0xfe {
@@ -437,6 +502,11 @@ func (cmd *WrSecureDeviceRemanKey) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSecureDeviceRemanKey.
+func (cmd *WrSecureDeviceRemanKey) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSecureDeviceRemainCode constructs a secure-device ReMan key command.
 func NewWrSecureDeviceRemainCode(deviceID deviceid.DeviceID, remanKey [16]byte, remanKeyNumber uint8) (WrSecureDeviceRemanKey, error) {
 	return WrSecureDeviceRemanKey{
@@ -461,6 +531,11 @@ func (cmd *RdSecureDeviceRemanKey) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSecureDeviceRemanKey.
+func (cmd *RdSecureDeviceRemanKey) ParseResponse(p response.Packet) (RdSecureDeviceRemanKeyResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSecureDeviceRemanKeyResponseOK)
+}
+
 // NewRdSecureDeviceRemanKey constructs RdSecureDeviceRemanKey.
 func NewRdSecureDeviceRemanKey(index uint8) (RdSecureDeviceRemanKey, error) {
 	return RdSecureDeviceRemanKey{
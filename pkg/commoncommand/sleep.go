@@ -6,6 +6,7 @@ import (
 	"github.com/edlundin/enocean-esp3/internal/serializer"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
 )
 
 const maxDeepSleepPeriod = 0xffffff
@@ -21,6 +22,11 @@ func (cmd *WrSleep) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrSleep.
+func (cmd *WrSleep) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrSleep constructs WrSleep.
 func NewWrSleep(deepSleepPeriod uint32) (WrSleep, error) {
 	return WrSleep{
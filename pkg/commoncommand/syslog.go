@@ -19,6 +19,11 @@ func (cmd *RdSysLog) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdSysLog.
+func (cmd *RdSysLog) ParseResponse(p response.Packet) (RdSysLogResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdSysLogResponseOK)
+}
+
 // NewRdSysLog constructs RdSysLog.
 func NewRdSysLog() (RdSysLog, error) {
 	return RdSysLog{
@@ -53,6 +58,11 @@ func (cmd *ResetSysLog) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to ResetSysLog.
+func (cmd *ResetSysLog) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewResetSysLog constructs ResetSysLog.
 func NewResetSysLog() (ResetSysLog, error) {
 	return ResetSysLog{
@@ -18,6 +18,11 @@ func (cmd *RdDutyCycleLimit) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdDutyCycleLimit.
+func (cmd *RdDutyCycleLimit) ParseResponse(p response.Packet) (RdDutyCycleLimitResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdDutyCycleLimitResponseOK)
+}
+
 // NewRdDutyCycleLimit constructs RdDutyCycleLimit.
 func NewRdDutyCycleLimit() (RdDutyCycleLimit, error) {
 	return RdDutyCycleLimit{
@@ -57,6 +62,11 @@ func (cmd *SetBaudrate) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to SetBaudrate.
+func (cmd *SetBaudrate) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewSetBaudrate constructs SetBaudrate.
 func NewSetBaudrate(baudrate enums.TCMBaudrate) (SetBaudrate, error) {
 	return SetBaudrate{
@@ -74,6 +84,11 @@ func (cmd *GetFrequencyInfo) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to GetFrequencyInfo.
+func (cmd *GetFrequencyInfo) ParseResponse(p response.Packet) (GetFrequencyInfoResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseGetFrequencyInfoResponseOK)
+}
+
 // NewGetFrequencyInfo constructs GetFrequencyInfo.
 func NewGetFrequencyInfo() (GetFrequencyInfo, error) {
 	return GetFrequencyInfo{
@@ -109,6 +124,11 @@ func (cmd *GetStepCode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to GetStepCode.
+func (cmd *GetStepCode) ParseResponse(p response.Packet) (GetStepCodeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseGetStepCodeResponseOK)
+}
+
 // NewGetStepCode constructs GetStepCode.
 func NewGetStepCode() (GetStepCode, error) {
 	return GetStepCode{
@@ -145,6 +165,11 @@ func (cmd *WrStartupDelay) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrStartupDelay.
+func (cmd *WrStartupDelay) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrStartupDelay constructs WrStartupDelay.
 func NewWrStartupDelay(startupDelay uint8) (WrStartupDelay, error) {
 	return WrStartupDelay{
@@ -163,6 +188,11 @@ func (cmd *SetNoiseThreshold) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to SetNoiseThreshold.
+func (cmd *SetNoiseThreshold) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewSetNoiseThreshold constructs SetNoiseThreshold.
 func NewSetNoiseThreshold(noiseThreshold uint8) (SetNoiseThreshold, error) {
 	return SetNoiseThreshold{
@@ -180,6 +210,11 @@ func (cmd *GetNoiseThreshold) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to GetNoiseThreshold.
+func (cmd *GetNoiseThreshold) ParseResponse(p response.Packet) (GetNoiseThresholdResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseGetNoiseThresholdResponseOK)
+}
+
 // NewGetNoiseThreshold constructs GetNoiseThreshold.
 func NewGetNoiseThreshold() (GetNoiseThreshold, error) {
 	return GetNoiseThreshold{
@@ -215,6 +250,11 @@ func (cmd *SetCRCMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to SetCRCMode.
+func (cmd *SetCRCMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewSetCRCMode constructs SetCRCMode.
 func NewSetCRCMode(crcMode enums.CRCMode) (SetCRCMode, error) {
 	return SetCRCMode{
@@ -232,6 +272,11 @@ func (cmd *GetCRCMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to GetCRCMode.
+func (cmd *GetCRCMode) ParseResponse(p response.Packet) (GetCRCModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseGetCRCModeResponseOK)
+}
+
 // NewGetCRCMode constructs GetCRCMode.
 func NewGetCRCMode() (GetCRCMode, error) {
 	return GetCRCMode{
@@ -268,6 +313,11 @@ func (cmd *WrRSSITestMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrRSSITestMode.
+func (cmd *WrRSSITestMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrRSSITestMode constructs WrRSSITestMode.
 func NewWrRSSITestMode(testMode enums.RSSITestMode, timeout uint16) (WrRSSITestMode, error) {
 	return WrRSSITestMode{
@@ -286,6 +336,11 @@ func (cmd *RdRSSITestMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdRSSITestMode.
+func (cmd *RdRSSITestMode) ParseResponse(p response.Packet) (RdRSSITestModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdRSSITestModeResponseOK)
+}
+
 // NewRdRSSITestMode constructs RdRSSITestMode.
 func NewRdRSSITestMode() (RdRSSITestMode, error) {
 	return RdRSSITestMode{
@@ -321,6 +376,11 @@ func (cmd *WrTransparentMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrTransparentMode.
+func (cmd *WrTransparentMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrTransparentMode constructs WrTransparentMode.
 func NewWrTransparentMode(transparentMode enums.TransparentMode) (WrTransparentMode, error) {
 	return WrTransparentMode{
@@ -338,6 +398,11 @@ func (cmd *RdTransparentMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdTransparentMode.
+func (cmd *RdTransparentMode) ParseResponse(p response.Packet) (RdTransparentModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdTransparentModeResponseOK)
+}
+
 // NewRdTransparentMode constructs RdTransparentMode.
 func NewRdTransparentMode() (RdTransparentMode, error) {
 	return RdTransparentMode{
@@ -373,6 +438,11 @@ func (cmd *WrTxOnlyMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse checks the return code of the RESPONSE to WrTxOnlyMode.
+func (cmd *WrTxOnlyMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
 // NewWrTxOnlyMode constructs WrTxOnlyMode.
 func NewWrTxOnlyMode(txOnlyMode enums.TxOnlyMode) (WrTxOnlyMode, error) {
 	return WrTxOnlyMode{
@@ -390,6 +460,11 @@ func (cmd *RdTxOnlyMode) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdTxOnlyMode.
+func (cmd *RdTxOnlyMode) ParseResponse(p response.Packet) (RdTxOnlyModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdTxOnlyModeResponseOK)
+}
+
 // NewRdTxOnlyMode constructs RdTxOnlyMode.
 func NewRdTxOnlyMode() (RdTxOnlyMode, error) {
 	return RdTxOnlyMode{
@@ -22,6 +22,11 @@ func (cmd *RdVersion) Serialize() (esp3.Telegram, error) {
 	return serializer.CommandToTelegram(cmd)
 }
 
+// ParseResponse parses the RESPONSE to RdVersion.
+func (cmd *RdVersion) ParseResponse(p response.Packet) (RdVersionResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdVersionResponseOK)
+}
+
 // NewRdVersion constructs RdVersion.
 func NewRdVersion() (RdVersion, error) {
 	return RdVersion{
@@ -0,0 +1,45 @@
+// Package configcommand parses and builds CONFIG_COMMAND telegrams, which
+// read and write the configuration of the transceiver.
+package configcommand
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type Packet struct {
+	Code    byte
+	Data    []byte
+	OptData []byte
+}
+
+// NewPacketFromEsp3 parses a configuration command from an ESP3 telegram.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const codeOffset = 0
+
+	if telegram.PacketType != enums.PacketTypeCONFIG_COMMAND {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) == 0 {
+		return Packet{}, errors.New("data too short")
+	}
+
+	return Packet{
+		Code:    telegram.Data[codeOffset],
+		Data:    telegram.Data[codeOffset+1:],
+		OptData: telegram.OptData,
+	}, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	return esp3.NewTelegramFromData(enums.PacketTypeCONFIG_COMMAND, append([]byte{p.Code}, p.Data...), p.OptData)
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
@@ -0,0 +1,35 @@
+package configcommand
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCONFIG_COMMAND, []byte{0x01, 0x02, 0x03}, []byte{0x04}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Packet{Code: 0x01, Data: []byte{0x02, 0x03}, OptData: []byte{0x04}}); !reflect.DeepEqual(p, want) {
+		t.Errorf("packet = %+v", p)
+	}
+
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{0x01}, nil)); err == nil {
+		t.Error("common command accepted")
+	}
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeCONFIG_COMMAND, nil, nil)); err == nil {
+		t.Error("empty command accepted")
+	}
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	telegram := Packet{Code: 0x05, Data: []byte{0x01}}.ToEsp3()
+	if telegram.PacketType != enums.PacketTypeCONFIG_COMMAND || !reflect.DeepEqual(telegram.Data, []byte{0x05, 0x01}) {
+		t.Fatalf("telegram = %+v", telegram)
+	}
+}
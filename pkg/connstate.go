@@ -0,0 +1,35 @@
+package pkg
+
+// ConnState is the state of a session's link to the transceiver.
+type ConnState uint8
+
+const (
+	ConnStateConnected ConnState = iota
+	ConnStateLost
+	ConnStateReconnecting
+)
+
+// String returns the string representation of ConnState.
+func (state ConnState) String() string {
+	switch state {
+	case ConnStateConnected:
+		return "CONNECTED"
+	case ConnStateLost:
+		return "LOST"
+	case ConnStateReconnecting:
+		return "RECONNECTING"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ConnEvent reports a connection state change.
+//
+// Connected is emitted once the setup commands have been replayed; Err holds
+// the first setup failure, if any. Lost carries the read error that ended the
+// link. Reconnecting carries the failed attempt number and its dial error.
+type ConnEvent struct {
+	State   ConnState
+	Attempt int
+	Err     error
+}
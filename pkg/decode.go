@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+)
+
+// Decoded is an ERP1 packet decoded with the EEP its sender is registered
+// under.
+type Decoded struct {
+	Device   registry.Device
+	Packet   erp1.Packet
+	Telegram profiles.Telegram
+}
+
+// decodeProfiles appends a decoded message after every ERP1 message whose
+// sender is registered in devices. Teach-in telegrams and packets of another
+// RORG than the registered EEP are left undecoded.
+func decodeProfiles(devices *registry.Registry, messages []Message) []Message {
+	if devices == nil {
+		return messages
+	}
+
+	for i := 0; i < len(messages); i++ {
+		msg := messages[i]
+		if msg.Kind != MessageKindERP1 {
+			continue
+		}
+		p := msg.Data.(erp1.Packet)
+		device, ok := devices.Lookup(p.SenderID)
+		if !ok || p.Rorg != device.EEP.Rorg || registry.IsTeachIn(p) {
+			continue
+		}
+
+		decoded := Message{Kind: MessageKindDecoded, ESP3: msg.ESP3, ERP1: msg.ERP1}
+		telegram, err := profiles.ParsePacket(p, device.EEP)
+		if err != nil {
+			decoded.Kind = MessageKindParseError
+			decoded.Err = err
+		} else {
+			decoded.Data = Decoded{Device: device, Packet: p, Telegram: telegram}
+		}
+		messages = append(messages[:i+1], append([]Message{decoded}, messages[i+1:]...)...)
+		i++
+	}
+	return messages
+}
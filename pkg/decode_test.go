@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+)
+
+// TestDecodeProfilesAppendsDecodedMessage verifies DecodeProfilesAppendsDecodedMessage behavior.
+func TestDecodeProfilesAppendsDecodedMessage(t *testing.T) {
+	rocker, _ := eep.FromString("F6-01-01")
+	devices := registry.New(registry.Device{ID: 0x0100000a, EEP: rocker, Name: "Switch"})
+
+	msgs := decodeProfiles(devices, parseTelegram(newReManAssembler(remanChainPeriod), rockerPacket(0x0100000a, true).ToEsp3()))
+	if len(msgs) != 3 || msgs[1].Kind != MessageKindERP1 || msgs[2].Kind != MessageKindDecoded {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	decoded := msgs[2].Data.(Decoded)
+	if decoded.Device.Name != "Switch" || decoded.Telegram != (profiles.F60101{Pressed: true}) || msgs[2].ERP1 == nil {
+		t.Fatalf("decoded = %+v", decoded)
+	}
+
+	unknown := decodeProfiles(devices, parseTelegram(newReManAssembler(remanChainPeriod), rockerPacket(0x0100000b, true).ToEsp3()))
+	if len(unknown) != 2 {
+		t.Fatalf("unregistered sender decoded: %+v", unknown)
+	}
+}
+
+// TestDecodeProfilesSkipsTeachInAndReportsErrors verifies DecodeProfilesSkipsTeachInAndReportsErrors behavior.
+func TestDecodeProfilesSkipsTeachInAndReportsErrors(t *testing.T) {
+	temperature, _ := eep.FromString("A5-02-01")
+	devices := registry.New(registry.Device{ID: 1, EEP: temperature})
+	packet := func(data ...byte) []Message {
+		p := erp1.Packet{DestinationID: 0xffffffff, Rorg: enums.Rorg4BS, SenderID: 1, UserData: data}
+		return parseTelegram(newReManAssembler(remanChainPeriod), p.ToEsp3())
+	}
+
+	if msgs := decodeProfiles(devices, packet(0x08, 0x28, 0x0b, 0x80)); len(msgs) != 2 {
+		t.Fatalf("teach-in decoded: %+v", msgs)
+	}
+	if msgs := decodeProfiles(devices, packet(0x00, 0x00, 0x40, 0x08)); len(msgs) != 3 || msgs[2].Kind != MessageKindDecoded {
+		t.Fatalf("data telegram: %+v", msgs)
+	}
+
+	devices.Add(registry.Device{ID: 1, EEP: eep.EEP{Rorg: enums.Rorg4BS, Func: 0x7f, Type: 0x7f}})
+	if msgs := decodeProfiles(devices, packet(0x00, 0x00, 0x40, 0x08)); len(msgs) != 3 || msgs[2].Kind != MessageKindParseError || msgs[2].Err == nil {
+		t.Fatalf("unsupported profile: %+v", msgs)
+	}
+}
+
+// TestSessionDecodesRegisteredSenders verifies SessionDecodesRegisteredSenders behavior.
+func TestSessionDecodesRegisteredSenders(t *testing.T) {
+	port := newScriptedPort()
+	devices := registry.New()
+	s := NewSession(context.Background(), port, SessionConfig{Devices: devices})
+	defer s.Close()
+
+	rocker, _ := eep.FromString("F6-01-01")
+	devices.Add(registry.Device{ID: 0x0100000a, EEP: rocker})
+	port.in <- rockerPacket(0x0100000a, true).ToEsp3().Serialize()
+
+	select {
+	case d := <-s.Channels.Decoded:
+		if d.Packet.SenderID != 0x0100000a || d.Telegram != (profiles.F60101{Pressed: true}) {
+			t.Fatalf("decoded = %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("telegram was not decoded")
+	}
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// Deduplicated is one logical ERP1 telegram merged from the subtelegrams
+// and repeated copies received within the deduplication window.
+type Deduplicated struct {
+	// Packet is the first copy, carrying the best RSSI of all copies.
+	Packet erp1.Packet
+	// Copies counts the copies received, the first one included.
+	Copies int
+	// Hops is the fewest repeater hops any copy took; 0 when the
+	// telegram was received directly.
+	Hops int
+}
+
+// dedupKey identifies copies of one telegram. The repeater count is masked
+// out of the status because repeaters increment it.
+type dedupKey struct {
+	sender   deviceid.DeviceID
+	rorg     enums.Rorg
+	userData string
+	status   byte
+}
+
+type dedupEntry struct {
+	ctx      context.Context
+	first    esp3.Telegram
+	merged   Deduplicated
+	deadline time.Time
+	timer    *time.Timer
+}
+
+// dedupStage suppresses copies of ERP1 telegrams received within window of
+// the first one and publishes a Deduplicated message once window elapses.
+// Merged telegrams are published in the order their first copies arrived.
+type dedupStage struct {
+	window time.Duration
+
+	releaseMu sync.Mutex
+	mu        sync.Mutex
+	pending   map[dedupKey]*dedupEntry
+}
+
+// newDedupStage constructs a dedupStage merging copies within window.
+func newDedupStage(window time.Duration) *dedupStage {
+	return &dedupStage{window: window, pending: map[dedupKey]*dedupEntry{}}
+}
+
+// newDedupKey returns the key of p.
+func newDedupKey(p erp1.Packet) dedupKey {
+	return dedupKey{sender: p.SenderID, rorg: p.Rorg, userData: string(p.UserData), status: p.Status &^ erp1.RepeaterCountMask}
+}
+
+// merge folds p into a pending telegram and reports whether p was a copy.
+func (d *dedupStage) merge(p erp1.Packet) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.pending[newDedupKey(p)]
+	if !ok {
+		return false
+	}
+	entry.merged.Copies++
+	if p.Rssi != erp1.RssiUnknown && (entry.merged.Packet.Rssi == erp1.RssiUnknown || p.Rssi < entry.merged.Packet.Rssi) {
+		entry.merged.Packet.Rssi = p.Rssi
+	}
+	entry.merged.Hops = min(entry.merged.Hops, int(p.RepeaterCount()))
+	return true
+}
+
+// hold starts the window of p, the first copy of a telegram, parsed from t.
+func (d *dedupStage) hold(ctx context.Context, c *channelSet, t esp3.Telegram, p erp1.Packet) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.pending[newDedupKey(p)] = &dedupEntry{
+		ctx:      ctx,
+		first:    t,
+		merged:   Deduplicated{Packet: p, Copies: 1, Hops: int(p.RepeaterCount())},
+		deadline: time.Now().Add(d.window),
+		timer:    time.AfterFunc(d.window, func() { d.release(c, time.Now()) }),
+	}
+}
+
+// flush publishes every pending telegram at once, so the channels can be
+// closed.
+func (d *dedupStage) flush(c *channelSet) {
+	d.release(c, time.Time{})
+}
+
+// release publishes the telegrams whose window elapsed at now, or every
+// pending telegram when now is zero.
+func (d *dedupStage) release(c *channelSet, now time.Time) {
+	d.releaseMu.Lock()
+	defer d.releaseMu.Unlock()
+
+	d.mu.Lock()
+	var due []*dedupEntry
+	for key, entry := range d.pending {
+		if now.IsZero() || !entry.deadline.After(now) {
+			entry.timer.Stop()
+			due = append(due, entry)
+			delete(d.pending, key)
+		}
+	}
+	d.mu.Unlock()
+
+	slices.SortFunc(due, func(a, b *dedupEntry) int { return a.deadline.Compare(b.deadline) })
+	for _, entry := range due {
+		publish(entry.ctx, c, []Message{{Kind: MessageKindDeduplicated, ESP3: entry.first, ERP1: &entry.merged.Packet, Data: entry.merged}})
+	}
+}
@@ -0,0 +1,156 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/linkstats"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
+)
+
+// receivedFrame serializes p as received with rssi through repeaters hops.
+func receivedFrame(p erp1.Packet, rssi byte, hops byte) []byte {
+	p.Status = p.Status&^erp1.RepeaterCountMask | hops
+	t := p.ToEsp3()
+	t.OptData[5] = rssi
+	return t.Serialize()
+}
+
+// TestSessionDeduplicatesCopies verifies SessionDeduplicatesCopies behavior.
+func TestSessionDeduplicatesCopies(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port, SessionConfig{DedupWindow: 50 * time.Millisecond})
+	defer s.Close()
+
+	press := rockerPacket(0x0100000a, true)
+	port.in <- receivedFrame(press, 0x50, 0)
+	port.in <- receivedFrame(press, 0x40, 1)
+	port.in <- receivedFrame(press, 0x48, 0)
+	port.in <- receivedFrame(rockerPacket(0x0100000a, false), 0x50, 0)
+
+	select {
+	case d := <-s.Channels.Deduplicated:
+		if d.Copies != 3 || d.Hops != 0 || d.Packet.Rssi != 0x40 || d.Packet.SenderID != 0x0100000a {
+			t.Fatalf("deduplicated = %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no deduplicated telegram")
+	}
+
+	var senders []deviceid.DeviceID
+	for range 2 {
+		p := <-s.Channels.ERP1
+		senders = append(senders, p.SenderID)
+		if p.Rssi != 0x50 {
+			t.Fatalf("first copy rssi = %#x", p.Rssi)
+		}
+	}
+	select {
+	case p := <-s.Channels.ERP1:
+		t.Fatalf("copy delivered: %+v", p)
+	default:
+	}
+
+	port.in <- receivedFrame(press, 0x50, 2)
+	select {
+	case p := <-s.Channels.ERP1:
+		if p.RepeaterCount() != 2 {
+			t.Fatalf("status = %#x", p.Status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("telegram after the window was suppressed")
+	}
+	if len(s.Channels.ESP3) != 5 {
+		t.Fatalf("ESP3 frames = %d, want every copy", len(s.Channels.ESP3))
+	}
+}
+
+// TestDedupFlushesOnClose verifies DedupFlushesOnClose behavior.
+func TestDedupFlushesOnClose(t *testing.T) {
+	set, channels := newChannelSet(16)
+	set.dedup = newDedupStage(time.Hour)
+
+	var frames bytes.Buffer
+	frames.Write(receivedFrame(rockerPacket(1, true), 0x50, 1))
+	frames.Write(receivedFrame(rockerPacket(1, true), 0x50, 2))
+	if err := parse(context.Background(), &frames, set, newReManAssembler(remanChainPeriod)); err != io.EOF {
+		t.Fatalf("parse = %v", err)
+	}
+	set.close()
+
+	d, ok := <-channels.Deduplicated
+	if !ok || d.Copies != 2 || d.Hops != 1 {
+		t.Fatalf("deduplicated = %+v, %t", d, ok)
+	}
+}
+
+// TestDedupFlushesAfterCancel verifies DedupFlushesAfterCancel behavior.
+func TestDedupFlushesAfterCancel(t *testing.T) {
+	for range 20 {
+		set, channels := newChannelSetFromConfig(StreamConfig{BufferSize: 16, Overflow: OverflowBlock})
+		set.dedup = newDedupStage(time.Hour)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		frames := bytes.NewReader(receivedFrame(rockerPacket(1, true), 0x50, 1))
+		if err := parse(ctx, frames, set, newReManAssembler(remanChainPeriod)); err != io.EOF {
+			t.Fatalf("parse = %v", err)
+		}
+		cancel()
+		set.close()
+
+		if d, ok := <-channels.Deduplicated; !ok || d.Copies != 1 {
+			t.Fatalf("deduplicated = %+v, %t", d, ok)
+		}
+	}
+}
+
+// TestSessionDeduplicatesSubTelCopies verifies SessionDeduplicatesSubTelCopies behavior.
+func TestSessionDeduplicatesSubTelCopies(t *testing.T) {
+	port := newScriptedPort()
+	stats := linkstats.New()
+	s := NewSession(context.Background(), port, SessionConfig{DedupWindow: 50 * time.Millisecond, Stats: stats})
+	defer s.Close()
+
+	press := rockerPacket(0x0100000a, true)
+	for i, rssi := range []byte{0x50, 0x40} {
+		copied := subtel.Packet{
+			DestinationID: press.DestinationID,
+			Rorg:          press.Rorg,
+			Rssi:          rssi,
+			Status:        press.Status | byte(i),
+			SubTelNum:     1,
+			SenderID:      press.SenderID,
+			SubTels:       []subtel.SubTel{{Rssi: rssi, Status: press.Status | byte(i)}},
+			UserData:      press.UserData,
+		}
+		frame := copied.ToEsp3()
+		frame.PacketType, frame.OptData[5] = enums.PacketTypeRADIO_SUB_TEL, rssi
+		port.in <- frame.Serialize()
+	}
+
+	select {
+	case d := <-s.Channels.Deduplicated:
+		if d.Copies != 2 || d.Hops != 0 || d.Packet.SenderID != 0x0100000a {
+			t.Fatalf("deduplicated = %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no deduplicated telegram")
+	}
+	if p := <-s.Channels.ERP1; p.Rssi != 0x50 {
+		t.Fatalf("first copy rssi = %#x", p.Rssi)
+	}
+	select {
+	case p := <-s.Channels.ERP1:
+		t.Fatalf("copy delivered: %+v", p)
+	default:
+	}
+	if l, _ := stats.Link(0x0100000a); l.Telegrams != 2 || l.SubTelegrams != 2 || l.Repeated != 1 {
+		t.Fatalf("link = %+v", l)
+	}
+}
@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+)
+
+// Handler handles a parsed message.
+type Handler func(Message)
+
+// Filter reports whether a handler should see a message.
+type Filter func(Message) bool
+
+// Middleware wraps every handler invocation, e.g. to log, time or recover.
+type Middleware func(Handler) Handler
+
+// ProfileResolver returns the EEP a sender is known to use.
+type ProfileResolver func(deviceid.DeviceID) (eep.EEP, bool)
+
+// Dispatcher routes parsed messages to registered handlers. Handlers run in
+// the order they were registered, on the goroutine calling Dispatch; for a
+// session that is the parser, so a handler must not wait for a RESPONSE.
+type Dispatcher struct {
+	mu         sync.RWMutex
+	handlers   []*registration
+	middleware []Middleware
+	resolve    ProfileResolver
+}
+
+type registration struct {
+	kind    MessageKind
+	any     bool
+	filters []Filter
+	handle  Handler
+}
+
+// NewDispatcher constructs an empty Dispatcher.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Use appends middleware. The first middleware added is the outermost.
+func (d *Dispatcher) Use(mw ...Middleware) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.middleware = append(d.middleware, mw...)
+}
+
+// SetProfileResolver sets how OnEEP learns which EEP a sender uses. Without
+// a resolver, OnEEP decodes every ERP1 packet whose RORG matches the profile.
+func (d *Dispatcher) SetProfileResolver(resolve ProfileResolver) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resolve = resolve
+}
+
+// OnMessage registers h for every message passing filters. The returned
+// function unregisters it.
+func (d *Dispatcher) OnMessage(h Handler, filters ...Filter) func() {
+	return d.add(&registration{any: true, filters: filters, handle: h})
+}
+
+// On registers h for messages of kind passing filters.
+func (d *Dispatcher) On(kind MessageKind, h Handler, filters ...Filter) func() {
+	return d.add(&registration{kind: kind, filters: filters, handle: h})
+}
+
+// OnERP1 registers h for every ERP1 packet passing filters.
+func (d *Dispatcher) OnERP1(h func(erp1.Packet), filters ...Filter) func() {
+	return d.On(MessageKindERP1, func(msg Message) { h(msg.Data.(erp1.Packet)) }, filters...)
+}
+
+// OnSender registers h for the ERP1 packets sent by id.
+func (d *Dispatcher) OnSender(id deviceid.DeviceID, h func(erp1.Packet), filters ...Filter) func() {
+	return d.OnERP1(h, append([]Filter{FromSender(id)}, filters...)...)
+}
+
+// OnEvent registers h for the events carrying code.
+func (d *Dispatcher) OnEvent(code enums.EventCode, h func(event.Event), filters ...Filter) func() {
+	isCode := func(msg Message) bool { return msg.Data.(event.Event).Description() == code }
+	return d.On(MessageKindEvent, func(msg Message) { h(msg.Data.(event.Event)) }, append([]Filter{isCode}, filters...)...)
+}
+
+// OnEEP registers h for ERP1 packets decoded with profile. Packets that do
+// not decode are skipped.
+func (d *Dispatcher) OnEEP(profile eep.EEP, h func(profiles.Telegram), filters ...Filter) func() {
+	return d.OnERP1(func(p erp1.Packet) {
+		telegram, err := profiles.ParsePacket(p, profile)
+		if err != nil {
+			return
+		}
+		h(telegram)
+	}, append([]Filter{d.usesProfile(profile)}, filters...)...)
+}
+
+// OnDecoded registers h for the telegrams decoded through the session's
+// device registry.
+func (d *Dispatcher) OnDecoded(h func(Decoded), filters ...Filter) func() {
+	return d.On(MessageKindDecoded, func(msg Message) { h(msg.Data.(Decoded)) }, filters...)
+}
+
+// OnDeduplicated registers h for the telegrams merged by the session's
+// deduplication stage.
+func (d *Dispatcher) OnDeduplicated(h func(Deduplicated), filters ...Filter) func() {
+	return d.On(MessageKindDeduplicated, func(msg Message) { h(msg.Data.(Deduplicated)) }, filters...)
+}
+
+// usesProfile matches packets from senders using profile.
+func (d *Dispatcher) usesProfile(profile eep.EEP) Filter {
+	return func(msg Message) bool {
+		d.mu.RLock()
+		resolve := d.resolve
+		d.mu.RUnlock()
+
+		if resolve == nil {
+			return msg.ERP1.Rorg == profile.Rorg
+		}
+		known, ok := resolve(msg.ERP1.SenderID)
+		return ok && known == profile
+	}
+}
+
+// add registers r and returns its removal function.
+func (d *Dispatcher) add(r *registration) func() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers = append(d.handlers, r)
+
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.handlers = slices.DeleteFunc(d.handlers, func(other *registration) bool { return other == r })
+	}
+}
+
+// Dispatch runs the handlers matching msg.
+func (d *Dispatcher) Dispatch(msg Message) {
+	d.mu.RLock()
+	handlers := slices.Clone(d.handlers)
+	middleware := slices.Clone(d.middleware)
+	d.mu.RUnlock()
+
+	for _, r := range handlers {
+		if !r.matches(msg) {
+			continue
+		}
+		h := r.handle
+		for i := len(middleware) - 1; i >= 0; i-- {
+			h = middleware[i](h)
+		}
+		h(msg)
+	}
+}
+
+// Run dispatches messages from ch, e.g. Channels.All, until ch is closed or
+// ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, ch <-chan Message) {
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			d.Dispatch(msg)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// matches reports whether r handles msg.
+func (r *registration) matches(msg Message) bool {
+	if !r.any && r.kind != msg.Kind {
+		return false
+	}
+	for _, filter := range r.filters {
+		if !filter(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// FromSender matches messages carrying an ERP1 packet sent by id.
+func FromSender(id deviceid.DeviceID) Filter {
+	return func(msg Message) bool { return msg.ERP1 != nil && msg.ERP1.SenderID == id }
+}
+
+// WithRorg matches messages carrying an ERP1 packet of rorg.
+func WithRorg(rorg enums.Rorg) Filter {
+	return func(msg Message) bool { return msg.ERP1 != nil && msg.ERP1.Rorg == rorg }
+}
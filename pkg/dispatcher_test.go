@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+)
+
+// rockerPacket builds an F6-01-01 telegram from sender.
+func rockerPacket(sender deviceid.DeviceID, pressed bool) erp1.Packet {
+	data := byte(0x00)
+	if pressed {
+		data = 0x10
+	}
+	return erp1.Packet{DestinationID: deviceid.BroadcastId(), Rorg: enums.RorgRPS, SenderID: sender, UserData: []byte{data}, Status: 0x30}
+}
+
+// dispatchPacket runs p through the parser pipeline into d.
+func dispatchPacket(d *Dispatcher, p erp1.Packet) {
+	for _, msg := range parseTelegram(newReManAssembler(remanChainPeriod), p.ToEsp3()) {
+		d.Dispatch(msg)
+	}
+}
+
+// TestDispatcherRoutesByKindAndSender verifies DispatcherRoutesByKindAndSender behavior.
+func TestDispatcherRoutesByKindAndSender(t *testing.T) {
+	d := NewDispatcher()
+	var kinds []MessageKind
+	var fromA, all int
+	d.OnMessage(func(msg Message) { kinds = append(kinds, msg.Kind) })
+	d.OnERP1(func(erp1.Packet) { all++ })
+	d.OnSender(0x0100000a, func(erp1.Packet) { fromA++ })
+
+	dispatchPacket(d, rockerPacket(0x0100000a, true))
+	dispatchPacket(d, rockerPacket(0x0100000b, true))
+
+	if fromA != 1 || all != 2 {
+		t.Fatalf("fromA=%d all=%d", fromA, all)
+	}
+	if want := []MessageKind{MessageKindESP3, MessageKindERP1, MessageKindESP3, MessageKindERP1}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+}
+
+// TestDispatcherOnEEP verifies DispatcherOnEEP behavior.
+func TestDispatcherOnEEP(t *testing.T) {
+	rocker, _ := eep.FromString("F6-01-01")
+	d := NewDispatcher()
+	var got []profiles.Telegram
+	d.OnEEP(rocker, func(telegram profiles.Telegram) { got = append(got, telegram) })
+
+	dispatchPacket(d, rockerPacket(0x0100000a, true))
+	if len(got) != 1 || got[0] != (profiles.F60101{Pressed: true}) {
+		t.Fatalf("decoded %v", got)
+	}
+
+	d.SetProfileResolver(func(id deviceid.DeviceID) (eep.EEP, bool) { return rocker, id == 0x0100000a })
+	dispatchPacket(d, rockerPacket(0x0100000a, false))
+	dispatchPacket(d, rockerPacket(0x0100000b, true))
+	if len(got) != 2 || got[1] != (profiles.F60101{Pressed: false}) {
+		t.Fatalf("decoded %v", got)
+	}
+}
+
+// TestDispatcherOnEvent verifies DispatcherOnEvent behavior.
+func TestDispatcherOnEvent(t *testing.T) {
+	d := NewDispatcher()
+	var got []enums.EventCode
+	d.OnEvent(enums.EventCodeCO_TX_DONE, func(e event.Event) { got = append(got, e.Description()) })
+
+	d.Dispatch(Message{Kind: MessageKindEvent, Data: event.Packet{EventCode: enums.EventCodeCO_READY}})
+	d.Dispatch(Message{Kind: MessageKindEvent, Data: event.Packet{EventCode: enums.EventCodeCO_TX_DONE}})
+
+	if !reflect.DeepEqual(got, []enums.EventCode{enums.EventCodeCO_TX_DONE}) {
+		t.Fatalf("events = %v", got)
+	}
+}
+
+// TestDispatcherFiltersAndUnregister verifies DispatcherFiltersAndUnregister behavior.
+func TestDispatcherFiltersAndUnregister(t *testing.T) {
+	d := NewDispatcher()
+	var count int
+	unregister := d.OnERP1(func(erp1.Packet) { count++ }, WithRorg(enums.RorgRPS), func(msg Message) bool { return msg.ERP1.UserData[0] != 0 })
+
+	dispatchPacket(d, rockerPacket(1, true))
+	dispatchPacket(d, rockerPacket(1, false))
+	unregister()
+	dispatchPacket(d, rockerPacket(1, true))
+
+	if count != 1 {
+		t.Fatalf("count = %d, want 1", count)
+	}
+}
+
+// TestDispatcherMiddlewareOrder verifies DispatcherMiddlewareOrder behavior.
+func TestDispatcherMiddlewareOrder(t *testing.T) {
+	d := NewDispatcher()
+	var trace []string
+	tag := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(msg Message) {
+				trace = append(trace, name)
+				next(msg)
+			}
+		}
+	}
+	d.Use(tag("outer"), tag("inner"))
+	d.On(MessageKindUnparsed, func(Message) { trace = append(trace, "handler") })
+
+	d.Dispatch(Message{Kind: MessageKindUnparsed})
+	d.Dispatch(Message{Kind: MessageKindESP3})
+
+	if want := []string{"outer", "inner", "handler"}; !reflect.DeepEqual(trace, want) {
+		t.Fatalf("trace = %v, want %v", trace, want)
+	}
+}
+
+// TestSessionFeedsDispatcher verifies SessionFeedsDispatcher behavior.
+func TestSessionFeedsDispatcher(t *testing.T) {
+	port := newScriptedPort()
+	d := NewDispatcher()
+	packets := make(chan erp1.Packet, 1)
+	d.OnERP1(func(p erp1.Packet) { packets <- p })
+	s := NewSession(context.Background(), port, SessionConfig{Dispatcher: d})
+	defer s.Close()
+
+	port.in <- rockerPacket(0x0100000a, true).ToEsp3().Serialize()
+
+	select {
+	case p := <-packets:
+		if p.SenderID != 0x0100000a {
+			t.Fatalf("sender = %s", p.SenderID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("packet was not dispatched")
+	}
+}
+
+// TestDispatcherRun verifies DispatcherRun behavior.
+func TestDispatcherRun(t *testing.T) {
+	d := NewDispatcher()
+	got := make(chan esp3.Telegram, 1)
+	d.On(MessageKindESP3, func(msg Message) { got <- msg.ESP3 })
+
+	ch := make(chan Message, 1)
+	ch <- Message{Kind: MessageKindESP3, ESP3: esp3.Telegram{PacketType: enums.PacketTypeEVENT}}
+	close(ch)
+	d.Run(context.Background(), ch)
+
+	if telegram := <-got; telegram.PacketType != enums.PacketTypeEVENT {
+		t.Fatalf("telegram = %+v", telegram)
+	}
+}
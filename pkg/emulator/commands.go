@@ -0,0 +1,304 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+const (
+	minBaseID     = 0xff800000
+	maxBaseID     = 0xffffff80
+	baseIDGapSize = 128
+
+	// memorySize is the size of every emulated memory type.
+	memorySize = 0x400
+
+	dutyCycleSlots      = 24
+	dutyCycleSlotPeriod = 150 // seconds
+)
+
+// memoryAreas places the areas of CO_RD_MEM_ADDRESS in emulated flash.
+var memoryAreas = map[enums.MemoryArea][2]uint32{
+	enums.MemoryAreaCONFIG:           {0x000, 0x100},
+	enums.MemoryAreaSMART_ACK_TABLE:  {0x100, 0x200},
+	enums.MemoryAreaSYSTEM_ERROR_LOG: {0x300, 0x100},
+}
+
+// filter is a receive filter written with CO_WR_FILTER_ADD.
+type filter struct {
+	action    enums.FilterActionMask
+	criterion enums.FilterCriterion
+	value     uint32
+}
+
+// matches reports whether p meets the criterion of f.
+func (f filter) matches(p erp1.Packet) bool {
+	switch f.criterion {
+	case enums.FilterCriterionSENDER_ID:
+		return uint32(p.SenderID) == f.value
+	case enums.FilterCriterionRORG:
+		return uint32(p.Rorg) == f.value
+	case enums.FilterCriterionRSSI:
+		// RSSI is an attenuation: stronger telegrams have lower values.
+		return uint32(p.Rssi) <= f.value
+	case enums.FilterCriterionDESTINATION_ID:
+		return uint32(p.DestinationID) == f.value
+	default:
+		return false
+	}
+}
+
+// passes reports whether f lets p through: forwarding filters pass matching
+// telegrams, blocking filters pass the others.
+func (f filter) passes(p erp1.Packet) bool {
+	forward := f.action&enums.FilterActionFORWARD != 0
+	return f.matches(p) == forward
+}
+
+// accepts reports whether the enabled filters let p through.
+func (e *Emulator) accepts(p erp1.Packet) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.filtersEnabled || len(e.filters) == 0 {
+		return true
+	}
+
+	and := e.filterOperator&enums.FilerOperatorAND_ALL_FILTERS != 0
+	for _, f := range e.filters {
+		if f.passes(p) != and {
+			return !and
+		}
+	}
+	return and
+}
+
+// command answers a COMMON_COMMAND of the host.
+func (e *Emulator) command(t esp3.Telegram) {
+	if len(t.Data) == 0 {
+		e.respond(enums.ReturnCodeWRONG_ARGUMENT, nil, nil)
+		return
+	}
+	args := t.Data[1:]
+
+	switch enums.CommonCommand(t.Data[0]) {
+	case enums.CommonCommandRD_VERSION:
+		data := make([]byte, 0, 16+len(e.cfg.Description))
+		data = append(data, e.cfg.AppVersion[:]...)
+		data = append(data, e.cfg.APIVersion[:]...)
+		data = binary.BigEndian.AppendUint32(data, e.cfg.ChipID)
+		data = binary.BigEndian.AppendUint32(data, defaultChipVersion)
+		data = append(data, e.cfg.Description...)
+		e.respond(enums.ReturnCodeSUCCESS, data, nil)
+	case enums.CommonCommandRD_IDBASE:
+		e.mu.Lock()
+		id, remaining := e.baseID.ToArray(), e.remainingWrites
+		e.mu.Unlock()
+		e.respond(enums.ReturnCodeSUCCESS, id[:], []byte{remaining})
+	case enums.CommonCommandWR_IDBASE:
+		e.respond(e.writeIDBase(args), nil, nil)
+	case enums.CommonCommandWR_RESET:
+		e.respond(enums.ReturnCodeSUCCESS, nil, nil)
+		_ = e.Reset(enums.WakeUpCauseRESET_BY_RESET_PIN)
+	case enums.CommonCommandRD_DUTYCYCLE_LIMIT:
+		e.mu.Lock()
+		available := byte(100)
+		if e.dutyCycleReached {
+			available = 0
+		}
+		e.mu.Unlock()
+		data := []byte{available, dutyCycleSlots}
+		data = binary.BigEndian.AppendUint16(data, dutyCycleSlotPeriod)
+		data = binary.BigEndian.AppendUint16(data, dutyCycleSlotPeriod/2)
+		e.respond(enums.ReturnCodeSUCCESS, append(data, 100), nil)
+	default:
+		e.respond(e.configure(enums.CommonCommand(t.Data[0]), args))
+	}
+}
+
+// writeIDBase handles CO_WR_IDBASE.
+func (e *Emulator) writeIDBase(args []byte) enums.ReturnCode {
+	if len(args) < deviceid.DeviceIDSize {
+		return enums.ReturnCodeWRONG_ARGUMENT
+	}
+	id := deviceid.DeviceID(binary.BigEndian.Uint32(args))
+	if id < minBaseID || id > maxBaseID || id%baseIDGapSize != 0 {
+		return enums.ReturnCodeBASEID_OUT_OF_RANGE
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.remainingWrites == 0 {
+		return enums.ReturnCodeBASEID_MAX_REACHED
+	}
+	e.baseID = id
+	e.remainingWrites--
+	return enums.ReturnCodeSUCCESS
+}
+
+// configure handles the commands that read or write the emulator state
+// and returns their RESPONSE.
+func (e *Emulator) configure(command enums.CommonCommand, args []byte) (enums.ReturnCode, []byte, []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch command {
+	case enums.CommonCommandWR_FILTER_ADD, enums.CommonCommandWR_FILTER_DEL:
+		if len(args) < 6 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		f := filter{
+			action:    enums.FilterActionMask(args[0]),
+			criterion: enums.FilterCriterion(args[1]),
+			value:     binary.BigEndian.Uint32(args[2:]),
+		}
+		if !f.criterion.Valid() {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		if command == enums.CommonCommandWR_FILTER_ADD {
+			e.filters = append(e.filters, f)
+			return enums.ReturnCodeSUCCESS, nil, nil
+		}
+		for i, existing := range e.filters {
+			if existing.criterion == f.criterion && existing.value == f.value {
+				e.filters = append(e.filters[:i], e.filters[i+1:]...)
+				return enums.ReturnCodeSUCCESS, nil, nil
+			}
+		}
+		return enums.ReturnCodeERROR, nil, nil
+	case enums.CommonCommandWR_FILTER_DEL_ALL:
+		e.filters = nil
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandWR_FILTER_ENABLE:
+		if len(args) < 2 || !enums.FilerOperator(args[1]).Valid() {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.filtersEnabled = args[0] != 0
+		e.filterOperator = enums.FilerOperator(args[1])
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandRD_FILTER:
+		data := []byte{byte(len(e.filters))}
+		for _, f := range e.filters {
+			data = append(data, byte(f.criterion))
+			data = binary.BigEndian.AppendUint32(data, f.value)
+		}
+		return enums.ReturnCodeSUCCESS, data, nil
+	case enums.CommonCommandWR_LEARNMODE:
+		if len(args) < 5 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.stopLearning()
+		if args[0] == 0 {
+			return enums.ReturnCodeSUCCESS, nil, nil
+		}
+		timeout := time.Duration(binary.BigEndian.Uint32(args[1:])) * time.Millisecond
+		if timeout == 0 {
+			timeout = defaultLearnTimeout
+		}
+		if len(args) > 5 {
+			e.learnChannel = args[5]
+		}
+		e.learning = true
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			e.mu.Lock()
+			current := e.learnTimer == timer
+			if current {
+				e.learning, e.learnTimer = false, nil
+			}
+			e.mu.Unlock()
+			if current {
+				_ = e.Event(enums.EventCodeCO_LRN_MODE_DISABLED, nil, nil)
+			}
+		})
+		e.learnTimer = timer
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandRD_LEARNMODE:
+		return enums.ReturnCodeSUCCESS, []byte{boolByte(e.learning)}, []byte{e.learnChannel}
+	case enums.CommonCommandWR_REPEATER:
+		if len(args) < 2 || !enums.RepeaterMode(args[0]).Valid() || !enums.RepeaterLevel(args[1]).Valid() {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.repeaterMode, e.repeaterLevel = enums.RepeaterMode(args[0]), enums.RepeaterLevel(args[1])
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandRD_REPEATER:
+		return enums.ReturnCodeSUCCESS, []byte{byte(e.repeaterMode), byte(e.repeaterLevel)}, nil
+	case enums.CommonCommandWR_MEM:
+		if len(args) < 5 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		mem, ok := e.region(enums.MemoryType(args[0]), binary.BigEndian.Uint32(args[1:]), len(args)-5)
+		if !ok {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		copy(mem, args[5:])
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandRD_MEM:
+		if len(args) < 7 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		mem, ok := e.region(enums.MemoryType(args[0]), binary.BigEndian.Uint32(args[1:]), int(binary.BigEndian.Uint16(args[5:])))
+		if !ok {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		return enums.ReturnCodeSUCCESS, append([]byte(nil), mem...), nil
+	case enums.CommonCommandWR_MODE:
+		if len(args) < 1 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		mode, err := enums.ParseRadioModeFromByte(args[0])
+		if err != nil {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.radioMode = mode
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.CommonCommandRD_MEM_ADDRESS:
+		if len(args) < 1 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		area, ok := memoryAreas[enums.MemoryArea(args[0])]
+		if !ok {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		data := []byte{byte(enums.MemoryTypeFLASH)}
+		data = binary.BigEndian.AppendUint32(data, area[0])
+		return enums.ReturnCodeSUCCESS, binary.BigEndian.AppendUint32(data, area[1]), nil
+	default:
+		return enums.ReturnCodeNOT_SUPPORTED, nil, nil
+	}
+}
+
+// region returns n bytes of memory typ at address, allocating the memory on
+// first use. The caller holds e.mu.
+func (e *Emulator) region(typ enums.MemoryType, address uint32, n int) ([]byte, bool) {
+	if !typ.Valid() || uint64(address)+uint64(n) > memorySize {
+		return nil, false
+	}
+	mem, ok := e.memory[typ]
+	if !ok {
+		mem = make([]byte, memorySize)
+		e.memory[typ] = mem
+	}
+	return mem[address : int(address)+n], true
+}
+
+// stopLearning leaves learn mode without an event. The caller holds e.mu.
+func (e *Emulator) stopLearning() {
+	if e.learnTimer != nil {
+		e.learnTimer.Stop()
+		e.learnTimer = nil
+	}
+	e.learning = false
+}
+
+// boolByte encodes b as ESP3 does.
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
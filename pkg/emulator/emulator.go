@@ -0,0 +1,415 @@
+// Package emulator emulates an EnOcean TCM 310 / USB 300 transceiver on the
+// device side of an in-memory transport, so sessions, parsers and everything
+// built on them can be tested without hardware.
+//
+// The emulator answers common and Smart Ack commands with plausible
+// RESPONSEs, keeps the state they write (ID base, filters, learn modes,
+// repeater, memory, learned Smart Ack clients, radio mode), accepts radio
+// telegrams from the host and injects scripted ones towards it, as RADIO_ERP2
+// in ERP2 mode. Faults
+// such as checksum errors, dropped bytes and an exhausted duty cycle can be
+// simulated at will.
+package emulator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/radioerp2"
+)
+
+const (
+	defaultBaseID          deviceid.DeviceID = 0xff9fa580
+	defaultChipID          uint32            = 0x01807ab3
+	defaultChipVersion     uint32            = 0x45410103
+	defaultDescription                       = "GATEWAYCTRL"
+	defaultRemainingWrites uint8             = 10
+	defaultLearnTimeout                      = 60 * time.Second
+)
+
+var (
+	defaultAppVersion = [4]byte{2, 11, 1, 0}
+	defaultAPIVersion = [4]byte{2, 6, 3, 0}
+)
+
+// Fault corrupts the next frame sent to the host.
+type Fault uint8
+
+const (
+	// FaultNone sends the frame unharmed.
+	FaultNone Fault = iota
+	// FaultCRC8H flips the bits of the header checksum.
+	FaultCRC8H
+	// FaultCRC8D flips the bits of the data checksum.
+	FaultCRC8D
+	// FaultDropByte loses the last data byte, as a noisy UART would.
+	FaultDropByte
+)
+
+// String returns the string representation of Fault.
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "NONE"
+	case FaultCRC8H:
+		return "CRC8H"
+	case FaultCRC8D:
+		return "CRC8D"
+	case FaultDropByte:
+		return "DROP_BYTE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Config tunes an Emulator. Zero fields keep their defaults.
+type Config struct {
+	// BaseID is the initial ID base; defaults to FF9FA580.
+	BaseID deviceid.DeviceID
+
+	// ChipID is the chip ID, also the sender of telegrams sent with it.
+	ChipID uint32
+
+	// AppVersion, APIVersion and Description are reported by CO_RD_VERSION.
+	AppVersion  [4]byte
+	APIVersion  [4]byte
+	Description string
+
+	// RemainingWrites is the number of ID base changes left; defaults to 10.
+	RemainingWrites uint8
+
+	// ResponseDelay delays every RESPONSE, e.g. beyond the ESP3 window to
+	// test timeouts.
+	ResponseDelay time.Duration
+
+	// TxDone emits CO_TX_DONE after every radio telegram sent.
+	TxDone bool
+
+	// OnTransmit, if set, receives every radio telegram the host sends.
+	OnTransmit func(erp1.Packet)
+
+	// RadioMode is the radio mode the emulator starts in and returns to on
+	// Reset; CO_WR_MODE changes it in between. Defaults to ERP1.
+	RadioMode enums.RadioMode
+}
+
+// Emulator is an emulated transceiver. It is safe for concurrent use.
+type Emulator struct {
+	cfg    Config
+	host   pkg.Transport
+	device pkg.Transport
+	cancel context.CancelFunc
+	done   chan struct{}
+	node   *node
+
+	writeMu sync.Mutex
+	fault   Fault
+
+	mu               sync.Mutex
+	baseID           deviceid.DeviceID
+	remainingWrites  uint8
+	filters          []filter
+	filtersEnabled   bool
+	filterOperator   enums.FilerOperator
+	learning         bool
+	learnChannel     uint8
+	learnTimer       *time.Timer
+	repeaterMode     enums.RepeaterMode
+	repeaterLevel    enums.RepeaterLevel
+	dutyCycleReached bool
+	memory           map[enums.MemoryType][]byte
+	smartAckLearning bool
+	smartAckMode     enums.SmartAckLearnMode
+	smartAckTimer    *time.Timer
+	smartAckClients  []smartAckClient
+	mailboxCount     uint8
+	radioMode        enums.RadioMode
+}
+
+// New starts an emulator. The host side of its transport is returned by
+// Host; it must be read, as the emulator blocks until its frames are taken.
+func New(cfg ...Config) *Emulator {
+	c := Config{
+		BaseID:          defaultBaseID,
+		ChipID:          defaultChipID,
+		AppVersion:      defaultAppVersion,
+		APIVersion:      defaultAPIVersion,
+		Description:     defaultDescription,
+		RemainingWrites: defaultRemainingWrites,
+	}
+	for _, o := range cfg {
+		if o.BaseID != 0 {
+			c.BaseID = o.BaseID
+		}
+		if o.ChipID != 0 {
+			c.ChipID = o.ChipID
+		}
+		if o.AppVersion != [4]byte{} {
+			c.AppVersion = o.AppVersion
+		}
+		if o.APIVersion != [4]byte{} {
+			c.APIVersion = o.APIVersion
+		}
+		if o.Description != "" {
+			c.Description = o.Description
+		}
+		if o.RemainingWrites != 0 {
+			c.RemainingWrites = o.RemainingWrites
+		}
+		if o.ResponseDelay > 0 {
+			c.ResponseDelay = o.ResponseDelay
+		}
+		c.TxDone = c.TxDone || o.TxDone
+		if o.OnTransmit != nil {
+			c.OnTransmit = o.OnTransmit
+		}
+		if o.RadioMode != enums.RadioModeERP1 {
+			c.RadioMode = o.RadioMode
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	host, device := pkg.NewLoopback()
+	e := &Emulator{
+		cfg:             c,
+		host:            host,
+		device:          device,
+		cancel:          cancel,
+		done:            make(chan struct{}),
+		baseID:          c.BaseID,
+		remainingWrites: c.RemainingWrites,
+		memory:          make(map[enums.MemoryType][]byte),
+		radioMode:       c.RadioMode,
+	}
+
+	channels := pkg.StartParser(ctx, device, pkg.ParserConfig{
+		Streams: pkg.StreamConfig{Overflows: map[pkg.Stream]pkg.Overflow{pkg.StreamESP3: pkg.OverflowBlock}},
+	})
+	go e.run(channels.ESP3)
+	return e
+}
+
+// Host returns the host side of the emulator's transport, to hand to
+// pkg.NewSession or pkg.StartParser.
+func (e *Emulator) Host() pkg.Transport {
+	return e.host
+}
+
+// BaseID returns the current ID base.
+func (e *Emulator) BaseID() deviceid.DeviceID {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.baseID
+}
+
+// Learning reports whether learn mode is active.
+func (e *Emulator) Learning() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.learning
+}
+
+// Close stops the emulator and closes both sides of its transport.
+func (e *Emulator) Close() error {
+	e.cancel()
+	e.device.Close()
+	err := e.host.Close()
+	<-e.done
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.learnTimer != nil {
+		e.learnTimer.Stop()
+	}
+	e.stopSmartAckLearning()
+	return err
+}
+
+// run answers the telegrams of the host until the parser stops.
+func (e *Emulator) run(telegrams <-chan esp3.Telegram) {
+	defer close(e.done)
+	for t := range telegrams {
+		e.handle(t)
+	}
+}
+
+// handle answers one telegram of the host.
+func (e *Emulator) handle(t esp3.Telegram) {
+	switch t.PacketType {
+	case enums.PacketTypeCOMMON_COMMAND:
+		e.command(t)
+	case enums.PacketTypeRADIO_ERP1, enums.PacketTypeRADIO_ERP2:
+		e.transmit(t)
+	case enums.PacketTypeSMART_ACK_COMMAND:
+		e.smartAckCommand(t)
+	default:
+		e.respond(enums.ReturnCodeNOT_SUPPORTED, nil, nil)
+	}
+}
+
+// transmit sends a radio telegram of the host on the air. The telegram
+// must match the radio mode.
+func (e *Emulator) transmit(t esp3.Telegram) {
+	e.mu.Lock()
+	limited, mode := e.dutyCycleReached, e.radioMode
+	e.mu.Unlock()
+	if (t.PacketType == enums.PacketTypeRADIO_ERP2) != (mode == enums.RadioModeERP2) {
+		e.respond(enums.ReturnCodeNOT_SUPPORTED, nil, nil)
+		return
+	}
+
+	p, err := radioPacket(t)
+	if err != nil {
+		e.respond(enums.ReturnCodeWRONG_ARGUMENT, nil, nil)
+		return
+	}
+	if limited {
+		e.respond(enums.ReturnCodeOPERATION_DENIED, nil, nil)
+		return
+	}
+
+	e.respond(enums.ReturnCodeSUCCESS, nil, nil)
+	if e.cfg.OnTransmit != nil {
+		e.cfg.OnTransmit(p)
+	}
+	if e.cfg.TxDone {
+		e.Event(enums.EventCodeCO_TX_DONE, nil, nil)
+	}
+}
+
+// Receive injects p as a radio telegram received by the transceiver. The
+// subtelegram count, destination, RSSI and security level of p are kept,
+// and the active filters apply. It reports whether p passed the filters.
+// In ERP2 mode, p is sent as RADIO_ERP2 and its security level is lost.
+func (e *Emulator) Receive(p erp1.Packet) (bool, error) {
+	if !e.accepts(p) {
+		return false, nil
+	}
+
+	e.mu.Lock()
+	mode := e.radioMode
+	e.mu.Unlock()
+	if mode == enums.RadioModeERP2 {
+		telegram, err := erp2.FromERP1(p)
+		if err != nil {
+			return false, err
+		}
+		data, err := telegram.Encode()
+		if err != nil {
+			return false, err
+		}
+		return true, e.Send(radioerp2.Packet{Data: data, SubTelNum: p.SubTelNum, Dbm: p.Rssi}.ToEsp3())
+	}
+
+	t := p.ToEsp3()
+	t.OptData[5] = p.Rssi
+	t.OptData[6] = p.SecurityLevel
+	return true, e.Send(t)
+}
+
+// RadioMode returns the radio mode set with CO_WR_MODE.
+func (e *Emulator) RadioMode() enums.RadioMode {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.radioMode
+}
+
+// radioPacket decodes a RADIO_ERP1 or RADIO_ERP2 telegram of the host.
+func radioPacket(t esp3.Telegram) (erp1.Packet, error) {
+	if t.PacketType == enums.PacketTypeRADIO_ERP1 {
+		return erp1.NewPacketFromEsp3(t)
+	}
+	p, err := radioerp2.NewPacketFromEsp3(t)
+	if err != nil {
+		return erp1.Packet{}, err
+	}
+	telegram, err := erp2.Parse(p.Data)
+	if err != nil {
+		return erp1.Packet{}, err
+	}
+	return telegram.ERP1()
+}
+
+// Event injects an EVENT telegram with the given event code.
+func (e *Emulator) Event(code enums.EventCode, data []byte, optData []byte) error {
+	return e.Send(esp3.NewTelegramFromData(enums.PacketTypeEVENT, append([]byte{byte(code)}, data...), optData))
+}
+
+// Reset simulates a reset of the transceiver: the learn modes and the duty
+// cycle limit are cleared, the radio mode returns to Config.RadioMode, and
+// CO_READY reports cause.
+func (e *Emulator) Reset(cause enums.WakeUpCause) error {
+	e.mu.Lock()
+	e.stopLearning()
+	e.stopSmartAckLearning()
+	e.dutyCycleReached = false
+	e.radioMode = e.cfg.RadioMode
+	e.mu.Unlock()
+
+	return e.Event(enums.EventCodeCO_READY, []byte{byte(cause)}, []byte{byte(enums.WakeUpModeSTANDARD_SECURITY)})
+}
+
+// LimitDutyCycle exhausts or restores the duty cycle budget and reports it
+// with CO_DUTYCYCLE_LIMIT. While exhausted, radio telegrams are refused with
+// OPERATION_DENIED and CO_RD_DUTYCYCLE_LIMIT reports no budget.
+func (e *Emulator) LimitDutyCycle(reached bool) error {
+	e.mu.Lock()
+	e.dutyCycleReached = reached
+	e.mu.Unlock()
+
+	cause := enums.DutyCycleLimitCauseNOT_YET_REACHED
+	if reached {
+		cause = enums.DutyCycleLimitCauseREACHED
+	}
+	return e.Event(enums.EventCodeCO_DUTYCYCLE_LIMIT, []byte{byte(cause)}, nil)
+}
+
+// Fail corrupts the next frame sent to the host with f.
+func (e *Emulator) Fail(f Fault) {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	e.fault = f
+}
+
+// Send writes t to the host.
+func (e *Emulator) Send(t esp3.Telegram) error {
+	frame := t.Serialize()
+
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	switch e.fault {
+	case FaultCRC8H:
+		frame[5] ^= 0xff
+	case FaultCRC8D:
+		frame[len(frame)-1] ^= 0xff
+	case FaultDropByte:
+		frame = append(frame[:len(frame)-2], frame[len(frame)-1])
+	}
+	e.fault = FaultNone
+
+	_, err := e.device.Write(frame)
+	return err
+}
+
+// SendRaw writes b to the host as is, e.g. line noise between frames.
+func (e *Emulator) SendRaw(b []byte) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+	_, err := e.device.Write(b)
+	return err
+}
+
+// respond sends a RESPONSE with code, after the configured delay.
+func (e *Emulator) respond(code enums.ReturnCode, data []byte, optData []byte) {
+	if e.cfg.ResponseDelay > 0 {
+		time.Sleep(e.cfg.ResponseDelay)
+	}
+	_ = e.Send(esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, append([]byte{byte(code)}, data...), optData))
+}
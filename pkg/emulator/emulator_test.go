@@ -0,0 +1,360 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+)
+
+// crcHooks counts the checksum errors seen by a session.
+type crcHooks struct {
+	pkg.NopHooks
+
+	mu     sync.Mutex
+	errors []pkg.CRCError
+}
+
+func (h *crcHooks) CRCError(err pkg.CRCError) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors = append(h.errors, err)
+}
+
+func (h *crcHooks) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.errors)
+}
+
+// newSession starts an emulator and a session on it.
+func newSession(t *testing.T, cfg Config, sessionCfg ...pkg.SessionConfig) (*Emulator, *pkg.Session) {
+	t.Helper()
+	e := New(cfg)
+	s := pkg.NewSession(context.Background(), e.Host(), sessionCfg...)
+	t.Cleanup(func() {
+		s.Close()
+		e.Close()
+	})
+	return e, s
+}
+
+// nextEvent waits for the next event of s.
+func nextEvent(t *testing.T, s *pkg.Session) event.Event {
+	t.Helper()
+	select {
+	case e := <-s.Channels.Event:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+// TestEmulatorAnswersCommonCommands verifies EmulatorAnswersCommonCommands behavior.
+func TestEmulatorAnswersCommonCommands(t *testing.T) {
+	e, s := newSession(t, Config{RemainingWrites: 1})
+	ctx := context.Background()
+
+	rdVersion, _ := commoncommand.NewRdVersion()
+	version, err := pkg.Exec(ctx, s, &rdVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.AppVersion != defaultAppVersion || version.ChipID != defaultChipID || version.Description != defaultDescription {
+		t.Fatalf("version = %+v", version)
+	}
+
+	wrIDBase, _ := commoncommand.NewWrIDBase(0xff800080)
+	if _, err := pkg.Exec(ctx, s, &wrIDBase); err != nil {
+		t.Fatal(err)
+	}
+	rdIDBase, _ := commoncommand.NewRdIDBase()
+	idBase, err := pkg.Exec(ctx, s, &rdIDBase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idBase.BaseID != 0xff800080 || idBase.RemainingWriteCount != 0 || e.BaseID() != 0xff800080 {
+		t.Fatalf("ID base = %+v", idBase)
+	}
+	var codeErr *commoncommand.ReturnCodeError
+	if _, err := pkg.Exec(ctx, s, &wrIDBase); !errors.As(err, &codeErr) || codeErr.Code != enums.ReturnCodeBASEID_MAX_REACHED {
+		t.Fatalf("second WR_IDBASE err = %v", err)
+	}
+
+	add, _ := commoncommand.NewWrFilterAdd(enums.FilterCriterionSENDER_ID, 0x01020304, true, false)
+	if _, err := pkg.Exec(ctx, s, &add); err != nil {
+		t.Fatal(err)
+	}
+	rdFilter, _ := commoncommand.NewRdFilter()
+	filters, err := pkg.Exec(ctx, s, &rdFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []commoncommand.Filter{{Criterion: enums.FilterCriterionSENDER_ID, Value: 0x01020304}}; !reflect.DeepEqual(filters.Filters, want) {
+		t.Fatalf("filters = %+v", filters.Filters)
+	}
+
+	wrMem, _ := commoncommand.NewWrMem(enums.MemoryTypeRAM0, 0x10, []byte{0xde, 0xad})
+	if _, err := pkg.Exec(ctx, s, &wrMem); err != nil {
+		t.Fatal(err)
+	}
+	rdMem, _ := commoncommand.NewRdMem(enums.MemoryTypeRAM0, 0x0f, 4)
+	mem, err := pkg.Exec(ctx, s, &rdMem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mem.Data, []byte{0x00, 0xde, 0xad, 0x00}) {
+		t.Fatalf("memory = % x", mem.Data)
+	}
+	rdMemAddress, _ := commoncommand.NewRdMemAddress(enums.MemoryAreaSMART_ACK_TABLE)
+	if area, err := pkg.Exec(ctx, s, &rdMemAddress); err != nil || area.Address != 0x100 || area.Length != 0x200 {
+		t.Fatalf("area = %+v, err = %v", area, err)
+	}
+
+	rdSysLog, _ := commoncommand.NewRdSysLog()
+	if _, err := pkg.Exec(ctx, s, &rdSysLog); !errors.As(err, &codeErr) || codeErr.Code != enums.ReturnCodeNOT_SUPPORTED {
+		t.Fatalf("RD_SYS_LOG err = %v", err)
+	}
+}
+
+// TestEmulatorLearnModeAndReset verifies EmulatorLearnModeAndReset behavior.
+func TestEmulatorLearnModeAndReset(t *testing.T) {
+	e, s := newSession(t, Config{})
+	ctx := context.Background()
+
+	wrLearn, _ := commoncommand.NewWrLearnMode(true, 20, 0)
+	if _, err := pkg.Exec(ctx, s, &wrLearn); err != nil {
+		t.Fatal(err)
+	}
+	rdLearn, _ := commoncommand.NewRdLearnMode()
+	if learn, err := pkg.Exec(ctx, s, &rdLearn); err != nil || !learn.LearnModeStatus {
+		t.Fatalf("learn mode = %+v, err = %v", learn, err)
+	}
+	if _, ok := nextEvent(t, s).(event.COLrnModeDisabled); !ok || e.Learning() {
+		t.Fatal("learn mode did not time out")
+	}
+
+	reset, _ := commoncommand.NewWrReset()
+	if _, err := pkg.Exec(ctx, s, &reset); err != nil {
+		t.Fatal(err)
+	}
+	ready, ok := nextEvent(t, s).(event.COReady)
+	if !ok || ready.Cause != enums.WakeUpCauseRESET_BY_RESET_PIN {
+		t.Fatalf("event = %#v", ready)
+	}
+}
+
+// TestEmulatorRadioTraffic verifies EmulatorRadioTraffic behavior.
+func TestEmulatorRadioTraffic(t *testing.T) {
+	sent := make(chan erp1.Packet, 1)
+	e, s := newSession(t, Config{TxDone: true, OnTransmit: func(p erp1.Packet) { sent <- p }})
+	ctx := context.Background()
+
+	out := erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x10}, SenderID: e.BaseID(), DestinationID: deviceid.BroadcastId(), Status: 0x30}
+	if err := s.Transmit(ctx, out, pkg.TxOptions{WaitTxDone: true}); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-sent; p.SenderID != out.SenderID || !reflect.DeepEqual(p.UserData, out.UserData) {
+		t.Fatalf("sent = %+v", p)
+	}
+
+	add, _ := commoncommand.NewWrFilterAdd(enums.FilterCriterionRSSI, 0x50, true, false)
+	enable, _ := commoncommand.NewWrFilterEnable(true, enums.FilerOperatorOR_ALL_FILTERS)
+	for _, cmd := range []pkg.Command{&add, &enable} {
+		if _, err := s.Do(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	weak := erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x10}, SenderID: 0x01020304, DestinationID: deviceid.BroadcastId(), Status: 0x30, SubTelNum: 1, Rssi: 0x5a}
+	strong := weak
+	strong.Rssi = 0x3c
+	if ok, err := e.Receive(weak); ok || err != nil {
+		t.Fatalf("weak telegram passed the filter: %v", err)
+	}
+	if ok, err := e.Receive(strong); !ok || err != nil {
+		t.Fatalf("strong telegram blocked: %v", err)
+	}
+	select {
+	case p := <-s.Channels.ERP1:
+		if p.SenderID != 0x01020304 || p.Rssi != 0x3c {
+			t.Fatalf("received = %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no telegram received")
+	}
+}
+
+// TestEmulatorERP2Mode verifies EmulatorERP2Mode behavior.
+func TestEmulatorERP2Mode(t *testing.T) {
+	sent := make(chan erp1.Packet, 1)
+	mode, _ := commoncommand.NewWrMode(enums.RadioModeERP2)
+	e, s := newSession(t, Config{OnTransmit: func(p erp1.Packet) { sent <- p }})
+	ctx := context.Background()
+
+	if _, err := pkg.Exec(ctx, s, &mode); err != nil {
+		t.Fatal(err)
+	}
+	if s.RadioMode() != enums.RadioModeERP2 || e.RadioMode() != enums.RadioModeERP2 {
+		t.Fatalf("radio modes = %s, %s", s.RadioMode(), e.RadioMode())
+	}
+
+	out := erp1.Packet{Rorg: enums.RorgVLD, UserData: []byte{0x01, 0x64}, SenderID: e.BaseID(), DestinationID: 0x01020304}
+	if err := s.Transmit(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+	if p := <-sent; !reflect.DeepEqual(p, out) {
+		t.Fatalf("sent = %+v", p)
+	}
+
+	in := erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x00, 0x00, 0x80, 0x08}, SenderID: 0x01020304, DestinationID: deviceid.BroadcastId(), SubTelNum: 1, Rssi: 0x3c}
+	if ok, err := e.Receive(in); !ok || err != nil {
+		t.Fatalf("telegram not received: %v", err)
+	}
+	select {
+	case tel := <-s.Channels.ERP2:
+		if tel.AddressControl != erp2.AddressOriginator32 || tel.Type != erp2.Type4BS || tel.OriginatorID != 0x01020304 {
+			t.Fatalf("ERP2 telegram = %+v", tel)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no ERP2 telegram received")
+	}
+	if p := <-s.Channels.ERP1; !reflect.DeepEqual(p, in) {
+		t.Fatalf("received = %+v", p)
+	}
+
+	mode, _ = commoncommand.NewWrMode(enums.RadioModeERP1)
+	if _, err := pkg.Exec(ctx, s, &mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Transmit(ctx, out); err != nil || s.RadioMode() != enums.RadioModeERP1 {
+		t.Fatalf("ERP1 transmit after mode switch: %v", err)
+	}
+}
+
+// TestSessionStartsInERP2Mode verifies SessionStartsInERP2Mode behavior.
+func TestSessionStartsInERP2Mode(t *testing.T) {
+	ctx := context.Background()
+	out := erp1.Packet{Rorg: enums.RorgVLD, UserData: []byte{0x01, 0x64}, SenderID: defaultBaseID, DestinationID: 0x01020304}
+	in := erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x00, 0x00, 0x80, 0x08}, SenderID: 0x01020304, DestinationID: deviceid.BroadcastId(), SubTelNum: 1, Rssi: 0x3c}
+
+	e, s := newSession(t, Config{RadioMode: enums.RadioModeERP2}, pkg.SessionConfig{RadioMode: enums.RadioModeERP2})
+	if s.RadioMode() != enums.RadioModeERP2 {
+		t.Fatalf("radio mode = %s", s.RadioMode())
+	}
+	if err := s.Transmit(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+
+	mode, _ := commoncommand.NewWrMode(enums.RadioModeERP1)
+	if _, err := pkg.Exec(ctx, s, &mode); err != nil || s.RadioMode() != enums.RadioModeERP1 {
+		t.Fatalf("radio mode after CO_WR_MODE = %s, %v", s.RadioMode(), err)
+	}
+	if err := e.Reset(enums.WakeUpCauseRESET_BY_RESET_PIN); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := nextEvent(t, s).(event.COReady); !ok || s.RadioMode() != enums.RadioModeERP2 {
+		t.Fatalf("radio mode after reset = %s", s.RadioMode())
+	}
+	if err := s.Transmit(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the option, the first RADIO_ERP2 telegram reveals the mode.
+	e, s = newSession(t, Config{RadioMode: enums.RadioModeERP2})
+	if s.RadioMode() != enums.RadioModeERP1 {
+		t.Fatalf("radio mode = %s", s.RadioMode())
+	}
+	if ok, err := e.Receive(in); !ok || err != nil {
+		t.Fatalf("telegram not received: %v", err)
+	}
+	if p := receive(t, s); !reflect.DeepEqual(p, in) || s.RadioMode() != enums.RadioModeERP2 {
+		t.Fatalf("received %+v in radio mode %s", p, s.RadioMode())
+	}
+	if err := s.Transmit(ctx, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEmulatorFaults verifies EmulatorFaults behavior.
+func TestEmulatorFaults(t *testing.T) {
+	hooks := &crcHooks{}
+	e, s := newSession(t, Config{}, pkg.SessionConfig{Hooks: hooks})
+
+	p := erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x10}, SenderID: 0x01020304, DestinationID: deviceid.BroadcastId(), Status: 0x30}
+	for _, f := range []Fault{FaultCRC8H, FaultCRC8D, FaultDropByte} {
+		e.Fail(f)
+		if _, err := e.Receive(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.SendRaw([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := e.Receive(p); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-s.Channels.ERP1:
+		if got.SenderID != p.SenderID {
+			t.Fatalf("received = %+v", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no telegram received")
+	}
+	if n := hooks.count(); n != 3 {
+		t.Fatalf("CRC errors = %d, want 3", n)
+	}
+	select {
+	case got := <-s.Channels.ERP1:
+		t.Fatalf("corrupted telegram received: %+v", got)
+	default:
+	}
+}
+
+// TestEmulatorDutyCycleLimit verifies EmulatorDutyCycleLimit behavior.
+func TestEmulatorDutyCycleLimit(t *testing.T) {
+	sent := make(chan erp1.Packet, 1)
+	e, s := newSession(t, Config{OnTransmit: func(p erp1.Packet) { sent <- p }})
+
+	if err := e.LimitDutyCycle(true); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for !s.DutyCyclePaused() {
+		if time.Now().After(deadline) {
+			t.Fatal("queue not paused")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Transmit(context.Background(), erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x09}, SenderID: e.BaseID(), DestinationID: deviceid.BroadcastId()})
+	}()
+	select {
+	case err := <-result:
+		t.Fatalf("transmitted while limited: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := e.LimitDutyCycle(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	<-sent
+}
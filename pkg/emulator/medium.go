@@ -0,0 +1,353 @@
+package emulator
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+)
+
+// defaultRSSI is the attenuation of links unless configured: -70 dBm.
+const defaultRSSI = 70
+
+// Link describes the radio path between two nodes.
+type Link struct {
+	// Loss is the probability, from 0 to 1, that a telegram is lost.
+	Loss float64
+
+	// RSSI is the signal strength at the receiver, as ERP1 reports it:
+	// 70 is -70 dBm. Zero keeps the medium default.
+	RSSI uint8
+
+	// Latency delays every telegram on the link.
+	Latency time.Duration
+}
+
+// MediumConfig tunes a Medium. Zero fields keep their defaults.
+type MediumConfig struct {
+	// Link applies between nodes without a link of their own. Its RSSI
+	// defaults to 70 (-70 dBm).
+	Link Link
+
+	// Seed seeds the loss draws, so a run with the same seed and traffic
+	// loses the same telegrams.
+	Seed uint64
+}
+
+// Node is a participant of a Medium: a gateway, a Device or a Repeater.
+type Node interface {
+	radio() *node
+}
+
+// node is the medium side of a participant.
+type node struct {
+	medium  *Medium
+	name    string
+	receive func(erp1.Packet)
+}
+
+// String returns the name of the node.
+func (n *node) String() string {
+	return n.name
+}
+
+// linkKey identifies the link between two nodes.
+type linkKey struct {
+	a, b *node
+}
+
+// Medium is a simulated radio channel. Every telegram a node sends reaches
+// all other nodes, subject to the loss, RSSI and latency of their link.
+// Deliveries happen in order of arrival on a single goroutine. It is safe
+// for concurrent use.
+type Medium struct {
+	link Link
+
+	mu       sync.Mutex
+	nodes    []*node
+	links    map[linkKey]Link
+	rng      *rand.Rand
+	pending  deliveryHeap
+	seq      uint64
+	gateways []*Emulator
+
+	wake   chan struct{}
+	closed chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewMedium starts an empty medium.
+func NewMedium(cfg ...MediumConfig) *Medium {
+	c := MediumConfig{Link: Link{RSSI: defaultRSSI}}
+	for _, o := range cfg {
+		c.Link.Loss = o.Link.Loss
+		c.Link.Latency = o.Link.Latency
+		if o.Link.RSSI != 0 {
+			c.Link.RSSI = o.Link.RSSI
+		}
+		c.Seed = o.Seed
+	}
+
+	m := &Medium{
+		link:   c.Link,
+		links:  make(map[linkKey]Link),
+		rng:    rand.New(rand.NewPCG(c.Seed, c.Seed)),
+		wake:   make(chan struct{}, 1),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go m.run()
+	return m
+}
+
+// SetLink sets the link between a and b, in both directions.
+func (m *Medium) SetLink(a, b Node, l Link) {
+	if l.RSSI == 0 {
+		l.RSSI = m.link.RSSI
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links[linkKey{a.radio(), b.radio()}] = l
+	m.links[linkKey{b.radio(), a.radio()}] = l
+}
+
+// Close stops the deliveries and closes the gateways of the medium.
+// Telegrams still in flight are lost.
+func (m *Medium) Close() error {
+	m.once.Do(func() { close(m.closed) })
+	<-m.done
+
+	m.mu.Lock()
+	gateways := m.gateways
+	m.gateways = nil
+	m.mu.Unlock()
+
+	var err error
+	for _, e := range gateways {
+		if cerr := e.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// add registers a node receiving with receive.
+func (m *Medium) add(name string, receive func(erp1.Packet)) *node {
+	n := &node{medium: m, name: name, receive: receive}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nodes = append(m.nodes, n)
+	return n
+}
+
+// AddGateway starts an emulated transceiver on the medium. The host sends
+// and receives radio telegrams through it; with repeating enabled by
+// CO_WR_REPEATER, it also repeats what it hears. Close closes it.
+func (m *Medium) AddGateway(name string, cfg ...Config) *Emulator {
+	var n *node
+	onTransmit := func(p erp1.Packet) { m.transmit(n, p) }
+	for _, c := range cfg {
+		if c.OnTransmit != nil {
+			user := c.OnTransmit
+			onTransmit = func(p erp1.Packet) {
+				user(p)
+				m.transmit(n, p)
+			}
+		}
+	}
+
+	e := New(append(cfg, Config{OnTransmit: onTransmit})...)
+	n = m.add(name, func(p erp1.Packet) {
+		_, _ = e.Receive(p)
+
+		e.mu.Lock()
+		mode, level := e.repeaterMode, e.repeaterLevel
+		e.mu.Unlock()
+		if mode != enums.RepeaterModeOFF {
+			m.repeat(n, p, level)
+		}
+	})
+	e.node = n
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gateways = append(m.gateways, e)
+	return e
+}
+
+// Device is an emulated sensor or actuator on a medium.
+type Device struct {
+	node *node
+
+	// ID is the sender ID of the telegrams the device sends.
+	ID deviceid.DeviceID
+	// Profile is the EEP of the device, used by SendValues.
+	Profile eep.EEP
+}
+
+// AddDevice adds a device to the medium. handle, if set, receives every
+// telegram the device hears, e.g. to let an actuator answer with Send.
+func (m *Medium) AddDevice(id deviceid.DeviceID, profile eep.EEP, handle func(d *Device, p erp1.Packet)) *Device {
+	d := &Device{ID: id, Profile: profile}
+	d.node = m.add(id.String(), func(p erp1.Packet) {
+		if handle != nil {
+			handle(d, p)
+		}
+	})
+	return d
+}
+
+// Send transmits p from d. A zero sender or destination is filled in with
+// d.ID and the broadcast ID.
+func (d *Device) Send(p erp1.Packet) {
+	if p.SenderID == 0 {
+		p.SenderID = d.ID
+	}
+	if p.DestinationID == 0 {
+		p.DestinationID = deviceid.BroadcastId()
+	}
+	d.node.medium.transmit(d.node, p)
+}
+
+// SendValues encodes values with the profile of d and transmits them.
+func (d *Device) SendValues(values map[string]uint64) error {
+	userData, status, err := profiles.Encode(d.Profile, values)
+	if err != nil {
+		return fmt.Errorf("%s: %w", d.ID, err)
+	}
+	d.Send(erp1.Packet{Rorg: d.Profile.Rorg, UserData: userData, Status: status})
+	return nil
+}
+
+func (d *Device) radio() *node { return d.node }
+
+// Repeater is a standalone repeater on a medium.
+type Repeater struct {
+	node *node
+}
+
+// AddRepeater adds a repeater repeating telegrams up to level: level 1
+// repeats original telegrams only, level 2 also those repeated once.
+func (m *Medium) AddRepeater(name string, level enums.RepeaterLevel) *Repeater {
+	r := &Repeater{}
+	r.node = m.add(name, func(p erp1.Packet) { m.repeat(r.node, p, level) })
+	return r
+}
+
+func (r *Repeater) radio() *node { return r.node }
+
+func (e *Emulator) radio() *node { return e.node }
+
+// repeat sends p again from n with its repeater count incremented, unless
+// it has already been repeated level times.
+func (m *Medium) repeat(n *node, p erp1.Packet, level enums.RepeaterLevel) {
+	hops := p.RepeaterCount()
+	if hops >= byte(level) {
+		return
+	}
+	p.Status = p.Status&^erp1.RepeaterCountMask | (hops + 1)
+	m.transmit(n, p)
+}
+
+// transmit schedules the delivery of p from n to every other node.
+func (m *Medium) transmit(from *node, p erp1.Packet) {
+	now := time.Now()
+
+	m.mu.Lock()
+	for _, to := range m.nodes {
+		if to == from {
+			continue
+		}
+		l, ok := m.links[linkKey{from, to}]
+		if !ok {
+			l = m.link
+		}
+		if l.Loss > 0 && m.rng.Float64() < l.Loss {
+			continue
+		}
+
+		copied := p
+		copied.UserData = append([]byte(nil), p.UserData...)
+		if copied.SubTelNum == 0 {
+			copied.SubTelNum = 1 // every telegram on the air has at least one
+		}
+		copied.Rssi = l.RSSI
+		m.seq++
+		heap.Push(&m.pending, &delivery{due: now.Add(l.Latency), seq: m.seq, to: to, packet: copied})
+	}
+	m.mu.Unlock()
+
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the pending telegrams when they are due.
+func (m *Medium) run() {
+	defer close(m.done)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		m.mu.Lock()
+		var next *delivery
+		wait := time.Hour
+		if len(m.pending) > 0 {
+			if wait = time.Until(m.pending[0].due); wait <= 0 {
+				next = heap.Pop(&m.pending).(*delivery)
+			}
+		}
+		m.mu.Unlock()
+
+		if next != nil {
+			next.to.receive(next.packet)
+			continue
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-m.wake:
+		case <-m.closed:
+			return
+		}
+	}
+}
+
+// delivery is a telegram in flight.
+type delivery struct {
+	due    time.Time
+	seq    uint64
+	to     *node
+	packet erp1.Packet
+}
+
+// deliveryHeap orders deliveries by due time, then by transmission.
+type deliveryHeap []*delivery
+
+func (h deliveryHeap) Len() int { return len(h) }
+func (h deliveryHeap) Less(i, j int) bool {
+	if !h[i].due.Equal(h[j].due) {
+		return h[i].due.Before(h[j].due)
+	}
+	return h[i].seq < h[j].seq
+}
+func (h deliveryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *deliveryHeap) Push(x any)   { *h = append(*h, x.(*delivery)) }
+func (h *deliveryHeap) Pop() any {
+	old := *h
+	d := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return d
+}
@@ -0,0 +1,203 @@
+package emulator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+)
+
+// receive waits for the next ERP1 telegram of s.
+func receive(t *testing.T, s *pkg.Session) erp1.Packet {
+	t.Helper()
+	select {
+	case p := <-s.Channels.ERP1:
+		return p
+	case <-time.After(time.Second):
+		t.Fatal("no telegram received")
+		return erp1.Packet{}
+	}
+}
+
+// TestMediumRepeaterCopiesAreDeduplicated verifies MediumRepeaterCopiesAreDeduplicated behavior.
+func TestMediumRepeaterCopiesAreDeduplicated(t *testing.T) {
+	m := NewMedium()
+	defer m.Close()
+
+	profile, _ := eep.FromString("A5-02-05")
+	sensor := m.AddDevice(0x01a0b0c0, profile, nil)
+	repeater := m.AddRepeater("hall", enums.RepeaterLevel1_REPETITION)
+	near := m.AddGateway("near")
+	far := m.AddGateway("far")
+	m.SetLink(sensor, near, Link{RSSI: 50})
+	m.SetLink(sensor, far, Link{Loss: 1})
+	m.SetLink(repeater, far, Link{RSSI: 80, Latency: 10 * time.Millisecond})
+
+	nearSession := pkg.NewSession(context.Background(), near.Host(), pkg.SessionConfig{DedupWindow: 100 * time.Millisecond})
+	defer nearSession.Close()
+	farSession := pkg.NewSession(context.Background(), far.Host())
+	defer farSession.Close()
+
+	sent := time.Now()
+	if err := sensor.SendValues(map[string]uint64{"TMP": 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	direct := receive(t, nearSession)
+	if direct.SenderID != sensor.ID || direct.Rssi != 50 || direct.RepeaterCount() != 0 {
+		t.Fatalf("direct = %+v", direct)
+	}
+	repeated := receive(t, farSession)
+	if repeated.SenderID != sensor.ID || repeated.Rssi != 80 || repeated.RepeaterCount() != 1 {
+		t.Fatalf("repeated = %+v", repeated)
+	}
+	if elapsed := time.Since(sent); elapsed < 10*time.Millisecond {
+		t.Fatalf("repeated after %s, want at least the link latency", elapsed)
+	}
+
+	select {
+	case d := <-nearSession.Channels.Deduplicated:
+		if d.Copies != 2 || d.Hops != 0 || d.Packet.Rssi != 50 {
+			t.Fatalf("deduplicated = %+v", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no deduplicated telegram")
+	}
+	select {
+	case p := <-farSession.Channels.ERP1:
+		t.Fatalf("far gateway heard the sensor directly: %+v", p)
+	default:
+	}
+}
+
+// TestMediumActuatorAnswersGateway verifies MediumActuatorAnswersGateway behavior.
+func TestMediumActuatorAnswersGateway(t *testing.T) {
+	m := NewMedium()
+	defer m.Close()
+
+	gateway := m.AddGateway("gateway")
+	listener := m.AddGateway("listener")
+	profile, _ := eep.FromString("D2-01-00")
+	m.AddDevice(0x05000001, profile, func(d *Device, p erp1.Packet) {
+		if p.DestinationID == d.ID {
+			d.Send(erp1.Packet{Rorg: enums.RorgVLD, UserData: []byte{0x04, 0x60, 0x64}})
+		}
+	})
+
+	s := pkg.NewSession(context.Background(), gateway.Host())
+	defer s.Close()
+	other := pkg.NewSession(context.Background(), listener.Host())
+	defer other.Close()
+
+	command := erp1.Packet{Rorg: enums.RorgVLD, UserData: []byte{0x01, 0x00, 0x64}, SenderID: gateway.BaseID(), DestinationID: 0x05000001}
+	if err := s.Transmit(context.Background(), command); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := receive(t, s); status.SenderID != 0x05000001 || status.UserData[2] != 0x64 {
+		t.Fatalf("status = %+v", status)
+	}
+	if heard := receive(t, other); heard.SenderID != gateway.BaseID() || heard.DestinationID != 0x05000001 {
+		t.Fatalf("listener heard %+v", heard)
+	}
+	if heard := receive(t, other); heard.SenderID != 0x05000001 {
+		t.Fatalf("listener heard %+v", heard)
+	}
+}
+
+// TestMediumLossIsSeeded verifies MediumLossIsSeeded behavior.
+func TestMediumLossIsSeeded(t *testing.T) {
+	run := func() []bool {
+		m := NewMedium(MediumConfig{Link: Link{Loss: 0.5}, Seed: 7})
+		defer m.Close()
+
+		heard := make(chan erp1.Packet, 64)
+		sensor := m.AddDevice(0x01000001, eep.EEP{}, nil)
+		m.AddDevice(0x01000002, eep.EEP{}, func(_ *Device, p erp1.Packet) { heard <- p })
+
+		received := make([]bool, 32)
+		for i := range received {
+			sensor.Send(erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{byte(i)}})
+		}
+		deadline := time.After(100 * time.Millisecond)
+		for {
+			select {
+			case p := <-heard:
+				received[p.UserData[0]] = true
+			case <-deadline:
+				return received
+			}
+		}
+	}
+
+	first, second := run(), run()
+	lost := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("telegram %d: %v then %v", i, first[i], second[i])
+		}
+		if !first[i] {
+			lost++
+		}
+	}
+	if lost == 0 || lost == len(first) {
+		t.Fatalf("lost %d of %d telegrams", lost, len(first))
+	}
+}
+
+// TestMediumGatewayRepeats verifies MediumGatewayRepeats behavior.
+func TestMediumGatewayRepeats(t *testing.T) {
+	m := NewMedium()
+	defer m.Close()
+
+	heard := make(chan erp1.Packet, 4)
+	sensor := m.AddDevice(0x01000001, eep.EEP{}, nil)
+	gateway := m.AddGateway("gateway")
+	m.AddDevice(0x01000002, eep.EEP{}, func(_ *Device, p erp1.Packet) { heard <- p })
+
+	s := pkg.NewSession(context.Background(), gateway.Host())
+	defer s.Close()
+	repeater, _ := commoncommand.NewWrRepeater(enums.RepeaterModeON, enums.RepeaterLevel1_REPETITION)
+	if _, err := pkg.Exec(context.Background(), s, &repeater); err != nil {
+		t.Fatal(err)
+	}
+
+	sensor.Send(erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x09}})
+	hops := map[byte]bool{}
+	for range 2 {
+		select {
+		case p := <-heard:
+			hops[p.RepeaterCount()] = true
+		case <-time.After(time.Second):
+			t.Fatal("telegram missing")
+		}
+	}
+	if !hops[0] || !hops[1] {
+		t.Fatalf("hops = %v", hops)
+	}
+}
+
+// TestMediumKeepsSubTelNum verifies MediumKeepsSubTelNum behavior.
+func TestMediumKeepsSubTelNum(t *testing.T) {
+	m := NewMedium()
+	defer m.Close()
+
+	sensor := m.AddDevice(0x01000001, eep.EEP{}, nil)
+	gateway := m.AddGateway("gateway")
+	s := pkg.NewSession(context.Background(), gateway.Host())
+	defer s.Close()
+
+	sensor.Send(erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x09}, SubTelNum: 3})
+	if p := receive(t, s); p.SubTelNum != 3 {
+		t.Fatalf("SubTelNum = %d, want 3", p.SubTelNum)
+	}
+	sensor.Send(erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x09}})
+	if p := receive(t, s); p.SubTelNum != 1 {
+		t.Fatalf("SubTelNum = %d, want 1 when unset", p.SubTelNum)
+	}
+}
@@ -0,0 +1,179 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"slices"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// smartAckClient is an entry of the emulated learned clients table.
+type smartAckClient struct {
+	client     deviceid.DeviceID
+	postmaster deviceid.DeviceID
+	mailbox    uint8
+}
+
+// smartAckCommand answers a SMART_ACK_COMMAND of the host.
+func (e *Emulator) smartAckCommand(t esp3.Telegram) {
+	if len(t.Data) == 0 {
+		e.respond(enums.ReturnCodeWRONG_ARGUMENT, nil, nil)
+		return
+	}
+	e.respond(e.smartAck(enums.SmartAckCommand(t.Data[0]), t.Data[1:]))
+}
+
+// smartAck handles the Smart Ack commands and returns their RESPONSE.
+func (e *Emulator) smartAck(command enums.SmartAckCommand, args []byte) (enums.ReturnCode, []byte, []byte) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch command {
+	case enums.SmartAckCommandWR_LEARN_MODE:
+		if len(args) < 6 || !enums.SmartAckLearnMode(args[1]).Valid() {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.stopSmartAckLearning()
+		if args[0] == 0 {
+			return enums.ReturnCodeSUCCESS, nil, nil
+		}
+		timeout := time.Duration(binary.BigEndian.Uint32(args[2:])) * time.Millisecond
+		if timeout == 0 {
+			timeout = defaultLearnTimeout
+		}
+		e.smartAckLearning, e.smartAckMode = true, enums.SmartAckLearnMode(args[1])
+		var timer *time.Timer
+		timer = time.AfterFunc(timeout, func() {
+			e.mu.Lock()
+			defer e.mu.Unlock()
+			if e.smartAckTimer == timer {
+				e.smartAckLearning, e.smartAckTimer = false, nil
+			}
+		})
+		e.smartAckTimer = timer
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.SmartAckCommandRD_LEARN_MODE:
+		return enums.ReturnCodeSUCCESS, []byte{boolByte(e.smartAckLearning), byte(e.smartAckMode)}, nil
+	case enums.SmartAckCommandWR_LEARN_CONFIRM:
+		if len(args) < 11 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		code := enums.LearnAckConfirmCode(args[2])
+		postmaster := deviceid.DeviceID(binary.BigEndian.Uint32(args[3:]))
+		client := deviceid.DeviceID(binary.BigEndian.Uint32(args[7:]))
+		switch code {
+		case enums.LearnAckConfirmCodeLRN_IN:
+			e.learnInSmartAckClient(client, postmaster)
+		case enums.LearnAckConfirmCodeLRN_OUT:
+			i := e.smartAckClientIndex(client)
+			if i < 0 {
+				return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+			}
+			e.smartAckClients = slices.Delete(e.smartAckClients, i, i+1)
+		default:
+			if !code.Valid() {
+				return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+			}
+		}
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.SmartAckCommandWR_RESET:
+		if len(args) < deviceid.DeviceIDSize || e.smartAckClientIndex(deviceid.DeviceID(binary.BigEndian.Uint32(args))) < 0 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.SmartAckCommandWR_RD_LEARNED_CLIENTS:
+		var data []byte
+		for _, c := range e.smartAckClients {
+			data = binary.BigEndian.AppendUint32(data, uint32(c.client))
+			data = binary.BigEndian.AppendUint32(data, uint32(c.postmaster))
+			data = append(data, c.mailbox)
+		}
+		return enums.ReturnCodeSUCCESS, data, nil
+	case enums.SmartAckCommandWR_WR_POSTMASTER:
+		if len(args) < 1 {
+			return enums.ReturnCodeWRONG_ARGUMENT, nil, nil
+		}
+		e.mailboxCount = args[0]
+		return enums.ReturnCodeSUCCESS, nil, nil
+	case enums.SmartAckCommandWR_CLIENT_LEARN_RQ, enums.SmartAckCommandWR_RECLAIMS:
+		return enums.ReturnCodeSUCCESS, nil, nil
+	default:
+		return enums.ReturnCodeNOT_SUPPORTED, nil, nil
+	}
+}
+
+// learnInSmartAckClient adds client to the learned clients table with the
+// lowest mailbox index free at postmaster. A client learned in again keeps
+// its entry. The caller holds e.mu.
+func (e *Emulator) learnInSmartAckClient(client, postmaster deviceid.DeviceID) {
+	if e.smartAckClientIndex(client) >= 0 {
+		return
+	}
+	used := map[uint8]bool{}
+	for _, c := range e.smartAckClients {
+		if c.postmaster == postmaster {
+			used[c.mailbox] = true
+		}
+	}
+	var mailbox uint8
+	for used[mailbox] {
+		mailbox++
+	}
+	e.smartAckClients = append(e.smartAckClients, smartAckClient{client: client, postmaster: postmaster, mailbox: mailbox})
+}
+
+// smartAckClientIndex returns the index of client in the learned clients
+// table, or -1. The caller holds e.mu.
+func (e *Emulator) smartAckClientIndex(client deviceid.DeviceID) int {
+	return slices.IndexFunc(e.smartAckClients, func(c smartAckClient) bool { return c.client == client })
+}
+
+// stopSmartAckLearning leaves Smart Ack learn mode. The caller holds e.mu.
+func (e *Emulator) stopSmartAckLearning() {
+	if e.smartAckTimer != nil {
+		e.smartAckTimer.Stop()
+		e.smartAckTimer = nil
+	}
+	e.smartAckLearning = false
+}
+
+// SmartAckLearning reports whether Smart Ack learn mode is active.
+func (e *Emulator) SmartAckLearning() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.smartAckLearning
+}
+
+// SmartAckClients returns the IDs of the learned Smart Ack clients in the
+// order they were learned in.
+func (e *Emulator) SmartAckClients() []deviceid.DeviceID {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ids := make([]deviceid.DeviceID, 0, len(e.smartAckClients))
+	for _, c := range e.smartAckClients {
+		ids = append(ids, c.client)
+	}
+	return ids
+}
+
+// SmartAckLearnRequest simulates the learn request of a Smart Ack client
+// received with rssi, relayed by the transceiver itself as postmaster
+// candidate. Outside of Smart Ack learn mode the request is ignored; it
+// reports whether SA_CONFIRM_LEARN was sent to the host.
+func (e *Emulator) SmartAckLearnRequest(client deviceid.DeviceID, manufacturerID uint16, profile eep.EEP, rssi byte) (bool, error) {
+	if !e.SmartAckLearning() {
+		return false, nil
+	}
+
+	data := []byte{0x00}
+	data = binary.BigEndian.AppendUint16(data, manufacturerID&0x07ff)
+	data = append(data, byte(profile.Rorg), profile.Func, profile.Type, rssi)
+	data = binary.BigEndian.AppendUint32(data, e.cfg.ChipID)
+	data = binary.BigEndian.AppendUint32(data, uint32(client))
+	data = append(data, 0x00)
+	return true, e.Event(enums.EventCodeSA_CONFIRM_LEARN, data, nil)
+}
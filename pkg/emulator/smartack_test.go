@@ -0,0 +1,86 @@
+package emulator
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/smartackcommand"
+)
+
+// TestEmulatorSmartAckCommands verifies EmulatorSmartAckCommands behavior.
+func TestEmulatorSmartAckCommands(t *testing.T) {
+	e, s := newSession(t, Config{})
+	ctx := context.Background()
+	profile := eep.EEP{Rorg: enums.Rorg4BS, Func: 0x02, Type: 0x05}
+
+	if ok, err := e.SmartAckLearnRequest(0x01020304, 0x00b, profile, 0x40); ok || err != nil {
+		t.Fatalf("learn request outside learn mode sent: %v", err)
+	}
+
+	wrLearn, _ := smartackcommand.NewWrLearnMode(true, enums.SmartAckLearnModeADVANCED, 0)
+	if _, err := pkg.Exec(ctx, s, &wrLearn); err != nil {
+		t.Fatal(err)
+	}
+	rdLearn, _ := smartackcommand.NewRdLearnMode()
+	if learn, err := pkg.Exec(ctx, s, &rdLearn); err != nil || !learn.Enabled || learn.Extended != enums.SmartAckLearnModeADVANCED {
+		t.Fatalf("learn mode = %+v, err = %v", learn, err)
+	}
+
+	if ok, err := e.SmartAckLearnRequest(0x01020304, 0x00b, profile, 0x40); !ok || err != nil {
+		t.Fatalf("learn request not sent: %v", err)
+	}
+	confirm, ok := nextEvent(t, s).(event.SAConfirmLearn)
+	if !ok || confirm.SmartACKClientID != 0x01020304 || confirm.PostmasterCandidateID != defaultChipID || confirm.EEP != profile || confirm.ManufacturerID != 0x00b || confirm.Rssi != 0x40 {
+		t.Fatalf("event = %+v", confirm)
+	}
+
+	for _, client := range []deviceid.DeviceID{0x01020304, 0x01020305} {
+		learnIn, _ := smartackcommand.NewWrLearnConfirm(150, enums.LearnAckConfirmCodeLRN_IN, deviceid.DeviceID(defaultChipID), client)
+		if _, err := pkg.Exec(ctx, s, &learnIn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	learnOut, _ := smartackcommand.NewWrLearnConfirm(0, enums.LearnAckConfirmCodeLRN_OUT, deviceid.DeviceID(defaultChipID), 0x01020304)
+	if _, err := pkg.Exec(ctx, s, &learnOut); err != nil {
+		t.Fatal(err)
+	}
+	learnIn, _ := smartackcommand.NewWrLearnConfirm(150, enums.LearnAckConfirmCodeLRN_IN, deviceid.DeviceID(defaultChipID), 0x01020306)
+	if _, err := pkg.Exec(ctx, s, &learnIn); err != nil {
+		t.Fatal(err)
+	}
+
+	rdClients, _ := smartackcommand.NewRdLearnedClients()
+	clients, err := pkg.Exec(ctx, s, &rdClients)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []smartackcommand.LearnedClient{
+		{ClientID: 0x01020305, PostmasterID: deviceid.DeviceID(defaultChipID), MailboxIndex: 1},
+		{ClientID: 0x01020306, PostmasterID: deviceid.DeviceID(defaultChipID), MailboxIndex: 0},
+	}
+	if !reflect.DeepEqual(clients.Clients, want) {
+		t.Fatalf("clients = %+v", clients.Clients)
+	}
+
+	var codeErr *smartackcommand.ReturnCodeError
+	if _, err := pkg.Exec(ctx, s, &learnOut); !errors.As(err, &codeErr) || codeErr.Code != enums.ReturnCodeWRONG_ARGUMENT {
+		t.Fatalf("second LRN_OUT err = %v", err)
+	}
+
+	if err := e.Reset(enums.WakeUpCauseRESET_BY_RESET_PIN); err != nil {
+		t.Fatal(err)
+	}
+	if e.SmartAckLearning() {
+		t.Fatal("Smart Ack learn mode survived a reset")
+	}
+	if got := e.SmartAckClients(); !reflect.DeepEqual(got, []deviceid.DeviceID{0x01020305, 0x01020306}) {
+		t.Fatalf("clients after reset = %v", got)
+	}
+}
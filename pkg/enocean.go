@@ -5,16 +5,31 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/edlundin/enocean-esp3/pkg/command24"
+	"github.com/edlundin/enocean-esp3/pkg/commandaccepted"
+	"github.com/edlundin/enocean-esp3/pkg/configcommand"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
 	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
 	"github.com/edlundin/enocean-esp3/pkg/event"
 	"github.com/edlundin/enocean-esp3/pkg/gp"
+	"github.com/edlundin/enocean-esp3/pkg/linkstats"
+	"github.com/edlundin/enocean-esp3/pkg/radio802154"
+	"github.com/edlundin/enocean-esp3/pkg/radioerp2"
+	"github.com/edlundin/enocean-esp3/pkg/radiomessage"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
 	"github.com/edlundin/enocean-esp3/pkg/reman"
+	"github.com/edlundin/enocean-esp3/pkg/remancommand"
 	"github.com/edlundin/enocean-esp3/pkg/response"
 	"github.com/edlundin/enocean-esp3/pkg/smartack"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
 	"go.bug.st/serial"
 )
 
@@ -22,64 +37,143 @@ import (
 func GetSerialPortList() ([]string, error) { return serial.GetPortsList() }
 
 type Message struct {
-	Kind string
+	Kind MessageKind
 	ESP3 esp3.Telegram
 	ERP1 *erp1.Packet
 	Data any
 	Err  error
 }
 
-// Channels contains independent, buffered, best-effort event streams.
-// A message is dropped from an individual stream when that stream's buffer is
-// full; slow consumers do not block serial parsing or other streams.
+// Channels contains independent, buffered event streams. By default a
+// message is dropped from an individual stream when that stream's buffer is
+// full, so slow consumers do not block serial parsing or other streams;
+// StreamConfig selects other overflow policies. Dropped reports the losses.
 type Channels struct {
-	All        <-chan Message
-	ESP3       <-chan esp3.Telegram
-	ERP1       <-chan erp1.Packet
-	Response   <-chan response.Packet
-	Event      <-chan event.Event
-	SmartAck   <-chan smartack.Message
-	ReMan      <-chan reman.Message
-	ReManPart  <-chan reman.Part
-	GPHeader   <-chan any
-	Unparsed   <-chan Message
-	ParseError <-chan Message
+	All          <-chan Message
+	ESP3         <-chan esp3.Telegram
+	ERP1         <-chan erp1.Packet
+	Response     <-chan response.Packet
+	Event        <-chan event.Event
+	SmartAck     <-chan smartack.Message
+	ReMan        <-chan reman.Message
+	ReManPart    <-chan reman.Part
+	GPHeader     <-chan any
+	Unparsed     <-chan Message
+	ParseError   <-chan Message
+	Decoded      <-chan Decoded
+	Deduplicated <-chan Deduplicated
+
+	SubTel          <-chan subtel.Packet
+	ReManCommand    <-chan remancommand.Packet
+	RadioMessage    <-chan radiomessage.Packet
+	RadioERP2       <-chan radioerp2.Packet
+	ConfigCommand   <-chan configcommand.Packet
+	CommandAccepted <-chan commandaccepted.Packet
+	Radio802154     <-chan radio802154.Packet
+	Command24       <-chan command24.Packet
+	ERP2            <-chan erp2.Telegram
+
+	drops *dropCounters
 }
 
 type channelSet struct {
-	all        chan Message
-	esp3       chan esp3.Telegram
-	erp1       chan erp1.Packet
-	response   chan response.Packet
-	event      chan event.Event
-	smartAck   chan smartack.Message
-	reman      chan reman.Message
-	remanPart  chan reman.Part
-	gpHeader   chan any
-	unparsed   chan Message
-	parseError chan Message
+	onResponse func(response.Packet)
+	onMessage  func(Message)
+	onEvent    func(event.Event)
+	// onRadioMode receives the radio mode each received radio telegram
+	// was sent in.
+	onRadioMode func(enums.RadioMode)
+	devices     *registry.Registry
+	stats       *linkstats.Collector
+	dedup       *dedupStage
+	publishMu   sync.Mutex
+	closing     bool // set under publishMu once close starts flushing
+	logger      *slog.Logger
+	hooks       Hooks
+	overflows   [streamCount]Overflow
+	drops       dropCounters
+
+	all          chan Message
+	esp3         chan esp3.Telegram
+	erp1         chan erp1.Packet
+	response     chan response.Packet
+	event        chan event.Event
+	smartAck     chan smartack.Message
+	reman        chan reman.Message
+	remanPart    chan reman.Part
+	gpHeader     chan any
+	unparsed     chan Message
+	parseError   chan Message
+	decoded      chan Decoded
+	deduplicated chan Deduplicated
+
+	subTel          chan subtel.Packet
+	remanCommand    chan remancommand.Packet
+	radioMessage    chan radiomessage.Packet
+	radioERP2       chan radioerp2.Packet
+	configCommand   chan configcommand.Packet
+	commandAccepted chan commandaccepted.Packet
+	radio802154     chan radio802154.Packet
+	command24       chan command24.Packet
+	erp2            chan erp2.Telegram
 }
 
-// newChannelSet constructs ChannelSet.
+// newChannelSet constructs a ChannelSet whose streams all buffer size values.
 func newChannelSet(size int) (*channelSet, *Channels) {
+	sizes := make(map[Stream]int, streamCount)
+	for stream := range streamCount {
+		sizes[stream] = size
+	}
+	return newChannelSetFromConfig(StreamConfig{BufferSizes: sizes})
+}
+
+// newChannelSetFromConfig constructs a ChannelSet from cfg.
+func newChannelSetFromConfig(cfg StreamConfig) (*channelSet, *Channels) {
 	set := &channelSet{
-		all:        make(chan Message, size),
-		esp3:       make(chan esp3.Telegram, size),
-		erp1:       make(chan erp1.Packet, size),
-		response:   make(chan response.Packet, size),
-		event:      make(chan event.Event, size),
-		smartAck:   make(chan smartack.Message, size),
-		reman:      make(chan reman.Message, size),
-		remanPart:  make(chan reman.Part, size),
-		gpHeader:   make(chan any, size),
-		unparsed:   make(chan Message, size),
-		parseError: make(chan Message, size),
+		logger:       slog.New(slog.DiscardHandler),
+		hooks:        NopHooks{},
+		all:          make(chan Message, cfg.bufferSize(StreamAll)),
+		esp3:         make(chan esp3.Telegram, cfg.bufferSize(StreamESP3)),
+		erp1:         make(chan erp1.Packet, cfg.bufferSize(StreamERP1)),
+		response:     make(chan response.Packet, cfg.bufferSize(StreamResponse)),
+		event:        make(chan event.Event, cfg.bufferSize(StreamEvent)),
+		smartAck:     make(chan smartack.Message, cfg.bufferSize(StreamSmartAck)),
+		reman:        make(chan reman.Message, cfg.bufferSize(StreamReMan)),
+		remanPart:    make(chan reman.Part, cfg.bufferSize(StreamReManPart)),
+		gpHeader:     make(chan any, cfg.bufferSize(StreamGPHeader)),
+		unparsed:     make(chan Message, cfg.bufferSize(StreamUnparsed)),
+		parseError:   make(chan Message, cfg.bufferSize(StreamParseError)),
+		decoded:      make(chan Decoded, cfg.bufferSize(StreamDecoded)),
+		deduplicated: make(chan Deduplicated, cfg.bufferSize(StreamDeduplicated)),
+
+		subTel:          make(chan subtel.Packet, cfg.bufferSize(StreamSubTel)),
+		remanCommand:    make(chan remancommand.Packet, cfg.bufferSize(StreamReManCommand)),
+		radioMessage:    make(chan radiomessage.Packet, cfg.bufferSize(StreamRadioMessage)),
+		radioERP2:       make(chan radioerp2.Packet, cfg.bufferSize(StreamRadioERP2)),
+		configCommand:   make(chan configcommand.Packet, cfg.bufferSize(StreamConfigCommand)),
+		commandAccepted: make(chan commandaccepted.Packet, cfg.bufferSize(StreamCommandAccepted)),
+		radio802154:     make(chan radio802154.Packet, cfg.bufferSize(StreamRadio802154)),
+		command24:       make(chan command24.Packet, cfg.bufferSize(StreamCommand24)),
+		erp2:            make(chan erp2.Telegram, cfg.bufferSize(StreamERP2)),
+	}
+	for stream := range streamCount {
+		set.overflows[stream] = cfg.overflow(stream)
 	}
-	return set, &Channels{All: set.all, ESP3: set.esp3, ERP1: set.erp1, Response: set.response, Event: set.event, SmartAck: set.smartAck, ReMan: set.reman, ReManPart: set.remanPart, GPHeader: set.gpHeader, Unparsed: set.unparsed, ParseError: set.parseError}
+	return set, &Channels{All: set.all, ESP3: set.esp3, ERP1: set.erp1, Response: set.response, Event: set.event, SmartAck: set.smartAck, ReMan: set.reman, ReManPart: set.remanPart, GPHeader: set.gpHeader, Unparsed: set.unparsed, ParseError: set.parseError, Decoded: set.decoded, Deduplicated: set.deduplicated,
+		SubTel: set.subTel, ReManCommand: set.remanCommand, RadioMessage: set.radioMessage, RadioERP2: set.radioERP2, ConfigCommand: set.configCommand, CommandAccepted: set.commandAccepted, Radio802154: set.radio802154, Command24: set.command24, ERP2: set.erp2,
+		drops: &set.drops}
 }
 
-// close closes all parser output channels.
+// close flushes pending deduplicated telegrams and closes all parser output
+// channels. The flush neither blocks nor heeds cancellation, as the parser
+// context is usually cancelled by then.
 func (c *channelSet) close() {
+	c.publishMu.Lock()
+	c.closing = true
+	c.publishMu.Unlock()
+	if c.dedup != nil {
+		c.dedup.flush(c)
+	}
 	close(c.all)
 	close(c.esp3)
 	close(c.erp1)
@@ -91,12 +185,36 @@ func (c *channelSet) close() {
 	close(c.gpHeader)
 	close(c.unparsed)
 	close(c.parseError)
+	close(c.decoded)
+	close(c.deduplicated)
+	close(c.subTel)
+	close(c.remanCommand)
+	close(c.radioMessage)
+	close(c.radioERP2)
+	close(c.configCommand)
+	close(c.commandAccepted)
+	close(c.radio802154)
+	close(c.command24)
+	close(c.erp2)
 }
 
 var serialOpen = serial.Open
 
 // OpenSerialPort opens a serial port and starts ESP3 parsing.
 func OpenSerialPort(ctx context.Context, portPath string) (serial.Port, *Channels, error) {
+	port, err := openSerial(portPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	set, channels := newChannelSetFromConfig(StreamConfig{})
+	go parser(ctx, port, set)
+
+	return port, channels, nil
+}
+
+// openSerial opens a serial port with the ESP3 line settings.
+func openSerial(portPath string) (serial.Port, error) {
 	portSettings := &serial.Mode{
 		BaudRate: 57600,
 		DataBits: 8,
@@ -107,25 +225,28 @@ func OpenSerialPort(ctx context.Context, portPath string) (serial.Port, *Channel
 	port, err := serialOpen(portPath, portSettings)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	err = port.SetReadTimeout(time.Second * 2)
+	err = port.SetReadTimeout(readTimeout)
 
 	if err != nil {
 		_ = port.Close()
-		return nil, nil, err
+		return nil, err
 	}
 
-	set, channels := newChannelSet(64)
-	go parser(ctx, port, set)
-
-	return port, channels, nil
+	return port, nil
 }
 
-// parser parses r.
-func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
+// parser parses r and closes channels once it stops.
+func parser(ctx context.Context, r io.Reader, channels *channelSet) {
 	defer channels.close()
+	_ = parse(ctx, r, channels, newReManAssembler(remanChainPeriod))
+}
+
+// parse runs the ESP3 state machine over r until ctx is cancelled or r fails.
+// It returns the read error, if any.
+func parse(ctx context.Context, r io.Reader, channels *channelSet, remanMessages *remanAssembler) error {
 	type ParserState uint8
 
 	const (
@@ -153,25 +274,29 @@ func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
 	parserDataLen := 0
 	parserOptDataLen := 0
 	parserPacketType := uint8(0)
-	remanMessages := newReManAssembler(remanChainPeriod)
+	// parserFrame holds every byte of the current frame from its sync byte,
+	// skipped the bytes dropped while searching for one.
+	var parserFrame, skipped []byte
 
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		default:
-			byteReceived, err := serialPort.Read(readBuffer)
+			byteReceived, err := readChunk(r, readBuffer)
 
 			if err != nil {
-				return
+				return err
 			}
 
 			if byteReceived == 0 {
 				continue
 			}
 
-			if time.Since(lastByteReceivedTime) >= interByteTimeout {
+			if time.Since(lastByteReceivedTime) >= interByteTimeout && parserState != ParserStateWaitingForSyncByte {
+				channels.interByteTimeout(parserFrame)
 				parserState = ParserStateWaitingForSyncByte
+				parserFrame = nil
 			}
 
 			for i := 0; i < byteReceived; i++ {
@@ -179,12 +304,20 @@ func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
 
 				switch parserState {
 				case ParserStateWaitingForSyncByte:
-					if parserByte == syncByte {
-						parserState = ParserStateWaitingForHeader
-						parserBuffer = make([]uint8, 0)
-						parserCrc = 0
+					if parserByte != syncByte {
+						skipped = append(skipped, parserByte)
+						break
+					}
+					if len(skipped) > 0 {
+						channels.resync(skipped)
+						skipped = nil
 					}
+					parserState = ParserStateWaitingForHeader
+					parserFrame = []byte{syncByte}
+					parserBuffer = make([]uint8, 0)
+					parserCrc = 0
 				case ParserStateWaitingForHeader:
+					parserFrame = append(parserFrame, parserByte)
 					parserBuffer = append(parserBuffer, parserByte)
 					parserCrc = esp3.ComputeCrc8(parserByte, parserCrc)
 
@@ -192,24 +325,36 @@ func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
 						parserState = ParserStateWaitingForCrc8H
 					}
 				case ParserStateWaitingForCrc8H:
+					parserFrame = append(parserFrame, parserByte)
+
 					// CRC8H invalid
 					if parserCrc != parserByte {
+						crcErr := CRCError{Field: CRCFieldHeader, Want: parserCrc, Got: parserByte, Covered: bytes.Clone(parserBuffer), Dropped: parserFrame}
 						syncByteIdx := bytes.IndexByte(parserBuffer, syncByte)
 
 						// Header and CRC8H does not contain the sync code, wait for new packet to start
 						if syncByteIdx < 0 && parserByte != syncByte {
+							channels.crcError(crcErr)
 							parserState = ParserStateWaitingForSyncByte
+							parserFrame = nil
 							break
 						}
 
 						// Header does not have sync code but CRC8H does, reset state, this is a new packet
 						if syncByteIdx < 0 {
+							crcErr.Dropped = parserFrame[:len(parserFrame)-1]
+							channels.crcError(crcErr)
 							parserState = ParserStateWaitingForHeader
+							parserFrame = []byte{syncByte}
 							parserBuffer = make([]uint8, 0)
 							parserCrc = 0
 							break
 						}
 
+						// parserFrame[0] is the sync byte, so the inner one is at syncByteIdx+1
+						crcErr.Dropped = parserFrame[:syncByteIdx+1]
+						channels.crcError(crcErr)
+						parserFrame = bytes.Clone(parserFrame[syncByteIdx+1:])
 						parserBuffer = append(parserBuffer[:0], parserBuffer[syncByteIdx+1:]...)
 						parserBuffer = append(parserBuffer, parserByte)
 						parserCrc = esp3.ComputeCrcSlice(parserBuffer)
@@ -233,6 +378,7 @@ func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
 					parserBuffer = make([]uint8, 0)
 					parserCrc = 0
 				case ParserStateWaitingForData:
+					parserFrame = append(parserFrame, parserByte)
 					parserBuffer = append(parserBuffer, parserByte)
 					parserCrc = esp3.ComputeCrc8(parserByte, parserCrc)
 
@@ -241,23 +387,35 @@ func parser(ctx context.Context, serialPort serial.Port, channels *channelSet) {
 					}
 				case ParserStateWaitingForCrc8D:
 					parserState = ParserStateWaitingForSyncByte
+					frame := append(parserFrame, parserByte)
+					parserFrame = nil
 					if parserByte != parserCrc {
+						crcErr := CRCError{Field: CRCFieldData, Want: parserCrc, Got: parserByte, Covered: bytes.Clone(parserBuffer), Dropped: frame}
 						if parserByte == syncByte {
+							crcErr.Dropped = frame[:len(frame)-1]
 							parserState = ParserStateWaitingForHeader
+							parserFrame = []byte{syncByte}
 							parserBuffer = make([]uint8, 0)
 							parserCrc = 0
 						}
+						channels.crcError(crcErr)
 						break
 					}
 
+					frameReceived := time.Now()
+					channels.hooks.Frame(frame)
+
 					packetType, err := enums.ParsePacketTypeFromByte(parserPacketType)
 					if err != nil {
+						channels.unknownPacketType(parserPacketType)
 						break
 					}
 
-					if !publish(ctx, channels, parseTelegram(remanMessages, esp3.NewTelegramFromData(packetType, parserBuffer[:parserDataLen], parserBuffer[parserDataLen:]))) {
-						return
+					telegram := esp3.NewTelegramFromData(packetType, parserBuffer[:parserDataLen], parserBuffer[parserDataLen:])
+					if !channels.process(ctx, remanMessages, telegram) {
+						return nil
 					}
+					channels.dispatched(telegram, time.Since(frameReceived))
 				default:
 					parserState = ParserStateWaitingForSyncByte
 				}
@@ -315,7 +473,7 @@ func (a *remanAssembler) add(part reman.Part) ([]Message, error) {
 		return nil, nil
 	}
 	delete(a.buffers, key)
-	return []Message{{Kind: "reman", Data: msg}}, nil
+	return []Message{{Kind: MessageKindReMan, Data: msg}}, nil
 }
 
 // expire removes stale ReMan chains.
@@ -329,47 +487,138 @@ func (a *remanAssembler) expire(now time.Time) {
 
 // parseTelegram parses Telegram.
 func parseTelegram(remanMessages *remanAssembler, t esp3.Telegram) []Message {
-	messages := []Message{{Kind: "esp3", ESP3: t, Data: t}}
+	messages := []Message{{Kind: MessageKindESP3, ESP3: t, Data: t}}
 
 	switch t.PacketType {
 	case enums.PacketTypeRADIO_ERP1:
 		p, err := erp1.NewPacketFromEsp3(t)
 		if err != nil {
-			return append(messages, Message{Kind: "parse_error", ESP3: t, Err: err})
+			return append(messages, Message{Kind: MessageKindParseError, ESP3: t, Err: err})
 		}
-		messages = append(messages, Message{Kind: "erp1", ESP3: t, ERP1: &p, Data: p})
+		messages = append(messages, Message{Kind: MessageKindERP1, ESP3: t, ERP1: &p, Data: p})
 		messages = append(messages, parseERP1(remanMessages, t, p)...)
 	case enums.PacketTypeRESPONSE:
 		p, err := response.NewPacketFromEsp3(t)
 		if err != nil {
-			return append(messages, Message{Kind: "parse_error", ESP3: t, Err: err})
+			return append(messages, Message{Kind: MessageKindParseError, ESP3: t, Err: err})
 		}
-		messages = append(messages, Message{Kind: "response", ESP3: t, Data: p})
+		messages = append(messages, Message{Kind: MessageKindResponse, ESP3: t, Data: p})
 	case enums.PacketTypeEVENT:
 		p, err := event.NewPacketFromEsp3(t)
 		if err != nil {
-			return append(messages, Message{Kind: "parse_error", ESP3: t, Err: err})
+			return append(messages, Message{Kind: MessageKindParseError, ESP3: t, Err: err})
 		}
-		messages = append(messages, Message{Kind: "event", ESP3: t, Data: p})
+		messages = append(messages, Message{Kind: MessageKindEvent, ESP3: t, Data: p})
+	case enums.PacketTypeRADIO_SUB_TEL:
+		p, err := subtel.NewPacketFromEsp3(t)
+		if err != nil {
+			return append(messages, Message{Kind: MessageKindParseError, ESP3: t, Err: err})
+		}
+		radio := p.ERP1()
+		messages = append(messages, Message{Kind: MessageKindSubTel, ESP3: t, ERP1: &radio, Data: p})
+		messages = append(messages, Message{Kind: MessageKindERP1, ESP3: t, ERP1: &radio, Data: radio})
+		messages = append(messages, parseERP1(remanMessages, t, radio)...)
+	case enums.PacketTypeREMOTE_MAN_COMMAND:
+		messages = append(messages, parsePacket(t, MessageKindReManCommand, remancommand.NewPacketFromEsp3))
+	case enums.PacketTypeRADIO_MESSAGE:
+		messages = append(messages, parsePacket(t, MessageKindRadioMessage, radiomessage.NewPacketFromEsp3))
+	case enums.PacketTypeRADIO_ERP2:
+		p, err := radioerp2.NewPacketFromEsp3(t)
+		if err != nil {
+			return append(messages, Message{Kind: MessageKindParseError, ESP3: t, Err: err})
+		}
+		messages = append(messages, Message{Kind: MessageKindRadioERP2, ESP3: t, Data: p})
+		messages = append(messages, parseERP2(remanMessages, t, p)...)
+	case enums.PacketTypeCONFIG_COMMAND:
+		messages = append(messages, parsePacket(t, MessageKindConfigCommand, configcommand.NewPacketFromEsp3))
+	case enums.PacketTypeCOMMAND_ACCEPTED:
+		messages = append(messages, parsePacket(t, MessageKindCommandAccepted, commandaccepted.NewPacketFromEsp3))
+	case enums.PacketTypeRADIO_802_15_4:
+		messages = append(messages, parsePacket(t, MessageKindRadio802154, radio802154.NewPacketFromEsp3))
+	case enums.PacketTypeCOMMAND_2_4:
+		messages = append(messages, parsePacket(t, MessageKindCommand24, command24.NewPacketFromEsp3))
 	default:
-		messages = append(messages, Message{Kind: "unparsed", ESP3: t, Data: t})
+		messages = append(messages, Message{Kind: MessageKindUnparsed, ESP3: t, Data: t})
 	}
 
 	return messages
 }
 
+// parsePacket parses t with parse into a message of kind.
+func parsePacket[T any](t esp3.Telegram, kind MessageKind, parse func(esp3.Telegram) (T, error)) Message {
+	p, err := parse(t)
+	if err != nil {
+		return Message{Kind: MessageKindParseError, ESP3: t, Err: err}
+	}
+	return Message{Kind: kind, ESP3: t, Data: p}
+}
+
+// parseERP2 decodes the ERP2 telegram of p and, if it has an ERP1
+// equivalent, parses that like any ERP1 packet.
+func parseERP2(remanMessages *remanAssembler, t esp3.Telegram, p radioerp2.Packet) []Message {
+	telegram, err := erp2.Parse(p.Data)
+	if err != nil {
+		return []Message{{Kind: MessageKindParseError, ESP3: t, Err: err}}
+	}
+	messages := []Message{{Kind: MessageKindERP2, ESP3: t, Data: telegram}}
+
+	radio, ok := erp2ERP1(p, telegram)
+	if !ok {
+		return messages
+	}
+	messages[0].ERP1 = &radio
+	messages = append(messages, Message{Kind: MessageKindERP1, ESP3: t, ERP1: &radio, Data: radio})
+	return append(messages, parseERP1(remanMessages, t, radio)...)
+}
+
+// erp2ERP1 converts the ERP2 telegram of p to ERP1, with the reception
+// details of p.
+func erp2ERP1(p radioerp2.Packet, telegram erp2.Telegram) (erp1.Packet, bool) {
+	radio, err := telegram.ERP1()
+	if err != nil {
+		return erp1.Packet{}, false
+	}
+	radio.SubTelNum, radio.Rssi = p.SubTelNum, p.Dbm
+	return radio, true
+}
+
+// radioPacket returns the ERP1 packet of a RADIO_ERP1 or RADIO_SUB_TEL
+// telegram, or of a RADIO_ERP2 one with an ERP1 equivalent.
+func radioPacket(t esp3.Telegram) (erp1.Packet, bool) {
+	switch t.PacketType {
+	case enums.PacketTypeRADIO_ERP1:
+		p, err := erp1.NewPacketFromEsp3(t)
+		return p, err == nil
+	case enums.PacketTypeRADIO_SUB_TEL:
+		p, err := subtel.NewPacketFromEsp3(t)
+		return p.ERP1(), err == nil
+	case enums.PacketTypeRADIO_ERP2:
+		p, err := radioerp2.NewPacketFromEsp3(t)
+		if err != nil {
+			return erp1.Packet{}, false
+		}
+		telegram, err := erp2.Parse(p.Data)
+		if err != nil {
+			return erp1.Packet{}, false
+		}
+		return erp2ERP1(p, telegram)
+	default:
+		return erp1.Packet{}, false
+	}
+}
+
 // parseERP1 parses ERP1.
 func parseERP1(remanMessages *remanAssembler, t esp3.Telegram, p erp1.Packet) []Message {
 	switch {
 	case p.Rorg == enums.RorgSYS_EX:
 		part, err := reman.ParsePacket(p)
 		if err != nil {
-			return []Message{{Kind: "parse_error", ESP3: t, ERP1: &p, Err: err}}
+			return []Message{{Kind: MessageKindParseError, ESP3: t, ERP1: &p, Err: err}}
 		}
-		out := []Message{{Kind: "reman_part", ESP3: t, ERP1: &p, Data: part}}
+		out := []Message{{Kind: MessageKindReManPart, ESP3: t, ERP1: &p, Data: part}}
 		messages, err := remanMessages.add(part)
 		if err != nil {
-			return append(out, Message{Kind: "parse_error", ESP3: t, ERP1: &p, Err: err})
+			return append(out, Message{Kind: MessageKindParseError, ESP3: t, ERP1: &p, Err: err})
 		}
 		for i := range messages {
 			messages[i].ESP3 = t
@@ -379,13 +628,13 @@ func parseERP1(remanMessages *remanAssembler, t esp3.Telegram, p erp1.Packet) []
 	case gp.IsRorg(p.Rorg):
 		header, err := parseGPHeader(p)
 		if err != nil {
-			return []Message{{Kind: "parse_error", ESP3: t, ERP1: &p, Err: err}}
+			return []Message{{Kind: MessageKindParseError, ESP3: t, ERP1: &p, Err: err}}
 		}
-		return []Message{{Kind: "gp_header", ESP3: t, ERP1: &p, Data: header}}
+		return []Message{{Kind: MessageKindGPHeader, ESP3: t, ERP1: &p, Data: header}}
 	default:
 		msg, err := smartack.Parse(p)
 		if err == nil {
-			return []Message{{Kind: "smart_ack", ESP3: t, ERP1: &p, Data: msg}}
+			return []Message{{Kind: MessageKindSmartAck, ESP3: t, ERP1: &p, Data: msg}}
 		}
 		return nil
 	}
@@ -403,51 +652,142 @@ func parseGPHeader(p erp1.Packet) (any, error) {
 	}
 }
 
-// publish dispatches parsed messages to output channels.
+// process parses telegram through every stage and publishes the result.
+func (c *channelSet) process(ctx context.Context, remanMessages *remanAssembler, telegram esp3.Telegram) bool {
+	if c.onRadioMode != nil {
+		switch telegram.PacketType {
+		case enums.PacketTypeRADIO_ERP1, enums.PacketTypeRADIO_SUB_TEL:
+			c.onRadioMode(enums.RadioModeERP1)
+		case enums.PacketTypeRADIO_ERP2:
+			c.onRadioMode(enums.RadioModeERP2)
+		}
+	}
+
+	p, isERP1 := radioPacket(telegram)
+	switch {
+	case !isERP1 || c.stats == nil:
+	case telegram.PacketType == enums.PacketTypeRADIO_SUB_TEL:
+		// ObserveSubTel counts the telegram with its subtelegram details.
+		sub, _ := subtel.NewPacketFromEsp3(telegram)
+		c.stats.ObserveSubTel(sub)
+	default:
+		c.stats.Observe(p)
+	}
+	if isERP1 && c.dedup != nil && c.dedup.merge(p) {
+		return publish(ctx, c, []Message{{Kind: MessageKindESP3, ESP3: telegram, Data: telegram}})
+	}
+
+	if !publish(ctx, c, decodeProfiles(c.devices, parseTelegram(remanMessages, telegram))) {
+		return false
+	}
+	if isERP1 && c.dedup != nil {
+		c.dedup.hold(ctx, c, telegram, p)
+	}
+	return true
+}
+
+// publish dispatches parsed messages to output channels. Calls are
+// serialized, so handlers and channels see one message at a time.
 func publish(ctx context.Context, channels *channelSet, messages []Message) bool {
+	channels.publishMu.Lock()
+	defer channels.publishMu.Unlock()
+
 	for _, msg := range messages {
-		if !send(ctx, channels.all, msg) {
+		if channels.onMessage != nil {
+			channels.onMessage(msg)
+		}
+		if !deliver(ctx, channels, StreamAll, channels.all, msg) {
 			return false
 		}
 		switch msg.Kind {
-		case "esp3":
-			if !send(ctx, channels.esp3, msg.Data.(esp3.Telegram)) {
+		case MessageKindESP3:
+			if !deliver(ctx, channels, StreamESP3, channels.esp3, msg.Data.(esp3.Telegram)) {
+				return false
+			}
+		case MessageKindERP1:
+			if !deliver(ctx, channels, StreamERP1, channels.erp1, msg.Data.(erp1.Packet)) {
+				return false
+			}
+		case MessageKindResponse:
+			if channels.onResponse != nil {
+				channels.onResponse(msg.Data.(response.Packet))
+			}
+			if !deliver(ctx, channels, StreamResponse, channels.response, msg.Data.(response.Packet)) {
+				return false
+			}
+		case MessageKindEvent:
+			if channels.onEvent != nil {
+				channels.onEvent(msg.Data.(event.Event))
+			}
+			if !deliver(ctx, channels, StreamEvent, channels.event, msg.Data.(event.Event)) {
+				return false
+			}
+		case MessageKindSmartAck:
+			if !deliver(ctx, channels, StreamSmartAck, channels.smartAck, msg.Data.(smartack.Message)) {
+				return false
+			}
+		case MessageKindReMan:
+			if !deliver(ctx, channels, StreamReMan, channels.reman, msg.Data.(reman.Message)) {
+				return false
+			}
+		case MessageKindReManPart:
+			if !deliver(ctx, channels, StreamReManPart, channels.remanPart, msg.Data.(reman.Part)) {
+				return false
+			}
+		case MessageKindGPHeader:
+			if !deliver(ctx, channels, StreamGPHeader, channels.gpHeader, msg.Data) {
+				return false
+			}
+		case MessageKindUnparsed:
+			if !deliver(ctx, channels, StreamUnparsed, channels.unparsed, msg) {
+				return false
+			}
+		case MessageKindParseError:
+			if !deliver(ctx, channels, StreamParseError, channels.parseError, msg) {
+				return false
+			}
+		case MessageKindDecoded:
+			if !deliver(ctx, channels, StreamDecoded, channels.decoded, msg.Data.(Decoded)) {
+				return false
+			}
+		case MessageKindDeduplicated:
+			if !deliver(ctx, channels, StreamDeduplicated, channels.deduplicated, msg.Data.(Deduplicated)) {
 				return false
 			}
-		case "erp1":
-			if !send(ctx, channels.erp1, msg.Data.(erp1.Packet)) {
+		case MessageKindSubTel:
+			if !deliver(ctx, channels, StreamSubTel, channels.subTel, msg.Data.(subtel.Packet)) {
 				return false
 			}
-		case "response":
-			if !send(ctx, channels.response, msg.Data.(response.Packet)) {
+		case MessageKindReManCommand:
+			if !deliver(ctx, channels, StreamReManCommand, channels.remanCommand, msg.Data.(remancommand.Packet)) {
 				return false
 			}
-		case "event":
-			if !send(ctx, channels.event, msg.Data.(event.Event)) {
+		case MessageKindRadioMessage:
+			if !deliver(ctx, channels, StreamRadioMessage, channels.radioMessage, msg.Data.(radiomessage.Packet)) {
 				return false
 			}
-		case "smart_ack":
-			if !send(ctx, channels.smartAck, msg.Data.(smartack.Message)) {
+		case MessageKindRadioERP2:
+			if !deliver(ctx, channels, StreamRadioERP2, channels.radioERP2, msg.Data.(radioerp2.Packet)) {
 				return false
 			}
-		case "reman":
-			if !send(ctx, channels.reman, msg.Data.(reman.Message)) {
+		case MessageKindConfigCommand:
+			if !deliver(ctx, channels, StreamConfigCommand, channels.configCommand, msg.Data.(configcommand.Packet)) {
 				return false
 			}
-		case "reman_part":
-			if !send(ctx, channels.remanPart, msg.Data.(reman.Part)) {
+		case MessageKindCommandAccepted:
+			if !deliver(ctx, channels, StreamCommandAccepted, channels.commandAccepted, msg.Data.(commandaccepted.Packet)) {
 				return false
 			}
-		case "gp_header":
-			if !send(ctx, channels.gpHeader, msg.Data) {
+		case MessageKindRadio802154:
+			if !deliver(ctx, channels, StreamRadio802154, channels.radio802154, msg.Data.(radio802154.Packet)) {
 				return false
 			}
-		case "unparsed":
-			if !send(ctx, channels.unparsed, msg) {
+		case MessageKindCommand24:
+			if !deliver(ctx, channels, StreamCommand24, channels.command24, msg.Data.(command24.Packet)) {
 				return false
 			}
-		case "parse_error":
-			if !send(ctx, channels.parseError, msg) {
+		case MessageKindERP2:
+			if !deliver(ctx, channels, StreamERP2, channels.erp2, msg.Data.(erp2.Telegram)) {
 				return false
 			}
 		}
@@ -455,14 +795,16 @@ func publish(ctx context.Context, channels *channelSet, messages []Message) bool
 	return true
 }
 
-// send delivers a value without blocking the parser.
-func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+// send delivers a value without blocking the parser, counting it in dropped
+// when ch is full.
+func send[T any](ctx context.Context, ch chan<- T, v T, dropped *atomic.Uint64) bool {
 	select {
 	case ch <- v:
 		return true
 	case <-ctx.Done():
 		return false
 	default:
+		dropped.Add(1)
 		return true
 	}
 }
@@ -9,15 +9,25 @@ import (
 	"testing"
 	"time"
 
+	"github.com/edlundin/enocean-esp3/pkg/command24"
+	"github.com/edlundin/enocean-esp3/pkg/commandaccepted"
+	"github.com/edlundin/enocean-esp3/pkg/configcommand"
 	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep/profiles"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
 	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
 	"github.com/edlundin/enocean-esp3/pkg/event"
 	"github.com/edlundin/enocean-esp3/pkg/gp"
+	"github.com/edlundin/enocean-esp3/pkg/radio802154"
+	"github.com/edlundin/enocean-esp3/pkg/radioerp2"
+	"github.com/edlundin/enocean-esp3/pkg/radiomessage"
 	"github.com/edlundin/enocean-esp3/pkg/reman"
+	"github.com/edlundin/enocean-esp3/pkg/remancommand"
 	"github.com/edlundin/enocean-esp3/pkg/response"
 	"github.com/edlundin/enocean-esp3/pkg/smartack"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
 	"go.bug.st/serial"
 )
 
@@ -328,7 +338,7 @@ func TestParseTelegramResponseAndUnparsed(t *testing.T) {
 	remanMessages := newReManAssembler(time.Second)
 	resp := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS), 1, 2}, []byte{3})
 	msgs := parseTelegram(remanMessages, resp)
-	if len(msgs) != 2 || msgs[0].Kind != "esp3" || msgs[1].Kind != "response" {
+	if len(msgs) != 2 || msgs[0].Kind != MessageKindESP3 || msgs[1].Kind != MessageKindResponse {
 		t.Fatalf("messages = %#v", msgs)
 	}
 	if got := msgs[1].Data.(response.Packet); got.Code != enums.ReturnCodeSUCCESS || !reflect.DeepEqual(got.Data, []byte{1, 2}) || !reflect.DeepEqual(got.OptData, []byte{3}) {
@@ -337,11 +347,82 @@ func TestParseTelegramResponseAndUnparsed(t *testing.T) {
 
 	unknown := esp3.NewTelegramFromData(enums.PacketTypeCOMMON_COMMAND, []byte{1}, nil)
 	msgs = parseTelegram(remanMessages, unknown)
-	if len(msgs) != 2 || msgs[1].Kind != "unparsed" {
+	if len(msgs) != 2 || msgs[1].Kind != MessageKindUnparsed {
 		t.Fatalf("unparsed messages = %#v", msgs)
 	}
 }
 
+// TestParseTelegramSubTelAndOtherPackets verifies ParseTelegramSubTelAndOtherPackets behavior.
+func TestParseTelegramSubTelAndOtherPackets(t *testing.T) {
+	sub := subtel.Packet{
+		DestinationID: deviceid.BroadcastId(),
+		Rorg:          enums.RorgRPS,
+		Rssi:          0x40,
+		SubTelNum:     1,
+		SenderID:      0x01020304,
+		UserData:      []byte{0x50},
+		SubTels:       []subtel.SubTel{{Tick: 1, Rssi: 0x40, Status: 0x30}},
+	}
+	telegram := sub.ToEsp3()
+	telegram = esp3.NewTelegramFromData(enums.PacketTypeRADIO_SUB_TEL, telegram.Data, telegram.OptData)
+	msgs := parseTelegram(newReManAssembler(time.Second), telegram)
+	if len(msgs) != 3 || msgs[1].Kind != MessageKindSubTel || msgs[2].Kind != MessageKindERP1 {
+		t.Fatalf("sub tel messages = %#v", msgs)
+	}
+	if got := msgs[1].Data.(subtel.Packet); !reflect.DeepEqual(got.SubTels, sub.SubTels) {
+		t.Fatalf("sub tels = %#v", got.SubTels)
+	}
+	if got := msgs[2].Data.(erp1.Packet); got.SenderID != sub.SenderID || !reflect.DeepEqual(got.UserData, sub.UserData) {
+		t.Fatalf("erp1 = %#v", got)
+	}
+
+	accepted := commandaccepted.Packet{Response: commandaccepted.ResponseNonBlocking, CommandCode: 0x05}
+	msgs = parseTelegram(newReManAssembler(time.Second), accepted.ToEsp3())
+	if len(msgs) != 2 || msgs[1].Kind != MessageKindCommandAccepted || msgs[1].Data != accepted {
+		t.Fatalf("command accepted messages = %#v", msgs)
+	}
+
+	msgs = parseTelegram(newReManAssembler(time.Second), esp3.NewTelegramFromData(enums.PacketTypeCOMMAND_ACCEPTED, []byte{0x00}, nil))
+	if msgs[len(msgs)-1].Kind != MessageKindParseError {
+		t.Fatalf("short command accepted messages = %#v", msgs)
+	}
+}
+
+// TestParseTelegramERP2 verifies ParseTelegramERP2 behavior.
+func TestParseTelegramERP2(t *testing.T) {
+	radio := func(tel erp2.Telegram) esp3.Telegram {
+		data, err := tel.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return radioerp2.Packet{Data: data, SubTelNum: 2, Dbm: 0x40}.ToEsp3()
+	}
+
+	reclaim := smartack.DataReclaim{MailboxIndex: 3}.ERP1(0x01020304)
+	tel, err := erp2.FromERP1(reclaim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs := parseTelegram(newReManAssembler(time.Second), radio(tel))
+	if len(msgs) != 5 || msgs[1].Kind != MessageKindRadioERP2 || msgs[2].Kind != MessageKindERP2 || msgs[3].Kind != MessageKindERP1 || msgs[4].Kind != MessageKindSmartAck {
+		t.Fatalf("messages = %#v", msgs)
+	}
+	if got := msgs[3].Data.(erp1.Packet); got.SenderID != 0x01020304 || got.Rorg != enums.RorgSM_REC || got.Rssi != 0x40 || got.SubTelNum != 2 {
+		t.Fatalf("erp1 = %#v", got)
+	}
+
+	long := erp2.Telegram{AddressControl: erp2.AddressOriginator48, Type: erp2.TypeRPS, OriginatorID: 0x010203040506, Data: []byte{0x50}}
+	msgs = parseTelegram(newReManAssembler(time.Second), radio(long))
+	if len(msgs) != 3 || msgs[2].Kind != MessageKindERP2 || msgs[2].ERP1 != nil {
+		t.Fatalf("48-bit messages = %#v", msgs)
+	}
+
+	msgs = parseTelegram(newReManAssembler(time.Second), radioerp2.Packet{Data: []byte{0x20, 0x00}}.ToEsp3())
+	if msgs[len(msgs)-1].Kind != MessageKindParseError {
+		t.Fatalf("bad CRC messages = %#v", msgs)
+	}
+}
+
 // TestParseTelegramReportsParseErrors verifies ParseTelegramReportsParseErrors behavior.
 func TestParseTelegramReportsParseErrors(t *testing.T) {
 	cases := []esp3.Telegram{
@@ -350,7 +431,7 @@ func TestParseTelegramReportsParseErrors(t *testing.T) {
 	}
 	for _, tc := range cases {
 		msgs := parseTelegram(newReManAssembler(time.Second), tc)
-		if msgs[len(msgs)-1].Kind != "parse_error" || msgs[len(msgs)-1].Err == nil {
+		if msgs[len(msgs)-1].Kind != MessageKindParseError || msgs[len(msgs)-1].Err == nil {
 			t.Fatalf("messages = %#v", msgs)
 		}
 	}
@@ -359,7 +440,7 @@ func TestParseTelegramReportsParseErrors(t *testing.T) {
 func TestParseERP1SmartAckAndGPHeader(t *testing.T) {
 	sa := smartack.DataReclaim{MailboxIndex: 3}.ERP1(deviceid.DeviceID(1))
 	msgs := parseERP1(newReManAssembler(time.Second), sa.ToEsp3(), sa)
-	if len(msgs) != 1 || msgs[0].Kind != "smart_ack" || msgs[0].Data.(smartack.DataReclaim).MailboxIndex != 3 {
+	if len(msgs) != 1 || msgs[0].Kind != MessageKindSmartAck || msgs[0].Data.(smartack.DataReclaim).MailboxIndex != 3 {
 		t.Fatalf("smart ack messages = %#v", msgs)
 	}
 
@@ -369,7 +450,7 @@ func TestParseERP1SmartAckAndGPHeader(t *testing.T) {
 	}
 	request := erp1.Packet{Rorg: enums.RorgGP_TI, UserData: requestData}
 	msgs = parseERP1(newReManAssembler(time.Second), request.ToEsp3(), request)
-	if len(msgs) != 1 || msgs[0].Kind != "gp_header" {
+	if len(msgs) != 1 || msgs[0].Kind != MessageKindGPHeader {
 		t.Fatalf("GP request messages = %#v", msgs)
 	}
 	if got := msgs[0].Data.(gp.RequestHeader); got.ManufacturerID != 0x123 || !got.Bidirectional || got.Purpose != gp.PurposeTeachIn {
@@ -394,7 +475,7 @@ func TestParseERP1SmartAckAndGPHeader(t *testing.T) {
 
 	invalid := erp1.Packet{Rorg: enums.RorgGP_TR}
 	msgs = parseERP1(newReManAssembler(time.Second), invalid.ToEsp3(), invalid)
-	if len(msgs) != 1 || msgs[0].Kind != "parse_error" || msgs[0].Err == nil {
+	if len(msgs) != 1 || msgs[0].Kind != MessageKindParseError || msgs[0].Err == nil {
 		t.Fatalf("invalid GP messages = %#v", msgs)
 	}
 }
@@ -405,20 +486,31 @@ func TestPublishDispatchesTypedChannels(t *testing.T) {
 		want    any
 		receive func(*Channels) any
 	}{
-		{Message{Kind: "esp3", Data: esp3.Telegram{}}, esp3.Telegram{}, func(c *Channels) any { return <-c.ESP3 }},
-		{Message{Kind: "erp1", Data: erp1.Packet{}}, erp1.Packet{}, func(c *Channels) any { return <-c.ERP1 }},
-		{Message{Kind: "response", Data: response.Packet{Code: enums.ReturnCodeSUCCESS}}, response.Packet{Code: enums.ReturnCodeSUCCESS}, func(c *Channels) any { return <-c.Response }},
-		{Message{Kind: "event", Data: event.Packet{}}, event.Packet{}, func(c *Channels) any { return <-c.Event }},
-		{Message{Kind: "smart_ack", Data: smartack.DataReclaim{MailboxIndex: 3}}, smartack.DataReclaim{MailboxIndex: 3}, func(c *Channels) any { return <-c.SmartAck }},
-		{Message{Kind: "reman", Data: reman.Message{Seq: 1}}, reman.Message{Seq: 1}, func(c *Channels) any { return <-c.ReMan }},
-		{Message{Kind: "reman_part", Data: reman.Part{Seq: 1}}, reman.Part{Seq: 1}, func(c *Channels) any { return <-c.ReManPart }},
-		{Message{Kind: "gp_header", Data: gp.RequestHeader{ManufacturerID: 1}}, gp.RequestHeader{ManufacturerID: 1}, func(c *Channels) any { return <-c.GPHeader }},
-		{Message{Kind: "unparsed"}, "unparsed", func(c *Channels) any { return (<-c.Unparsed).Kind }},
-		{Message{Kind: "parse_error", Err: errors.New("bad packet")}, "parse_error", func(c *Channels) any { return (<-c.ParseError).Kind }},
+		{Message{Kind: MessageKindESP3, Data: esp3.Telegram{}}, esp3.Telegram{}, func(c *Channels) any { return <-c.ESP3 }},
+		{Message{Kind: MessageKindERP1, Data: erp1.Packet{}}, erp1.Packet{}, func(c *Channels) any { return <-c.ERP1 }},
+		{Message{Kind: MessageKindResponse, Data: response.Packet{Code: enums.ReturnCodeSUCCESS}}, response.Packet{Code: enums.ReturnCodeSUCCESS}, func(c *Channels) any { return <-c.Response }},
+		{Message{Kind: MessageKindEvent, Data: event.Packet{}}, event.Packet{}, func(c *Channels) any { return <-c.Event }},
+		{Message{Kind: MessageKindSmartAck, Data: smartack.DataReclaim{MailboxIndex: 3}}, smartack.DataReclaim{MailboxIndex: 3}, func(c *Channels) any { return <-c.SmartAck }},
+		{Message{Kind: MessageKindReMan, Data: reman.Message{Seq: 1}}, reman.Message{Seq: 1}, func(c *Channels) any { return <-c.ReMan }},
+		{Message{Kind: MessageKindReManPart, Data: reman.Part{Seq: 1}}, reman.Part{Seq: 1}, func(c *Channels) any { return <-c.ReManPart }},
+		{Message{Kind: MessageKindGPHeader, Data: gp.RequestHeader{ManufacturerID: 1}}, gp.RequestHeader{ManufacturerID: 1}, func(c *Channels) any { return <-c.GPHeader }},
+		{Message{Kind: MessageKindUnparsed}, MessageKindUnparsed, func(c *Channels) any { return (<-c.Unparsed).Kind }},
+		{Message{Kind: MessageKindParseError, Err: errors.New("bad packet")}, MessageKindParseError, func(c *Channels) any { return (<-c.ParseError).Kind }},
+		{Message{Kind: MessageKindDecoded, Data: Decoded{Telegram: profiles.F60101{Pressed: true}}}, Decoded{Telegram: profiles.F60101{Pressed: true}}, func(c *Channels) any { return <-c.Decoded }},
+		{Message{Kind: MessageKindDeduplicated, Data: Deduplicated{Copies: 3}}, Deduplicated{Copies: 3}, func(c *Channels) any { return <-c.Deduplicated }},
+		{Message{Kind: MessageKindSubTel, Data: subtel.Packet{SubTelNum: 2}}, subtel.Packet{SubTelNum: 2}, func(c *Channels) any { return <-c.SubTel }},
+		{Message{Kind: MessageKindReManCommand, Data: remancommand.Packet{Function: 0x220}}, remancommand.Packet{Function: 0x220}, func(c *Channels) any { return <-c.ReManCommand }},
+		{Message{Kind: MessageKindRadioMessage, Data: radiomessage.Packet{Rorg: enums.RorgVLD}}, radiomessage.Packet{Rorg: enums.RorgVLD}, func(c *Channels) any { return <-c.RadioMessage }},
+		{Message{Kind: MessageKindRadioERP2, Data: radioerp2.Packet{SubTelNum: 1}}, radioerp2.Packet{SubTelNum: 1}, func(c *Channels) any { return <-c.RadioERP2 }},
+		{Message{Kind: MessageKindConfigCommand, Data: configcommand.Packet{Code: 0x01}}, configcommand.Packet{Code: 0x01}, func(c *Channels) any { return <-c.ConfigCommand }},
+		{Message{Kind: MessageKindCommandAccepted, Data: commandaccepted.Packet{CommandCode: 0x02}}, commandaccepted.Packet{CommandCode: 0x02}, func(c *Channels) any { return <-c.CommandAccepted }},
+		{Message{Kind: MessageKindRadio802154, Data: radio802154.Packet{Rssi: 0x40}}, radio802154.Packet{Rssi: 0x40}, func(c *Channels) any { return <-c.Radio802154 }},
+		{Message{Kind: MessageKindCommand24, Data: command24.Packet{Code: command24.R802RdChannel}}, command24.Packet{Code: command24.R802RdChannel}, func(c *Channels) any { return <-c.Command24 }},
+		{Message{Kind: MessageKindERP2, Data: erp2.Telegram{Type: erp2.TypeVLD}}, erp2.Telegram{Type: erp2.TypeVLD}, func(c *Channels) any { return <-c.ERP2 }},
 	}
 
 	for _, tc := range tests {
-		t.Run(tc.message.Kind, func(t *testing.T) {
+		t.Run(tc.message.Kind.String(), func(t *testing.T) {
 			set, channels := newChannelSet(2)
 			if !publish(context.Background(), set, []Message{tc.message}) {
 				t.Fatal("publish returned false")
@@ -436,8 +528,8 @@ func TestPublishDispatchesTypedChannels(t *testing.T) {
 // TestPublishFullAllStillDispatchesTypedChannel verifies PublishFullAllStillDispatchesTypedChannel behavior.
 func TestPublishFullAllStillDispatchesTypedChannel(t *testing.T) {
 	set, channels := newChannelSet(1)
-	set.all <- Message{Kind: "occupied"}
-	msg := Message{Kind: "response", Data: response.Packet{Code: enums.ReturnCodeSUCCESS}}
+	set.all <- Message{Kind: MessageKindUnparsed}
+	msg := Message{Kind: MessageKindResponse, Data: response.Packet{Code: enums.ReturnCodeSUCCESS}}
 	if !publish(context.Background(), set, []Message{msg}) {
 		t.Fatal("publish returned false")
 	}
@@ -451,7 +543,7 @@ func TestPublishStopsWhenContextCancelled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 	set, _ := newChannelSet(0)
-	if publish(ctx, set, []Message{{Kind: "esp3", Data: esp3.Telegram{}}}) {
+	if publish(ctx, set, []Message{{Kind: MessageKindESP3, Data: esp3.Telegram{}}}) {
 		t.Fatal("publish should stop on canceled context")
 	}
 }
@@ -479,10 +571,14 @@ func TestReManChainPeriod(t *testing.T) {
 func TestSendDropsWhenChannelFull(t *testing.T) {
 	ch := make(chan int, 1)
 	ch <- 1
-	if !send(context.Background(), ch, 2) {
+	var dropped atomic.Uint64
+	if !send(context.Background(), ch, 2, &dropped) {
 		t.Fatal("send should drop instead of blocking")
 	}
 	if got := <-ch; got != 1 {
 		t.Fatalf("got %d", got)
 	}
+	if got := dropped.Load(); got != 1 {
+		t.Fatalf("dropped = %d, want 1", got)
+	}
 }
@@ -79,3 +79,51 @@ func (command SmartAckCommand) Valid() bool {
 		return false
 	}
 }
+
+type SmartAckLearnMode uint8
+
+const (
+	SmartAckLearnModeSIMPLE SmartAckLearnMode = iota
+	SmartAckLearnModeADVANCED
+	SmartAckLearnModeADVANCED_SELECT_REPEATER
+)
+
+// ParseSmartAckLearnModeFromByte parses a SmartAckLearnMode from a byte.
+func ParseSmartAckLearnModeFromByte(b byte) (SmartAckLearnMode, error) {
+	switch b {
+	case 0x00:
+		return SmartAckLearnModeSIMPLE, nil
+	case 0x01:
+		return SmartAckLearnModeADVANCED, nil
+	case 0x02:
+		return SmartAckLearnModeADVANCED_SELECT_REPEATER, nil
+	default:
+		return 0, errors.New("invalid smart ack learn mode")
+	}
+}
+
+// String returns the string representation of SmartAckLearnMode.
+func (mode SmartAckLearnMode) String() string {
+	switch mode {
+	case SmartAckLearnModeSIMPLE:
+		return "SIMPLE"
+	case SmartAckLearnModeADVANCED:
+		return "ADVANCED"
+	case SmartAckLearnModeADVANCED_SELECT_REPEATER:
+		return "ADVANCED_SELECT_REPEATER"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Valid reports whether SmartAckLearnMode is valid.
+func (mode SmartAckLearnMode) Valid() bool {
+	switch mode {
+	case SmartAckLearnModeSIMPLE,
+		SmartAckLearnModeADVANCED,
+		SmartAckLearnModeADVANCED_SELECT_REPEATER:
+		return true
+	default:
+		return false
+	}
+}
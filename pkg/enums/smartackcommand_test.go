@@ -21,3 +21,28 @@ func TestSmartAckCommand(t *testing.T) {
 	if _, err := ParseSmartAckCommandFromByte(0xff); err == nil { t.Fatal("expected error") }
 	if SmartAckCommand(0xff).String() != "UNKNOWN" || SmartAckCommand(0xff).Valid() { t.Fatal("invalid smart ack command accepted") }
 }
+
+// TestSmartAckLearnMode verifies SmartAckLearnMode behavior.
+func TestSmartAckLearnMode(t *testing.T) {
+	cases := []struct {
+		b byte
+		v SmartAckLearnMode
+		s string
+	}{
+		{0x00, SmartAckLearnModeSIMPLE, "SIMPLE"},
+		{0x01, SmartAckLearnModeADVANCED, "ADVANCED"},
+		{0x02, SmartAckLearnModeADVANCED_SELECT_REPEATER, "ADVANCED_SELECT_REPEATER"},
+	}
+	for _, c := range cases {
+		v, err := ParseSmartAckLearnModeFromByte(c.b)
+		if err != nil || v != c.v || v.String() != c.s || !v.Valid() {
+			t.Fatalf("%#x => %v %v", c.b, v, err)
+		}
+	}
+	if _, err := ParseSmartAckLearnModeFromByte(0x03); err == nil {
+		t.Fatal("expected error")
+	}
+	if SmartAckLearnMode(0x03).String() != "UNKNOWN" || SmartAckLearnMode(0x03).Valid() {
+		t.Fatal("invalid smart ack learn mode accepted")
+	}
+}
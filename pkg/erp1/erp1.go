@@ -8,6 +8,14 @@ import (
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
 )
 
+const (
+	// RepeaterCountMask selects the repeater count bits of the status byte.
+	RepeaterCountMask = 0x0f
+	// RssiUnknown is the RSSI byte of telegrams without a level, such as
+	// telegrams being transmitted.
+	RssiUnknown = 0xff
+)
+
 type Packet struct {
 	DestinationID deviceid.DeviceID
 	Rorg          enums.Rorg
@@ -81,7 +89,7 @@ func (p Packet) ToEsp3() esp3.Telegram {
 	optData := make([]byte, 0, 3+deviceid.DeviceIDSize)
 	optData = append(optData, p.SubTelNum)
 	optData = append(optData, destinationID[:]...)
-	optData = append(optData, 0xff)
+	optData = append(optData, RssiUnknown)
 	optData = append(optData, 0x03)
 
 	return esp3.Telegram{
@@ -95,3 +103,8 @@ func (p Packet) ToEsp3() esp3.Telegram {
 func (p Packet) Serialize() []byte {
 	return p.ToEsp3().Serialize()
 }
+
+// RepeaterCount returns the number of times the telegram was repeated.
+func (p Packet) RepeaterCount() byte {
+	return p.Status & RepeaterCountMask
+}
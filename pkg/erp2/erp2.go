@@ -0,0 +1,299 @@
+// Package erp2 decodes and encodes ERP2 radio telegrams, the radio protocol
+// of transceivers switched to ERP2 mode with CO_WR_MODE. RADIO_ERP2 carries
+// them without their length byte: a header selecting the address fields and
+// the telegram type, an optional extended header and extended telegram type,
+// the originator and destination IDs, the payload, optional data and a CRC8.
+package erp2
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+const maxOptionalLength = 0x0f
+
+// ErrNoERP1 is returned when a telegram has no ERP1 equivalent: a 48-bit
+// originator ID, or a telegram type without an ERP1 RORG.
+var ErrNoERP1 = errors.New("no ERP1 equivalent")
+
+// AddressControl selects the address fields of a telegram.
+type AddressControl uint8
+
+const (
+	AddressOriginator24              AddressControl = 0x00
+	AddressOriginator32              AddressControl = 0x01
+	AddressOriginator32Destination32 AddressControl = 0x02
+	AddressOriginator48              AddressControl = 0x03
+)
+
+// String returns the string representation of AddressControl.
+func (ac AddressControl) String() string {
+	switch ac {
+	case AddressOriginator24:
+		return "ORIGINATOR_24"
+	case AddressOriginator32:
+		return "ORIGINATOR_32"
+	case AddressOriginator32Destination32:
+		return "ORIGINATOR_32_DESTINATION_32"
+	case AddressOriginator48:
+		return "ORIGINATOR_48"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Valid reports whether AddressControl is valid.
+func (ac AddressControl) Valid() bool {
+	return ac <= AddressOriginator48
+}
+
+// lengths returns the sizes of the originator and destination IDs.
+func (ac AddressControl) lengths() (originator, destination int) {
+	switch ac {
+	case AddressOriginator24:
+		return 3, 0
+	case AddressOriginator32:
+		return 4, 0
+	case AddressOriginator32Destination32:
+		return 4, deviceid.DeviceIDSize
+	default:
+		return 6, 0
+	}
+}
+
+// TelegramType is the 4-bit telegram type of the header.
+type TelegramType uint8
+
+const (
+	TypeRPS        TelegramType = 0x00
+	Type1BS        TelegramType = 0x01
+	Type4BS        TelegramType = 0x02
+	TypeSIGNAL     TelegramType = 0x03
+	TypeVLD        TelegramType = 0x04
+	TypeUTE        TelegramType = 0x05
+	TypeMSC        TelegramType = 0x06
+	TypeSEC        TelegramType = 0x07
+	TypeSEC_ENCAPS TelegramType = 0x08
+	TypeSEC_TI     TelegramType = 0x09
+	TypeGP_SD      TelegramType = 0x0a
+	// TypeExtended announces an extended telegram type after the header.
+	TypeExtended TelegramType = 0x0f
+)
+
+// ExtendedType is the extended telegram type following the header of
+// TypeExtended telegrams.
+type ExtendedType uint8
+
+const (
+	ExtendedTypeSYS_EX     ExtendedType = 0x00
+	ExtendedTypeSM_LRN_REQ ExtendedType = 0x01
+	ExtendedTypeSM_LRN_ANS ExtendedType = 0x02
+	ExtendedTypeSM_REC     ExtendedType = 0x03
+)
+
+var typeRorgs = map[TelegramType]enums.Rorg{
+	TypeRPS:        enums.RorgRPS,
+	Type1BS:        enums.Rorg1BS,
+	Type4BS:        enums.Rorg4BS,
+	TypeSIGNAL:     enums.RorgSIGNAL,
+	TypeVLD:        enums.RorgVLD,
+	TypeUTE:        enums.RorgUTE,
+	TypeMSC:        enums.RorgMSC,
+	TypeSEC:        enums.RorgSEC,
+	TypeSEC_ENCAPS: enums.RorgSEC_ENCAPS,
+	TypeSEC_TI:     enums.RorgSEC_TI,
+	TypeGP_SD:      enums.RorgGP_SD,
+}
+
+var extendedTypeRorgs = map[ExtendedType]enums.Rorg{
+	ExtendedTypeSYS_EX:     enums.RorgSYS_EX,
+	ExtendedTypeSM_LRN_REQ: enums.RorgSM_LRN_REQ,
+	ExtendedTypeSM_LRN_ANS: enums.RorgSM_LRN_ANS,
+	ExtendedTypeSM_REC:     enums.RorgSM_REC,
+}
+
+// Telegram is a decoded ERP2 telegram.
+type Telegram struct {
+	AddressControl AddressControl
+	Type           TelegramType
+	// ExtendedType applies to TypeExtended telegrams only.
+	ExtendedType ExtendedType
+
+	// RepeaterCount and OptionalData live in the extended header, which is
+	// only sent when either is set.
+	RepeaterCount uint8
+
+	// OriginatorID holds 24, 32 or 48 bits depending on AddressControl.
+	OriginatorID uint64
+	// DestinationID is only sent with AddressOriginator32Destination32.
+	DestinationID deviceid.DeviceID
+
+	Data         []byte
+	OptionalData []byte
+}
+
+// Parse decodes an ERP2 telegram and checks its CRC8.
+func Parse(b []byte) (Telegram, error) {
+	const minLen = 2 // 1 header + 1 CRC8
+
+	if len(b) < minLen {
+		return Telegram{}, errors.New("telegram too short")
+	}
+	body, crc := b[:len(b)-1], b[len(b)-1]
+	if valid := esp3.ComputeCrcSlice(body); crc != valid {
+		return Telegram{}, fmt.Errorf("invalid CRC8 (got:0x%x, valid:0x%x)", crc, valid)
+	}
+
+	header := body[0]
+	t := Telegram{AddressControl: AddressControl(header >> 5), Type: TelegramType(header & 0x0f)}
+	if !t.AddressControl.Valid() {
+		return Telegram{}, fmt.Errorf("reserved address control 0x%x", byte(t.AddressControl))
+	}
+	body = body[1:]
+
+	optionalLength := 0
+	if header&0x10 != 0 {
+		if len(body) < 1 {
+			return Telegram{}, errors.New("extended header missing")
+		}
+		t.RepeaterCount = body[0] >> 4
+		optionalLength = int(body[0] & 0x0f)
+		body = body[1:]
+	}
+	if t.Type == TypeExtended {
+		if len(body) < 1 {
+			return Telegram{}, errors.New("extended telegram type missing")
+		}
+		t.ExtendedType = ExtendedType(body[0])
+		body = body[1:]
+	}
+
+	originatorLength, destinationLength := t.AddressControl.lengths()
+	if len(body) < originatorLength+destinationLength+optionalLength {
+		return Telegram{}, errors.New("telegram too short")
+	}
+	for _, b := range body[:originatorLength] {
+		t.OriginatorID = t.OriginatorID<<8 | uint64(b)
+	}
+	body = body[originatorLength:]
+	if destinationLength > 0 {
+		t.DestinationID, _ = deviceid.FromByteArray(body[:destinationLength])
+		body = body[destinationLength:]
+	}
+
+	dataLength := len(body) - optionalLength
+	t.Data = append([]byte(nil), body[:dataLength]...)
+	if optionalLength > 0 {
+		t.OptionalData = append([]byte(nil), body[dataLength:]...)
+	}
+	return t, nil
+}
+
+// Encode encodes the telegram with its CRC8.
+func (t Telegram) Encode() ([]byte, error) {
+	switch {
+	case !t.AddressControl.Valid():
+		return nil, fmt.Errorf("reserved address control 0x%x", byte(t.AddressControl))
+	case t.Type > TypeExtended:
+		return nil, fmt.Errorf("invalid telegram type 0x%x", byte(t.Type))
+	case t.RepeaterCount > erp1.RepeaterCountMask:
+		return nil, fmt.Errorf("repeater count %d out of range", t.RepeaterCount)
+	case len(t.OptionalData) > maxOptionalLength:
+		return nil, fmt.Errorf("optional data of %d bytes too long", len(t.OptionalData))
+	}
+
+	originatorLength, destinationLength := t.AddressControl.lengths()
+	if t.OriginatorID >= 1<<(8*originatorLength) {
+		return nil, fmt.Errorf("originator ID 0x%x exceeds %d bits", t.OriginatorID, 8*originatorLength)
+	}
+
+	b := make([]byte, 0, 3+originatorLength+destinationLength+len(t.Data)+len(t.OptionalData)+1)
+	header := byte(t.AddressControl)<<5 | byte(t.Type)
+	extended := t.RepeaterCount != 0 || len(t.OptionalData) > 0
+	if extended {
+		header |= 0x10
+	}
+	b = append(b, header)
+	if extended {
+		b = append(b, t.RepeaterCount<<4|byte(len(t.OptionalData)))
+	}
+	if t.Type == TypeExtended {
+		b = append(b, byte(t.ExtendedType))
+	}
+	for i := originatorLength - 1; i >= 0; i-- {
+		b = append(b, byte(t.OriginatorID>>(8*i)))
+	}
+	if destinationLength > 0 {
+		destinationID := t.DestinationID.ToArray()
+		b = append(b, destinationID[:]...)
+	}
+	b = append(b, t.Data...)
+	b = append(b, t.OptionalData...)
+	return append(b, esp3.ComputeCrcSlice(b)), nil
+}
+
+// Rorg returns the ERP1 RORG of the telegram type.
+func (t Telegram) Rorg() (enums.Rorg, bool) {
+	if t.Type == TypeExtended {
+		rorg, ok := extendedTypeRorgs[t.ExtendedType]
+		return rorg, ok
+	}
+	rorg, ok := typeRorgs[t.Type]
+	return rorg, ok
+}
+
+// ERP1 converts the telegram to an ERP1 packet addressed to its destination,
+// or broadcast. ERP2 has no status byte: Status only holds the repeater
+// count, so RPS telegrams lose T21 and NU. Optional data is dropped.
+func (t Telegram) ERP1() (erp1.Packet, error) {
+	rorg, ok := t.Rorg()
+	if !ok || t.AddressControl == AddressOriginator48 {
+		return erp1.Packet{}, ErrNoERP1
+	}
+
+	destinationID := deviceid.BroadcastId()
+	if t.AddressControl == AddressOriginator32Destination32 {
+		destinationID = t.DestinationID
+	}
+	return erp1.Packet{
+		DestinationID: destinationID,
+		Rorg:          rorg,
+		Status:        t.RepeaterCount,
+		SenderID:      deviceid.DeviceID(t.OriginatorID),
+		UserData:      t.Data,
+	}, nil
+}
+
+// FromERP1 converts an ERP1 packet to an ERP2 telegram with a 32-bit
+// originator ID, and a destination ID unless p is broadcast. The low nibble
+// of Status is taken as the repeater count.
+func FromERP1(p erp1.Packet) (Telegram, error) {
+	t := Telegram{
+		AddressControl: AddressOriginator32,
+		RepeaterCount:  p.RepeaterCount(),
+		OriginatorID:   uint64(p.SenderID),
+		Data:           p.UserData,
+	}
+	if p.DestinationID != deviceid.BroadcastId() && p.DestinationID != 0 {
+		t.AddressControl, t.DestinationID = AddressOriginator32Destination32, p.DestinationID
+	}
+
+	for typ, rorg := range typeRorgs {
+		if rorg == p.Rorg {
+			t.Type = typ
+			return t, nil
+		}
+	}
+	for typ, rorg := range extendedTypeRorgs {
+		if rorg == p.Rorg {
+			t.Type, t.ExtendedType = TypeExtended, typ
+			return t, nil
+		}
+	}
+	return Telegram{}, fmt.Errorf("RORG %s has no ERP2 telegram type", p.Rorg)
+}
@@ -0,0 +1,152 @@
+package erp2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestParseEncode verifies ParseEncode behavior.
+func TestParseEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		telegram Telegram
+		encoded  []byte
+	}{
+		{
+			name:     "RPS with 32-bit originator",
+			telegram: Telegram{AddressControl: AddressOriginator32, Type: TypeRPS, OriginatorID: 0x01020304, Data: []byte{0x50}},
+			encoded:  []byte{0x20, 0x01, 0x02, 0x03, 0x04, 0x50},
+		},
+		{
+			name:     "4BS with 24-bit originator",
+			telegram: Telegram{AddressControl: AddressOriginator24, Type: Type4BS, OriginatorID: 0x0a0b0c, Data: []byte{0x00, 0x00, 0x80, 0x08}},
+			encoded:  []byte{0x02, 0x0a, 0x0b, 0x0c, 0x00, 0x00, 0x80, 0x08},
+		},
+		{
+			name: "VLD with destination and extended header",
+			telegram: Telegram{
+				AddressControl: AddressOriginator32Destination32,
+				Type:           TypeVLD,
+				RepeaterCount:  2,
+				OriginatorID:   0x01020304,
+				DestinationID:  0x05060708,
+				Data:           []byte{0x01, 0x02},
+				OptionalData:   []byte{0xaa, 0xbb},
+			},
+			encoded: []byte{0x54, 0x22, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x01, 0x02, 0xaa, 0xbb},
+		},
+		{
+			name:     "extended type with 48-bit originator",
+			telegram: Telegram{AddressControl: AddressOriginator48, Type: TypeExtended, ExtendedType: ExtendedTypeSM_REC, OriginatorID: 0x0102030405},
+			encoded:  []byte{0x6f, 0x03, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded, err := tc.telegram.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := append(tc.encoded, crc(tc.encoded)); !reflect.DeepEqual(encoded, want) {
+				t.Fatalf("encoded % x, want % x", encoded, want)
+			}
+			parsed, err := Parse(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed, tc.telegram) {
+				t.Fatalf("parsed %+v, want %+v", parsed, tc.telegram)
+			}
+		})
+	}
+}
+
+// TestParseErrors verifies ParseErrors behavior.
+func TestParseErrors(t *testing.T) {
+	valid, _ := Telegram{AddressControl: AddressOriginator32, Type: TypeRPS, OriginatorID: 1, Data: []byte{0x50}}.Encode()
+	badCRC := append([]byte(nil), valid...)
+	badCRC[len(badCRC)-1] ^= 0xff
+
+	for name, b := range map[string][]byte{
+		"empty":           nil,
+		"bad CRC":         badCRC,
+		"reserved":        withCRC(0x80, 0x01, 0x02, 0x03),
+		"short address":   withCRC(0x20, 0x01, 0x02),
+		"no ext header":   withCRC(0x30),
+		"no ext type":     withCRC(0x2f),
+		"short opt data":  withCRC(0x30, 0x05, 0x01, 0x02, 0x03, 0x04),
+		"no destinations": withCRC(0x40, 0x01, 0x02, 0x03, 0x04, 0x05),
+	} {
+		if _, err := Parse(b); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	for name, tel := range map[string]Telegram{
+		"reserved address": {AddressControl: 0x04},
+		"repeater count":   {RepeaterCount: 16},
+		"optional data":    {OptionalData: make([]byte, 16)},
+		"24-bit overflow":  {OriginatorID: 0x01000000},
+	} {
+		if _, err := tel.Encode(); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestERP1Conversion verifies ERP1Conversion behavior.
+func TestERP1Conversion(t *testing.T) {
+	packets := []erp1.Packet{
+		{Rorg: enums.RorgRPS, UserData: []byte{0x50}, SenderID: 0x01020304, DestinationID: deviceid.BroadcastId(), Status: 0x01},
+		{Rorg: enums.RorgVLD, UserData: []byte{0x01, 0x64}, SenderID: 0x01020304, DestinationID: 0x05060708},
+		{Rorg: enums.RorgSM_LRN_ANS, UserData: []byte{0x00, 0x00, 0x00, 0x00}, SenderID: 0x01020304, DestinationID: 0x05060708},
+	}
+	for _, p := range packets {
+		tel, err := FromERP1(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, err := tel.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := Parse(encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := parsed.ERP1()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, p) {
+			t.Errorf("round trip of %s = %+v, want %+v", p.Rorg, got, p)
+		}
+	}
+
+	if _, err := FromERP1(erp1.Packet{Rorg: enums.RorgADT}); err == nil {
+		t.Error("ADT converted to ERP2")
+	}
+	if _, err := (Telegram{AddressControl: AddressOriginator48, Type: TypeRPS}).ERP1(); !errors.Is(err, ErrNoERP1) {
+		t.Errorf("48-bit originator: err = %v", err)
+	}
+	if _, err := (Telegram{Type: TypeExtended, ExtendedType: 0x7f}).ERP1(); !errors.Is(err, ErrNoERP1) {
+		t.Errorf("unknown extended type: err = %v", err)
+	}
+}
+
+// crc returns the CRC8 of b.
+func crc(b []byte) byte {
+	return esp3.ComputeCrcSlice(b)
+}
+
+// withCRC returns b followed by its CRC8.
+func withCRC(b ...byte) []byte {
+	return append(b, crc(b))
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// CRCField names the checksum of an ESP3 frame.
+type CRCField uint8
+
+const (
+	CRCFieldHeader CRCField = iota
+	CRCFieldData
+)
+
+// String returns the string representation of CRCField.
+func (field CRCField) String() string {
+	switch field {
+	case CRCFieldHeader:
+		return "CRC8H"
+	case CRCFieldData:
+		return "CRC8D"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// CRCError describes a frame dropped because a checksum did not match.
+type CRCError struct {
+	Field CRCField
+	Want  byte
+	Got   byte
+	// Covered holds the bytes the checksum was computed over.
+	Covered []byte
+	// Dropped holds the bytes discarded with the frame, from its sync byte
+	// up to the failed checksum, or up to the sync byte inside the frame
+	// where parsing resumes.
+	Dropped []byte
+}
+
+// Error returns the error message.
+func (e CRCError) Error() string {
+	return fmt.Sprintf("%s mismatch: computed %#02x, received %#02x", e.Field, e.Want, e.Got)
+}
+
+// Hooks observes the ESP3 byte stream. Embed NopHooks to implement only
+// the methods of interest. Every received byte reaches exactly one of
+// Frame, CRCError (in Dropped), Resync or InterByteTimeout, in stream
+// order. Hooks run on the parser goroutine, except Written, which runs on
+// the writing goroutine.
+type Hooks interface {
+	// Frame receives every frame with valid checksums, sync byte included.
+	Frame(frame []byte)
+	// Written receives every frame written by the session.
+	Written(frame []byte)
+	// CRCError receives every checksum mismatch.
+	CRCError(err CRCError)
+	// Resync reports bytes skipped while searching for the next sync byte.
+	Resync(skipped []byte)
+	// InterByteTimeout reports a partial frame abandoned after a gap, sync
+	// byte included.
+	InterByteTimeout(pending []byte)
+	// UnknownPacketType reports a valid frame of an unknown packet type.
+	UnknownPacketType(packetType byte)
+	// Dispatched reports how long a frame took to reach every consumer.
+	Dispatched(telegram esp3.Telegram, latency time.Duration)
+}
+
+// NopHooks implements Hooks with no-ops.
+type NopHooks struct{}
+
+func (NopHooks) Frame([]byte)                            {}
+func (NopHooks) Written([]byte)                          {}
+func (NopHooks) CRCError(CRCError)                       {}
+func (NopHooks) Resync([]byte)                           {}
+func (NopHooks) InterByteTimeout([]byte)                 {}
+func (NopHooks) UnknownPacketType(byte)                  {}
+func (NopHooks) Dispatched(esp3.Telegram, time.Duration) {}
+
+// ParserConfig tunes a parser started with StartParser.
+type ParserConfig struct {
+	Streams StreamConfig
+
+	// Logger receives parser diagnostics; defaults to discarding them.
+	Logger *slog.Logger
+
+	// Hooks observes the byte stream; defaults to NopHooks.
+	Hooks Hooks
+}
+
+// observe installs logger and hooks on c, keeping the defaults for nil ones.
+func (c *channelSet) observe(logger *slog.Logger, hooks Hooks) {
+	if logger != nil {
+		c.logger = logger
+	}
+	if hooks != nil {
+		c.hooks = hooks
+	}
+}
+
+// crcError reports a checksum mismatch.
+func (c *channelSet) crcError(err CRCError) {
+	c.logger.Warn("dropping ESP3 frame", "err", err)
+	c.hooks.CRCError(err)
+}
+
+// resync reports bytes skipped before a sync byte.
+func (c *channelSet) resync(skipped []byte) {
+	c.logger.Debug("resynchronized on ESP3 sync byte", "skipped", len(skipped))
+	c.hooks.Resync(skipped)
+}
+
+// interByteTimeout reports an abandoned partial frame.
+func (c *channelSet) interByteTimeout(pending []byte) {
+	c.logger.Debug("inter-byte timeout, dropping partial ESP3 frame", "pending", len(pending))
+	c.hooks.InterByteTimeout(pending)
+}
+
+// unknownPacketType reports a frame of an unknown packet type.
+func (c *channelSet) unknownPacketType(packetType byte) {
+	c.logger.Warn("dropping ESP3 frame of unknown packet type", "packet_type", fmt.Sprintf("%#02x", packetType))
+	c.hooks.UnknownPacketType(packetType)
+}
+
+// dispatched reports a frame handed to every consumer.
+func (c *channelSet) dispatched(telegram esp3.Telegram, latency time.Duration) {
+	c.logger.Debug("dispatched ESP3 frame", "packet_type", telegram.PacketType, "latency", latency)
+	c.hooks.Dispatched(telegram, latency)
+}
+
+// MultiHooks returns Hooks that call every non-nil hooks in order.
+func MultiHooks(hooks ...Hooks) Hooks {
+	var m multiHooks
+	for _, h := range hooks {
+		if h != nil {
+			m = append(m, h)
+		}
+	}
+	return m
+}
+
+type multiHooks []Hooks
+
+func (m multiHooks) Frame(frame []byte) {
+	for _, h := range m {
+		h.Frame(frame)
+	}
+}
+
+func (m multiHooks) Written(frame []byte) {
+	for _, h := range m {
+		h.Written(frame)
+	}
+}
+
+func (m multiHooks) CRCError(err CRCError) {
+	for _, h := range m {
+		h.CRCError(err)
+	}
+}
+
+func (m multiHooks) Resync(skipped []byte) {
+	for _, h := range m {
+		h.Resync(skipped)
+	}
+}
+
+func (m multiHooks) InterByteTimeout(pending []byte) {
+	for _, h := range m {
+		h.InterByteTimeout(pending)
+	}
+}
+
+func (m multiHooks) UnknownPacketType(packetType byte) {
+	for _, h := range m {
+		h.UnknownPacketType(packetType)
+	}
+}
+
+func (m multiHooks) Dispatched(telegram esp3.Telegram, latency time.Duration) {
+	for _, h := range m {
+		h.Dispatched(telegram, latency)
+	}
+}
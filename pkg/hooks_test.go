@@ -0,0 +1,229 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type recordingHooks struct {
+	NopHooks
+
+	mu        sync.Mutex
+	frames    [][]byte
+	written   [][]byte
+	crcErrors []CRCError
+	resyncs   [][]byte
+	timeouts  [][]byte
+	// received concatenates the bytes of every frame, CRC error, resync and
+	// timeout in the order they were reported.
+	received     []byte
+	unknownTypes []byte
+	dispatched   []esp3.Telegram
+}
+
+func (h *recordingHooks) Frame(frame []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.frames = append(h.frames, frame)
+	h.received = append(h.received, frame...)
+}
+
+func (h *recordingHooks) Written(frame []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.written = append(h.written, frame)
+}
+
+func (h *recordingHooks) CRCError(err CRCError) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.crcErrors = append(h.crcErrors, err)
+	h.received = append(h.received, err.Dropped...)
+}
+
+func (h *recordingHooks) Resync(skipped []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.resyncs = append(h.resyncs, skipped)
+	h.received = append(h.received, skipped...)
+}
+
+func (h *recordingHooks) InterByteTimeout(pending []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.timeouts = append(h.timeouts, pending)
+	h.received = append(h.received, pending...)
+}
+
+func (h *recordingHooks) UnknownPacketType(packetType byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unknownTypes = append(h.unknownTypes, packetType)
+}
+
+func (h *recordingHooks) Dispatched(telegram esp3.Telegram, latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dispatched = append(h.dispatched, telegram)
+}
+
+type pausingReader struct {
+	chunks [][]byte
+	pause  time.Duration
+}
+
+// Read returns one chunk per call, pausing before every chunk but the first.
+func (r *pausingReader) Read(b []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	chunk := r.chunks[0]
+	if len(chunk) > len(b) {
+		panic("chunk larger than read buffer")
+	}
+	r.chunks = r.chunks[1:]
+	defer time.Sleep(r.pause)
+	return copy(b, chunk), nil
+}
+
+// parseWithHooks parses chunks and returns what hooks observed.
+func parseWithHooks(t *testing.T, logger *slog.Logger, pause time.Duration, chunks ...[]byte) *recordingHooks {
+	t.Helper()
+	hooks := &recordingHooks{}
+	set, _ := newChannelSet(16)
+	set.observe(logger, hooks)
+	if err := parse(context.Background(), &pausingReader{chunks: chunks, pause: pause}, set, newReManAssembler(remanChainPeriod)); err != io.EOF {
+		t.Fatalf("parse = %v, want io.EOF", err)
+	}
+	return hooks
+}
+
+// responseFrame returns a serialized RESPONSE telegram.
+func responseFrame() []byte {
+	return esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS)}, nil).Serialize()
+}
+
+// TestHooksReceiveFramesAndDispatch verifies HooksReceiveFramesAndDispatch behavior.
+func TestHooksReceiveFramesAndDispatch(t *testing.T) {
+	frame := responseFrame()
+	hooks := parseWithHooks(t, nil, 0, frame)
+
+	if !reflect.DeepEqual(hooks.frames, [][]byte{frame}) {
+		t.Fatalf("frames = %x, want %x", hooks.frames, frame)
+	}
+	if len(hooks.dispatched) != 1 || hooks.dispatched[0].PacketType != enums.PacketTypeRESPONSE {
+		t.Fatalf("dispatched = %+v", hooks.dispatched)
+	}
+	if len(hooks.crcErrors)+len(hooks.resyncs)+len(hooks.timeouts) != 0 {
+		t.Fatalf("unexpected diagnostics: %+v", hooks)
+	}
+}
+
+// TestHooksReportResyncAndCRCErrors verifies HooksReportResyncAndCRCErrors behavior.
+func TestHooksReportResyncAndCRCErrors(t *testing.T) {
+	badData := responseFrame()
+	badData[len(badData)-1] ^= 0xff
+	badHeader := responseFrame()
+	badHeader[5] ^= 0xff
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	stream := append(append(append([]byte{0x01, 0x02, 0x03}, badData...), badHeader...), responseFrame()...)
+	hooks := parseWithHooks(t, logger, 0, stream)
+
+	if len(hooks.crcErrors) != 2 || hooks.crcErrors[0].Field != CRCFieldData || hooks.crcErrors[1].Field != CRCFieldHeader {
+		t.Fatalf("crc errors = %+v", hooks.crcErrors)
+	}
+	if !reflect.DeepEqual(hooks.resyncs[0], []byte{0x01, 0x02, 0x03}) {
+		t.Fatalf("resyncs = %x, want the 3 skipped bytes first", hooks.resyncs)
+	}
+	if !reflect.DeepEqual(hooks.crcErrors[0].Dropped, badData) {
+		t.Fatalf("dropped = %x, want %x", hooks.crcErrors[0].Dropped, badData)
+	}
+	if len(hooks.frames) != 1 {
+		t.Fatalf("frames = %x", hooks.frames)
+	}
+	if !bytes.Equal(hooks.received, stream) {
+		t.Fatalf("reported bytes = %x, want %x", hooks.received, stream)
+	}
+	for _, want := range []string{"CRC8D mismatch", "CRC8H mismatch", "resynchronized"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("logs missing %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+// TestHooksReportUnknownPacketType verifies HooksReportUnknownPacketType behavior.
+func TestHooksReportUnknownPacketType(t *testing.T) {
+	frame := esp3.NewTelegramFromData(enums.PacketType(0x7f), []byte{0x01}, nil).Serialize()
+	hooks := parseWithHooks(t, nil, 0, frame)
+
+	if !reflect.DeepEqual(hooks.unknownTypes, []byte{0x7f}) || len(hooks.frames) != 1 || len(hooks.dispatched) != 0 {
+		t.Fatalf("unknown=%x frames=%d dispatched=%d", hooks.unknownTypes, len(hooks.frames), len(hooks.dispatched))
+	}
+}
+
+// TestHooksReportInterByteTimeout verifies HooksReportInterByteTimeout behavior.
+func TestHooksReportInterByteTimeout(t *testing.T) {
+	frame := responseFrame()
+	hooks := parseWithHooks(t, nil, 120*time.Millisecond, frame[:4], frame)
+
+	if !reflect.DeepEqual(hooks.timeouts, [][]byte{frame[:4]}) {
+		t.Fatalf("timeouts = %x, want [%x]", hooks.timeouts, frame[:4])
+	}
+	if len(hooks.frames) != 1 {
+		t.Fatalf("frames = %x", hooks.frames)
+	}
+}
+
+// TestSessionHooksSeeWrittenFrames verifies SessionHooksSeeWrittenFrames behavior.
+func TestSessionHooksSeeWrittenFrames(t *testing.T) {
+	port := newScriptedPort()
+	hooks := &recordingHooks{}
+	s := NewSession(context.Background(), port, SessionConfig{Hooks: hooks})
+	defer s.Close()
+
+	cmd, _ := commoncommand.NewRdVersion()
+	go port.respond(enums.ReturnCodeSUCCESS)
+	if _, err := s.Do(context.Background(), &cmd); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := cmd.Serialize()
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if !reflect.DeepEqual(hooks.written, [][]byte{want.Serialize()}) {
+		t.Fatalf("written = %x", hooks.written)
+	}
+	if len(hooks.frames) != 1 {
+		t.Fatalf("frames = %x", hooks.frames)
+	}
+}
+
+// TestMultiHooksFansOut verifies MultiHooksFansOut behavior.
+func TestMultiHooksFansOut(t *testing.T) {
+	a, b := &recordingHooks{}, &recordingHooks{}
+	frame := responseFrame()
+	set, _ := newChannelSet(16)
+	set.observe(nil, MultiHooks(a, nil, b))
+	if err := parse(context.Background(), bytes.NewReader(append([]byte{0x00}, frame...)), set, newReManAssembler(remanChainPeriod)); err != io.EOF {
+		t.Fatalf("parse = %v", err)
+	}
+
+	for _, h := range []*recordingHooks{a, b} {
+		if !reflect.DeepEqual(h.frames, [][]byte{frame}) || !reflect.DeepEqual(h.resyncs, [][]byte{{0x00}}) || len(h.dispatched) != 1 {
+			t.Fatalf("hooks = %+v", h)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+)
+
+const (
+	idBaseRangeSize             = 128
+	defaultIDBaseWriteWarnLimit = 3
+)
+
+var (
+	ErrIDRangeExhausted = errors.New("ID base range exhausted")
+	ErrForeignSenderID  = errors.New("sender ID is neither the chip ID nor inside the ID base range")
+)
+
+// IDAllocatorConfig tunes an IDAllocator. Zero fields keep their defaults.
+type IDAllocatorConfig struct {
+	// Store persists the assignments; defaults to a MemoryIDStore.
+	Store IDStore
+
+	// Logger receives warnings; defaults to the session's logger, or
+	// slog.Default() when the session has none.
+	Logger *slog.Logger
+
+	// WriteWarnLimit warns when at most this many WR_IDBASE writes are
+	// left. Defaults to 3.
+	WriteWarnLimit int
+}
+
+// IDAllocator hands out the 128 sender IDs of the transceiver's ID base
+// range, one stable ID per logical device name.
+type IDAllocator struct {
+	baseID          deviceid.DeviceID
+	chipID          deviceid.DeviceID
+	remainingWrites uint8
+	store           IDStore
+
+	mu  sync.Mutex
+	ids map[string]deviceid.DeviceID
+}
+
+// NewIDAllocator reads the ID base and chip ID through s, loads the stored
+// assignments and makes s.Transmit reject sender IDs the transceiver does
+// not own. Stored IDs from another base are moved into the current range,
+// keeping their offset unless another name already uses it, in which case
+// they get the lowest free ID.
+func NewIDAllocator(ctx context.Context, s *Session, cfg ...IDAllocatorConfig) (*IDAllocator, error) {
+	c := IDAllocatorConfig{Store: NewMemoryIDStore(), Logger: s.cfg.Logger, WriteWarnLimit: defaultIDBaseWriteWarnLimit}
+	if c.Logger == nil {
+		c.Logger = slog.Default()
+	}
+	for _, o := range cfg {
+		if o.Store != nil {
+			c.Store = o.Store
+		}
+		if o.Logger != nil {
+			c.Logger = o.Logger
+		}
+		if o.WriteWarnLimit > 0 {
+			c.WriteWarnLimit = o.WriteWarnLimit
+		}
+	}
+
+	rdIDBase, _ := commoncommand.NewRdIDBase()
+	base, err := Exec(ctx, s, &rdIDBase)
+	if err != nil {
+		return nil, fmt.Errorf("read ID base: %w", err)
+	}
+	rdVersion, _ := commoncommand.NewRdVersion()
+	version, err := Exec(ctx, s, &rdVersion)
+	if err != nil {
+		return nil, fmt.Errorf("read chip ID: %w", err)
+	}
+
+	ids, err := c.Store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("load ID assignments: %w", err)
+	}
+
+	a := &IDAllocator{
+		baseID:          base.BaseID,
+		chipID:          deviceid.DeviceID(version.ChipID),
+		remainingWrites: base.RemainingWriteCount,
+		store:           c.Store,
+		ids:             make(map[string]deviceid.DeviceID, len(ids)),
+	}
+
+	var moved []string
+	for name, id := range ids {
+		if !a.inRange(id) {
+			moved = append(moved, name)
+			continue
+		}
+		a.ids[name] = id
+	}
+	slices.Sort(moved)
+	for _, name := range moved {
+		id, ok := a.freeID(a.baseID + ids[name]%idBaseRangeSize)
+		if !ok {
+			return nil, fmt.Errorf("move %s into the ID base: %w", name, ErrIDRangeExhausted)
+		}
+		c.Logger.Warn("sender ID outside the current ID base, moving it", "name", name, "id", ids[name], "base", a.baseID, "moved", id)
+		a.ids[name] = id
+	}
+	if len(moved) > 0 {
+		if err := a.store.Save(maps.Clone(a.ids)); err != nil {
+			return nil, fmt.Errorf("save ID assignments: %w", err)
+		}
+	}
+
+	if int(a.remainingWrites) <= c.WriteWarnLimit {
+		c.Logger.Warn("few WR_IDBASE writes left; the ID base can only be changed a limited number of times", "remaining", a.remainingWrites, "base", a.baseID)
+	}
+
+	s.tx.setSenderCheck(a.Validate)
+	return a, nil
+}
+
+// BaseID returns the first ID of the transceiver's ID base range.
+func (a *IDAllocator) BaseID() deviceid.DeviceID { return a.baseID }
+
+// ChipID returns the transceiver's unique chip ID.
+func (a *IDAllocator) ChipID() deviceid.DeviceID { return a.chipID }
+
+// RemainingWrites returns how many times the ID base can still be changed.
+func (a *IDAllocator) RemainingWrites() uint8 { return a.remainingWrites }
+
+// Allocate returns the sender ID assigned to name, assigning the lowest free
+// ID of the range on first use.
+func (a *IDAllocator) Allocate(name string) (deviceid.DeviceID, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id, ok := a.ids[name]; ok {
+		return id, nil
+	}
+
+	id, ok := a.freeID(a.baseID)
+	if !ok {
+		return 0, ErrIDRangeExhausted
+	}
+	a.ids[name] = id
+	if err := a.store.Save(maps.Clone(a.ids)); err != nil {
+		delete(a.ids, name)
+		return 0, err
+	}
+	return id, nil
+}
+
+// freeID returns preferred if no name uses it, or else the lowest free ID
+// of the range. The caller holds a.mu or owns a exclusively.
+func (a *IDAllocator) freeID(preferred deviceid.DeviceID) (deviceid.DeviceID, bool) {
+	used := make(map[deviceid.DeviceID]bool, len(a.ids))
+	for _, id := range a.ids {
+		used[id] = true
+	}
+	if !used[preferred] {
+		return preferred, true
+	}
+	for offset := range deviceid.DeviceID(idBaseRangeSize) {
+		if id := a.baseID + offset; !used[id] {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// Lookup returns the sender ID assigned to name.
+func (a *IDAllocator) Lookup(name string) (deviceid.DeviceID, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	id, ok := a.ids[name]
+	return id, ok
+}
+
+// Release frees the sender ID assigned to name. Devices that learned the ID
+// keep reacting to it, so release only IDs no actuator has been taught.
+func (a *IDAllocator) Release(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	id, ok := a.ids[name]
+	if !ok {
+		return nil
+	}
+	delete(a.ids, name)
+	if err := a.store.Save(maps.Clone(a.ids)); err != nil {
+		a.ids[name] = id
+		return err
+	}
+	return nil
+}
+
+// Owns reports whether the transceiver may send as id.
+func (a *IDAllocator) Owns(id deviceid.DeviceID) bool {
+	return id == a.chipID || a.inRange(id)
+}
+
+// Validate rejects packets whose sender the transceiver does not own.
+func (a *IDAllocator) Validate(p erp1.Packet) error {
+	if !a.Owns(p.SenderID) {
+		return fmt.Errorf("%w: %s", ErrForeignSenderID, p.SenderID)
+	}
+	return nil
+}
+
+// inRange reports whether id lies inside the ID base range.
+func (a *IDAllocator) inRange(id deviceid.DeviceID) bool {
+	return id >= a.baseID && id < a.baseID+idBaseRangeSize
+}
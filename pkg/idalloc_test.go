@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+)
+
+// newTestAllocator answers RD_IDBASE and RD_VERSION for an allocator on a
+// scripted session.
+func newTestAllocator(t *testing.T, base deviceid.DeviceID, remainingWrites byte, cfg IDAllocatorConfig) (*IDAllocator, *Session, *scriptedPort) {
+	t.Helper()
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	t.Cleanup(func() { s.Close() })
+
+	go func() {
+		b := base.ToArray()
+		port.respond(enums.ReturnCodeSUCCESS, b[0], b[1], b[2], b[3], remainingWrites)
+		version := []byte{2, 11, 1, 0, 2, 6, 3, 0, 0x01, 0x82, 0x5e, 0x7a, 0x45, 0x4f, 0x01, 0x03}
+		port.respond(enums.ReturnCodeSUCCESS, append(version, "GATEWAYCTRL"...)...)
+	}()
+
+	a, err := NewIDAllocator(context.Background(), s, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, s, port
+}
+
+// TestIDAllocatorAssignsStableIDs verifies IDAllocatorAssignsStableIDs behavior.
+func TestIDAllocatorAssignsStableIDs(t *testing.T) {
+	store := NewMemoryIDStore()
+	a, _, _ := newTestAllocator(t, 0xff800000, 10, IDAllocatorConfig{Store: store})
+
+	if a.BaseID() != 0xff800000 || a.ChipID() != 0x01825e7a || a.RemainingWrites() != 10 {
+		t.Fatalf("base=%s chip=%s writes=%d", a.BaseID(), a.ChipID(), a.RemainingWrites())
+	}
+
+	light, _ := a.Allocate("light")
+	blind, _ := a.Allocate("blind")
+	again, _ := a.Allocate("light")
+	if light != 0xff800000 || blind != 0xff800001 || again != light {
+		t.Fatalf("light=%s blind=%s again=%s", light, blind, again)
+	}
+
+	if err := a.Release("light"); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := a.Allocate("fan"); id != 0xff800000 {
+		t.Fatalf("fan = %s, want the released ID", id)
+	}
+	if stored, _ := store.Load(); stored["fan"] != 0xff800000 || stored["blind"] != 0xff800001 || len(stored) != 2 {
+		t.Fatalf("stored = %v", stored)
+	}
+}
+
+// TestIDAllocatorExhaustsRange verifies IDAllocatorExhaustsRange behavior.
+func TestIDAllocatorExhaustsRange(t *testing.T) {
+	a, _, _ := newTestAllocator(t, 0xff800080, 10, IDAllocatorConfig{})
+	for i := range idBaseRangeSize {
+		if _, err := a.Allocate(string(rune('A' + i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := a.Allocate("one too many"); !errors.Is(err, ErrIDRangeExhausted) {
+		t.Fatalf("err = %v, want ErrIDRangeExhausted", err)
+	}
+}
+
+// TestIDAllocatorLoadsAndRebasesStore verifies IDAllocatorLoadsAndRebasesStore behavior.
+func TestIDAllocatorLoadsAndRebasesStore(t *testing.T) {
+	store := NewFileIDStore(filepath.Join(t.TempDir(), "ids.json"))
+	if err := store.Save(map[string]deviceid.DeviceID{"light": 0xff800005, "blind": 0xff900002}); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	a, _, _ := newTestAllocator(t, 0xff800000, 10, IDAllocatorConfig{Store: store, Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+
+	if id, ok := a.Lookup("light"); !ok || id != 0xff800005 {
+		t.Fatalf("light = %s, %t", id, ok)
+	}
+	if id, _ := a.Lookup("blind"); id != 0xff800002 {
+		t.Fatalf("blind = %s, want it moved into the range", id)
+	}
+	if !strings.Contains(logs.String(), "outside the current ID base") {
+		t.Fatalf("logs = %s", logs.String())
+	}
+	if stored, _ := store.Load(); stored["blind"] != 0xff800002 {
+		t.Fatalf("stored = %v", stored)
+	}
+	if id, _ := a.Allocate("fan"); id != 0xff800000 {
+		t.Fatalf("fan = %s", id)
+	}
+}
+
+// TestIDAllocatorRebaseAvoidsCollisions verifies IDAllocatorRebaseAvoidsCollisions behavior.
+func TestIDAllocatorRebaseAvoidsCollisions(t *testing.T) {
+	store := NewMemoryIDStore()
+	if err := store.Save(map[string]deviceid.DeviceID{
+		"light":  0xff800002,
+		"blind":  0xff900002,
+		"fan":    0xffa00002,
+		"heater": 0xffa00003,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	a, _, _ := newTestAllocator(t, 0xff800000, 10, IDAllocatorConfig{Store: store})
+
+	want := map[string]deviceid.DeviceID{"light": 0xff800002, "blind": 0xff800000, "fan": 0xff800001, "heater": 0xff800003}
+	for name, id := range want {
+		if got, _ := a.Lookup(name); got != id {
+			t.Errorf("%s = %s, want %s", name, got, id)
+		}
+	}
+	if stored, _ := store.Load(); len(stored) != len(want) || stored["fan"] != 0xff800001 {
+		t.Fatalf("stored = %v", stored)
+	}
+}
+
+// TestIDAllocatorWarnsAboutWriteCount verifies IDAllocatorWarnsAboutWriteCount behavior.
+func TestIDAllocatorWarnsAboutWriteCount(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	newTestAllocator(t, 0xff800000, 4, IDAllocatorConfig{Logger: logger})
+	if logs.Len() != 0 {
+		t.Fatalf("unexpected warning: %s", logs.String())
+	}
+	newTestAllocator(t, 0xff800000, 2, IDAllocatorConfig{Logger: logger})
+	if !strings.Contains(logs.String(), "WR_IDBASE") || !strings.Contains(logs.String(), "remaining=2") {
+		t.Fatalf("logs = %s", logs.String())
+	}
+}
+
+// TestIDAllocatorValidatesTransmit verifies IDAllocatorValidatesTransmit behavior.
+func TestIDAllocatorValidatesTransmit(t *testing.T) {
+	a, s, port := newTestAllocator(t, 0xff800000, 10, IDAllocatorConfig{})
+
+	for _, id := range []deviceid.DeviceID{0x01825e7a, 0xff800000, 0xff80007f} {
+		if !a.Owns(id) {
+			t.Fatalf("%s not owned", id)
+		}
+	}
+	if err := s.Transmit(context.Background(), rockerPacket(0xff800080, true)); !errors.Is(err, ErrForeignSenderID) {
+		t.Fatalf("err = %v, want ErrForeignSenderID", err)
+	}
+
+	id, _ := a.Allocate("light")
+	result := transmitAsync(context.Background(), s, rockerPacket(id, true))
+	port.respond(enums.ReturnCodeSUCCESS)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestFileIDStoreMissingFile verifies FileIDStoreMissingFile behavior.
+func TestFileIDStoreMissingFile(t *testing.T) {
+	ids, err := NewFileIDStore(filepath.Join(t.TempDir(), "missing.json")).Load()
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("ids=%v err=%v", ids, err)
+	}
+}
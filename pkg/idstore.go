@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"sync"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+)
+
+// IDStore persists the sender IDs assigned to logical devices.
+type IDStore interface {
+	Load() (map[string]deviceid.DeviceID, error)
+	Save(ids map[string]deviceid.DeviceID) error
+}
+
+// MemoryIDStore keeps assignments in memory only.
+type MemoryIDStore struct {
+	mu  sync.Mutex
+	ids map[string]deviceid.DeviceID
+}
+
+// NewMemoryIDStore constructs an empty MemoryIDStore.
+func NewMemoryIDStore() *MemoryIDStore {
+	return &MemoryIDStore{ids: map[string]deviceid.DeviceID{}}
+}
+
+// Load returns a copy of the stored assignments.
+func (m *MemoryIDStore) Load() (map[string]deviceid.DeviceID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.ids), nil
+}
+
+// Save replaces the stored assignments.
+func (m *MemoryIDStore) Save(ids map[string]deviceid.DeviceID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ids = maps.Clone(ids)
+	return nil
+}
+
+// FileIDStore keeps assignments in a JSON file mapping names to hex IDs.
+type FileIDStore struct {
+	Path string
+}
+
+// NewFileIDStore constructs a FileIDStore backed by path.
+func NewFileIDStore(path string) *FileIDStore {
+	return &FileIDStore{Path: path}
+}
+
+// Load reads the assignments. A missing file holds none.
+func (f *FileIDStore) Load() (map[string]deviceid.DeviceID, error) {
+	b, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]deviceid.DeviceID{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	ids := make(map[string]deviceid.DeviceID, len(raw))
+	for name, hexID := range raw {
+		id, err := deviceid.FromHexString(hexID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", f.Path, name, err)
+		}
+		ids[name] = id
+	}
+	return ids, nil
+}
+
+// Save writes the assignments, replacing the file atomically.
+func (f *FileIDStore) Save(ids map[string]deviceid.DeviceID) error {
+	raw := make(map[string]string, len(ids))
+	for name, id := range ids {
+		raw[name] = id.String()
+	}
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.Path)
+}
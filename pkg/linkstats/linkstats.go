@@ -0,0 +1,164 @@
+package linkstats
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
+)
+
+// Link is a snapshot of the reception statistics of one sender. RSSI values
+// are in dBm.
+type Link struct {
+	Sender    deviceid.DeviceID
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	Telegrams    uint64
+	SubTelegrams uint64
+	// Repeated counts telegrams received through at least one repeater.
+	Repeated uint64
+
+	// RSSISamples counts the telegrams that carried an RSSI value.
+	RSSISamples uint64
+	RSSIMin     int
+	RSSIMax     int
+	RSSIAvg     float64
+}
+
+// RepeatedRatio returns the share of telegrams received through a repeater.
+func (l Link) RepeatedRatio() float64 {
+	if l.Telegrams == 0 {
+		return 0
+	}
+	return float64(l.Repeated) / float64(l.Telegrams)
+}
+
+// Rate returns the average number of telegrams per minute between the first
+// and the last telegram, or 0 before a second telegram has been seen.
+func (l Link) Rate() float64 {
+	span := l.LastSeen.Sub(l.FirstSeen)
+	if l.Telegrams < 2 || span <= 0 {
+		return 0
+	}
+	return float64(l.Telegrams-1) / span.Minutes()
+}
+
+// Config tunes a Collector. Zero fields keep their defaults.
+type Config struct {
+	// Now returns the reception time of a telegram; defaults to time.Now.
+	Now func() time.Time
+}
+
+// Collector aggregates reception statistics per sender. It is safe for
+// concurrent use, so snapshots can be taken while a session observes
+// telegrams.
+type Collector struct {
+	now func() time.Time
+
+	mu    sync.Mutex
+	links map[deviceid.DeviceID]*link
+}
+
+type link struct {
+	Link
+	rssiSum int64
+}
+
+// New constructs an empty Collector.
+func New(cfg ...Config) *Collector {
+	c := &Collector{now: time.Now, links: map[deviceid.DeviceID]*link{}}
+	for _, o := range cfg {
+		if o.Now != nil {
+			c.now = o.Now
+		}
+	}
+	return c
+}
+
+// Observe records an ERP1 telegram.
+func (c *Collector) Observe(p erp1.Packet) {
+	c.observe(p.SenderID, uint64(p.SubTelNum), p.Rssi, p.RepeaterCount() != 0)
+}
+
+// ObserveSubTel records a telegram with its subtelegrams. The telegram
+// counts as repeated when any subtelegram arrived through a repeater.
+func (c *Collector) ObserveSubTel(p subtel.Packet) {
+	repeated := p.Status&erp1.RepeaterCountMask != 0
+	for _, st := range p.SubTels {
+		repeated = repeated || st.Status&erp1.RepeaterCountMask != 0
+	}
+	subTels := uint64(len(p.SubTels))
+	if subTels == 0 {
+		subTels = uint64(p.SubTelNum)
+	}
+	c.observe(p.SenderID, subTels, p.Rssi, repeated)
+}
+
+// observe records one telegram of sender.
+func (c *Collector) observe(sender deviceid.DeviceID, subTels uint64, rssi byte, repeated bool) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l, ok := c.links[sender]
+	if !ok {
+		l = &link{Link: Link{Sender: sender, FirstSeen: now}}
+		c.links[sender] = l
+	}
+	l.LastSeen = now
+	l.Telegrams++
+	l.SubTelegrams += subTels
+	if repeated {
+		l.Repeated++
+	}
+
+	if rssi == erp1.RssiUnknown {
+		return
+	}
+	dBm := -int(rssi)
+	if l.RSSISamples == 0 || dBm < l.RSSIMin {
+		l.RSSIMin = dBm
+	}
+	if l.RSSISamples == 0 || dBm > l.RSSIMax {
+		l.RSSIMax = dBm
+	}
+	l.RSSISamples++
+	l.rssiSum += int64(dBm)
+	l.RSSIAvg = float64(l.rssiSum) / float64(l.RSSISamples)
+}
+
+// Link returns the statistics of sender.
+func (c *Collector) Link(sender deviceid.DeviceID) (Link, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l, ok := c.links[sender]
+	if !ok {
+		return Link{}, false
+	}
+	return l.Link, true
+}
+
+// Snapshot returns the statistics of every sender, sorted by sender ID.
+func (c *Collector) Snapshot() []Link {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	links := make([]Link, 0, len(c.links))
+	for _, l := range c.links {
+		links = append(links, l.Link)
+	}
+	slices.SortFunc(links, func(a, b Link) int { return cmp.Compare(a.Sender, b.Sender) })
+	return links
+}
+
+// Reset forgets every sender, e.g. after a repeater has been moved.
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.links)
+}
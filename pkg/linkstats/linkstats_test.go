@@ -0,0 +1,79 @@
+package linkstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/subtel"
+)
+
+// fakeClock returns a clock that advances by step on every call.
+func fakeClock(start time.Time, step time.Duration) func() time.Time {
+	now := start.Add(-step)
+	return func() time.Time {
+		now = now.Add(step)
+		return now
+	}
+}
+
+// TestCollectorAggregatesPerSender verifies CollectorAggregatesPerSender behavior.
+func TestCollectorAggregatesPerSender(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New(Config{Now: fakeClock(start, 30*time.Second)})
+
+	c.Observe(erp1.Packet{SenderID: 2, Rorg: enums.RorgRPS, Rssi: 0x40, SubTelNum: 3, Status: 0x30})
+	c.Observe(erp1.Packet{SenderID: 2, Rorg: enums.RorgRPS, Rssi: 0x50, SubTelNum: 2, Status: 0x31})
+	c.Observe(erp1.Packet{SenderID: 2, Rorg: enums.RorgRPS, Rssi: 0xff, SubTelNum: 1, Status: 0x30})
+	c.Observe(erp1.Packet{SenderID: 1, Rorg: enums.Rorg1BS, Rssi: 0x48, SubTelNum: 1})
+
+	l, ok := c.Link(2)
+	if !ok {
+		t.Fatal("sender 2 not tracked")
+	}
+	if l.Telegrams != 3 || l.SubTelegrams != 6 || l.Repeated != 1 {
+		t.Fatalf("counts = %+v", l)
+	}
+	if l.RSSISamples != 2 || l.RSSIMin != -80 || l.RSSIMax != -64 || l.RSSIAvg != -72 {
+		t.Fatalf("rssi = %+v", l)
+	}
+	if !l.FirstSeen.Equal(start) || !l.LastSeen.Equal(start.Add(time.Minute)) {
+		t.Fatalf("seen = %s..%s", l.FirstSeen, l.LastSeen)
+	}
+	if l.Rate() != 2 {
+		t.Fatalf("rate = %v, want 2 per minute", l.Rate())
+	}
+	if ratio := l.RepeatedRatio(); ratio < 0.33 || ratio > 0.34 {
+		t.Fatalf("repeated ratio = %v", ratio)
+	}
+
+	snapshot := c.Snapshot()
+	if len(snapshot) != 2 || snapshot[0].Sender != 1 || snapshot[1].Sender != 2 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+	if snapshot[0].Rate() != 0 {
+		t.Fatalf("single telegram rate = %v", snapshot[0].Rate())
+	}
+
+	c.Reset()
+	if len(c.Snapshot()) != 0 {
+		t.Fatal("Reset kept senders")
+	}
+}
+
+// TestCollectorObserveSubTel verifies CollectorObserveSubTel behavior.
+func TestCollectorObserveSubTel(t *testing.T) {
+	c := New()
+	c.ObserveSubTel(subtel.Packet{
+		SenderID:  5,
+		Rssi:      0x3c,
+		SubTelNum: 3,
+		SubTels:   []subtel.SubTel{{Tick: 0, Rssi: 0x3c, Status: 0}, {Tick: 4, Rssi: 0x50, Status: 1}},
+	})
+
+	l, _ := c.Link(5)
+	if l.SubTelegrams != 2 || l.Repeated != 1 || l.RSSIMax != -60 {
+		t.Fatalf("link = %+v", l)
+	}
+}
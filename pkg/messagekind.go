@@ -0,0 +1,78 @@
+package pkg
+
+// MessageKind identifies what a Message carries in Data.
+type MessageKind uint8
+
+const (
+	MessageKindESP3 MessageKind = iota
+	MessageKindERP1
+	MessageKindResponse
+	MessageKindEvent
+	MessageKindSmartAck
+	MessageKindReMan
+	MessageKindReManPart
+	MessageKindGPHeader
+	MessageKindUnparsed
+	MessageKindParseError
+	MessageKindDecoded
+	MessageKindDeduplicated
+	MessageKindSubTel
+	MessageKindReManCommand
+	MessageKindRadioMessage
+	MessageKindRadioERP2
+	MessageKindConfigCommand
+	MessageKindCommandAccepted
+	MessageKindRadio802154
+	MessageKindCommand24
+	MessageKindERP2
+)
+
+// String returns the string representation of MessageKind.
+func (kind MessageKind) String() string {
+	switch kind {
+	case MessageKindESP3:
+		return "esp3"
+	case MessageKindERP1:
+		return "erp1"
+	case MessageKindResponse:
+		return "response"
+	case MessageKindEvent:
+		return "event"
+	case MessageKindSmartAck:
+		return "smart_ack"
+	case MessageKindReMan:
+		return "reman"
+	case MessageKindReManPart:
+		return "reman_part"
+	case MessageKindGPHeader:
+		return "gp_header"
+	case MessageKindUnparsed:
+		return "unparsed"
+	case MessageKindParseError:
+		return "parse_error"
+	case MessageKindDecoded:
+		return "decoded"
+	case MessageKindDeduplicated:
+		return "deduplicated"
+	case MessageKindSubTel:
+		return "sub_tel"
+	case MessageKindReManCommand:
+		return "reman_command"
+	case MessageKindRadioMessage:
+		return "radio_message"
+	case MessageKindRadioERP2:
+		return "radio_erp2"
+	case MessageKindConfigCommand:
+		return "config_command"
+	case MessageKindCommandAccepted:
+		return "command_accepted"
+	case MessageKindRadio802154:
+		return "radio_802_15_4"
+	case MessageKindCommand24:
+		return "command_2_4"
+	case MessageKindERP2:
+		return "erp2"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,48 @@
+// Package radio802154 parses and builds RADIO_802_15_4 telegrams, which carry
+// raw IEEE 802.15.4 frames between the host and a 2.4 GHz transceiver.
+package radio802154
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type Packet struct {
+	Data []byte
+	Rssi byte
+}
+
+// NewPacketFromEsp3 parses an IEEE 802.15.4 frame from an ESP3 telegram.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const rssiOffset = 0
+
+	if telegram.PacketType != enums.PacketTypeRADIO_802_15_4 {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) == 0 {
+		return Packet{}, errors.New("data too short")
+	}
+
+	p := Packet{Data: telegram.Data}
+	if len(telegram.OptData) > rssiOffset {
+		p.Rssi = telegram.OptData[rssiOffset]
+	}
+	return p, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	return esp3.Telegram{
+		PacketType: enums.PacketTypeRADIO_802_15_4,
+		Data:       p.Data,
+		OptData:    []byte{p.Rssi},
+	}
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
@@ -0,0 +1,36 @@
+package radio802154
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRADIO_802_15_4, []byte{0x41, 0x88, 0x01}, []byte{0x52}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Packet{Data: []byte{0x41, 0x88, 0x01}, Rssi: 0x52}); !reflect.DeepEqual(p, want) {
+		t.Errorf("packet = %+v", p)
+	}
+
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP2, []byte{0x41}, nil)); err == nil {
+		t.Error("ERP2 packet accepted")
+	}
+	if _, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRADIO_802_15_4, nil, nil)); err == nil {
+		t.Error("empty frame accepted")
+	}
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	p := Packet{Data: []byte{0x41, 0x88}, Rssi: 0x30}
+	parsed, err := NewPacketFromEsp3(p.ToEsp3())
+	if err != nil || !reflect.DeepEqual(parsed, p) {
+		t.Fatalf("round trip = %+v, err = %v", parsed, err)
+	}
+}
@@ -0,0 +1,58 @@
+// Package radioerp2 parses and builds RADIO_ERP2 telegrams, which carry a raw
+// ERP2 radio telegram between the host and a transceiver in ERP2 mode.
+package radioerp2
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type Packet struct {
+	Data      []byte
+	SubTelNum byte
+	Dbm       byte
+}
+
+// NewPacketFromEsp3 parses an ERP2 radio packet from an ESP3 telegram.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const optDataLen = 2 // 1 subTelNum + 1 dBm
+	const subTelNumOffset = 0
+	const dbmOffset = 1
+
+	if telegram.PacketType != enums.PacketTypeRADIO_ERP2 {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) == 0 {
+		return Packet{}, errors.New("data too short")
+	}
+
+	p := Packet{Data: telegram.Data}
+
+	switch {
+	case len(telegram.OptData) == 0:
+		return p, nil
+	case len(telegram.OptData) < optDataLen:
+		return Packet{}, errors.New("optData too short")
+	}
+
+	p.SubTelNum = telegram.OptData[subTelNumOffset]
+	p.Dbm = telegram.OptData[dbmOffset]
+	return p, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	return esp3.Telegram{
+		PacketType: enums.PacketTypeRADIO_ERP2,
+		Data:       p.Data,
+		OptData:    []byte{p.SubTelNum, p.Dbm},
+	}
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
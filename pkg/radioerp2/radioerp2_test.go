@@ -0,0 +1,43 @@
+package radioerp2
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	t.Run("parses data and optional data", func(t *testing.T) {
+		p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP2, []byte{0x20, 0x01, 0x02, 0x03, 0x04, 0x08, 0x5c}, []byte{0x03, 0x45}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Packet{Data: []byte{0x20, 0x01, 0x02, 0x03, 0x04, 0x08, 0x5c}, SubTelNum: 3, Dbm: 0x45}); !reflect.DeepEqual(p, want) {
+			t.Errorf("packet = %+v", p)
+		}
+	})
+
+	t.Run("rejects invalid telegrams", func(t *testing.T) {
+		for _, telegram := range []esp3.Telegram{
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP1, []byte{0x20}, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP2, nil, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP2, []byte{0x20}, []byte{0x03}),
+		} {
+			if _, err := NewPacketFromEsp3(telegram); err == nil {
+				t.Errorf("%+v accepted", telegram)
+			}
+		}
+	})
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	p := Packet{Data: []byte{0x20, 0x01}, SubTelNum: 1, Dbm: 0xff}
+	parsed, err := NewPacketFromEsp3(p.ToEsp3())
+	if err != nil || !reflect.DeepEqual(parsed, p) {
+		t.Fatalf("round trip = %+v, err = %v", parsed, err)
+	}
+}
@@ -0,0 +1,83 @@
+// Package radiomessage parses and builds RADIO_MESSAGE telegrams, which carry
+// a whole radio message, already merged from its chained telegrams, between
+// the host and the transceiver.
+package radiomessage
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type Packet struct {
+	Rorg          enums.Rorg
+	Data          []byte
+	DestinationID deviceid.DeviceID
+	SourceID      deviceid.DeviceID
+	Dbm           byte
+	SecurityLevel byte
+}
+
+// NewPacketFromEsp3 parses a radio message from an ESP3 telegram. Without
+// optional data the message is addressed to everyone.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const rorgOffset = 0
+	const optDataLen = 10 // 4 destination ID + 4 source ID + 1 dBm + 1 security level
+	const destinationIDOffset = 0
+	const sourceIDOffset = 4
+	const dbmOffset = 8
+	const securityLevelOffset = 9
+
+	if telegram.PacketType != enums.PacketTypeRADIO_MESSAGE {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) == 0 {
+		return Packet{}, errors.New("data too short")
+	}
+
+	p := Packet{
+		Rorg:          enums.Rorg(telegram.Data[rorgOffset]),
+		Data:          telegram.Data[rorgOffset+1:],
+		DestinationID: deviceid.BroadcastId(),
+	}
+
+	switch {
+	case len(telegram.OptData) == 0:
+		return p, nil
+	case len(telegram.OptData) < optDataLen:
+		return Packet{}, errors.New("optData too short")
+	}
+
+	p.DestinationID = deviceid.DeviceID(binary.BigEndian.Uint32(telegram.OptData[destinationIDOffset:]))
+	p.SourceID = deviceid.DeviceID(binary.BigEndian.Uint32(telegram.OptData[sourceIDOffset:]))
+	p.Dbm = telegram.OptData[dbmOffset]
+	p.SecurityLevel = telegram.OptData[securityLevelOffset]
+	return p, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	data := make([]byte, 0, 1+len(p.Data))
+	data = append(data, byte(p.Rorg))
+	data = append(data, p.Data...)
+
+	optData := make([]byte, 0, 10)
+	optData = binary.BigEndian.AppendUint32(optData, uint32(p.DestinationID))
+	optData = binary.BigEndian.AppendUint32(optData, uint32(p.SourceID))
+	optData = append(optData, p.Dbm, p.SecurityLevel)
+
+	return esp3.Telegram{
+		PacketType: enums.PacketTypeRADIO_MESSAGE,
+		Data:       data,
+		OptData:    optData,
+	}
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
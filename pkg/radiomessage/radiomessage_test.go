@@ -0,0 +1,61 @@
+package radiomessage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	t.Run("parses data and optional data", func(t *testing.T) {
+		telegram := esp3.NewTelegramFromData(enums.PacketTypeRADIO_MESSAGE,
+			[]byte{0xd1, 0x07, 0xff, 0x01},
+			[]byte{0x01, 0x02, 0x03, 0x04, 0x0a, 0x0b, 0x0c, 0x0d, 0x40, 0x03})
+
+		p, err := NewPacketFromEsp3(telegram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Packet{Rorg: enums.RorgMSC, Data: []byte{0x07, 0xff, 0x01}, DestinationID: 0x01020304, SourceID: 0x0a0b0c0d, Dbm: 0x40, SecurityLevel: 0x03}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("packet = %+v", p)
+		}
+	})
+
+	t.Run("addresses everyone without optional data", func(t *testing.T) {
+		p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeRADIO_MESSAGE, []byte{0xd1}, nil))
+		if err != nil || p.DestinationID != deviceid.BroadcastId() {
+			t.Errorf("packet = %+v, err = %v", p, err)
+		}
+	})
+
+	t.Run("rejects invalid telegrams", func(t *testing.T) {
+		for _, telegram := range []esp3.Telegram{
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP1, []byte{0xd1}, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_MESSAGE, nil, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_MESSAGE, []byte{0xd1}, []byte{0x01, 0x02}),
+		} {
+			if _, err := NewPacketFromEsp3(telegram); err == nil {
+				t.Errorf("%+v accepted", telegram)
+			}
+		}
+	})
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	p := Packet{Rorg: enums.RorgVLD, Data: []byte{0x01, 0x02}, DestinationID: deviceid.BroadcastId(), SourceID: 0x0a0b0c0d, Dbm: 0xff, SecurityLevel: 0x00}
+	telegram := p.ToEsp3()
+	if telegram.PacketType != enums.PacketTypeRADIO_MESSAGE || !reflect.DeepEqual(telegram.Data, []byte{0xd2, 0x01, 0x02}) || len(telegram.OptData) != 10 {
+		t.Fatalf("telegram = %+v", telegram)
+	}
+
+	parsed, err := NewPacketFromEsp3(telegram)
+	if err != nil || !reflect.DeepEqual(parsed, p) {
+		t.Fatalf("round trip = %+v, err = %v", parsed, err)
+	}
+}
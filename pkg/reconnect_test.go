@@ -0,0 +1,178 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// nextState waits for the next connection event.
+func nextState(t *testing.T, s *Session) ConnEvent {
+	t.Helper()
+	select {
+	case e, ok := <-s.State:
+		if !ok {
+			t.Fatal("state channel closed")
+		}
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for connection event")
+		return ConnEvent{}
+	}
+}
+
+// TestDialSessionReconnectsAndReplaysSetup verifies DialSessionReconnectsAndReplaysSetup behavior.
+func TestDialSessionReconnectsAndReplaysSetup(t *testing.T) {
+	devices := make(chan Transport, 4)
+	var dials atomic.Int32
+	dial := func(context.Context) (Transport, error) {
+		if dials.Add(1) == 2 {
+			return nil, errors.New("re-enumerating")
+		}
+		host, device := NewLoopback()
+		devices <- device
+		return host, nil
+	}
+
+	setup, _ := commoncommand.NewWrRepeater(enums.RepeaterModeON, enums.RepeaterLevel1_REPETITION)
+	want, _ := setup.Serialize()
+	s, err := DialSession(context.Background(), dial, SessionConfig{Setup: []Command{&setup}, MinBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	device := <-devices
+	frame, err := answerNext(device)
+	if err != nil || !reflect.DeepEqual(frame.Data, want.Data) {
+		t.Fatalf("setup frame = %+v err = %v", frame, err)
+	}
+	if e := nextState(t, s); e.State != ConnStateConnected || e.Err != nil {
+		t.Fatalf("event = %+v", e)
+	}
+	s.setRadioMode(enums.RadioModeERP2)
+
+	_ = device.Close()
+	if e := nextState(t, s); e.State != ConnStateLost || e.Err == nil {
+		t.Fatalf("event = %+v", e)
+	}
+	if e := nextState(t, s); e.State != ConnStateReconnecting || e.Attempt != 1 || e.Err == nil {
+		t.Fatalf("event = %+v", e)
+	}
+
+	device = <-devices
+	frame, err = answerNext(device)
+	if err != nil || !reflect.DeepEqual(frame.Data, want.Data) {
+		t.Fatalf("replayed setup frame = %+v err = %v", frame, err)
+	}
+	if e := nextState(t, s); e.State != ConnStateConnected || e.Err != nil {
+		t.Fatalf("event = %+v", e)
+	}
+	if s.RadioMode() != enums.RadioModeERP1 {
+		t.Fatalf("radio mode after reconnect = %s", s.RadioMode())
+	}
+
+	go func() {
+		_, _ = device.Write(esp3.NewTelegramFromData(enums.PacketTypeEVENT, []byte{byte(enums.EventCodeCO_TX_DONE)}, nil).Serialize())
+	}()
+	select {
+	case got, ok := <-s.Channels.Event:
+		if !ok || got.Description() != enums.EventCodeCO_TX_DONE {
+			t.Fatalf("event = %+v open = %v", got, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channels did not survive reconnect")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-s.State; ok {
+		t.Fatal("state channel open after Close")
+	}
+}
+
+// TestSessionSetupReportsReturnCode verifies SessionSetupReportsReturnCode behavior.
+func TestSessionSetupReportsReturnCode(t *testing.T) {
+	host, device := NewLoopback()
+	setup, _ := commoncommand.NewWrLearnMode(true, 0, 0)
+	errs := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 64)
+		if _, err := device.Read(buf); err != nil {
+			errs <- err
+			return
+		}
+		_, err := device.Write(esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeNOT_SUPPORTED)}, nil).Serialize())
+		errs <- err
+	}()
+
+	s := NewSession(context.Background(), host, SessionConfig{Setup: []Command{&setup}})
+	defer s.Close()
+
+	e := nextState(t, s)
+	var rcErr *commoncommand.ReturnCodeError
+	if e.State != ConnStateConnected || !errors.As(e.Err, &rcErr) || rcErr.Command != enums.CommonCommandWR_LEARNMODE {
+		t.Fatalf("event = %+v", e)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSessionWithoutDialerEndsOnTransportFailure verifies SessionWithoutDialerEndsOnTransportFailure behavior.
+func TestSessionWithoutDialerEndsOnTransportFailure(t *testing.T) {
+	host, device := NewLoopback()
+	s := NewSession(context.Background(), host)
+
+	if e := nextState(t, s); e.State != ConnStateConnected {
+		t.Fatalf("event = %+v", e)
+	}
+	_ = device.Close()
+	if e := nextState(t, s); e.State != ConnStateLost {
+		t.Fatalf("event = %+v", e)
+	}
+
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session did not stop")
+	}
+	if _, ok := <-s.Channels.All; ok {
+		t.Fatal("All channel open after session stopped")
+	}
+
+	cmd, _ := commoncommand.NewRdVersion()
+	if _, err := s.Do(context.Background(), &cmd); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// TestMergeSessionConfigs verifies MergeSessionConfigs behavior.
+func TestMergeSessionConfigs(t *testing.T) {
+	got := mergeSessionConfigs(nil)
+	if got.MinBackoff != defaultMinBackoff || got.MaxBackoff != defaultMaxBackoff {
+		t.Fatalf("defaults = %+v", got)
+	}
+
+	got = mergeSessionConfigs([]SessionConfig{{MinBackoff: time.Second, RadioMode: enums.RadioModeERP2}, {MaxBackoff: time.Minute}})
+	if got.MinBackoff != time.Second || got.MaxBackoff != time.Minute || got.RadioMode != enums.RadioModeERP2 {
+		t.Fatalf("merged = %+v", got)
+	}
+}
+
+// TestConnStateString verifies ConnStateString behavior.
+func TestConnStateString(t *testing.T) {
+	for state, want := range map[ConnState]string{ConnStateConnected: "CONNECTED", ConnStateLost: "LOST", ConnStateReconnecting: "RECONNECTING", ConnState(9): "UNKNOWN"} {
+		if got := state.String(); got != want {
+			t.Fatalf("%d.String() = %q, want %q", state, got, want)
+		}
+	}
+}
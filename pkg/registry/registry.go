@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+)
+
+type Device struct {
+	ID       deviceid.DeviceID
+	EEP      eep.EEP
+	Name     string
+	Location string
+	// Cycle is the interval at which the device sends at least one
+	// telegram, e.g. its heartbeat. Zero means unknown.
+	Cycle time.Duration
+}
+
+// Registry maps senders to the EEP they transmit. It is safe for concurrent
+// use, so devices can be added while a session is decoding with it.
+type Registry struct {
+	mu      sync.RWMutex
+	devices map[deviceid.DeviceID]Device
+}
+
+// New constructs a Registry holding devices.
+func New(devices ...Device) *Registry {
+	r := &Registry{devices: make(map[deviceid.DeviceID]Device, len(devices))}
+	for _, d := range devices {
+		r.devices[d.ID] = d
+	}
+	return r
+}
+
+// Add adds d, replacing any device with the same ID.
+func (r *Registry) Add(d Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices[d.ID] = d
+}
+
+// Remove removes id and reports whether it was registered.
+func (r *Registry) Remove(id deviceid.DeviceID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.devices[id]
+	delete(r.devices, id)
+	return ok
+}
+
+// Lookup returns the device registered under id.
+func (r *Registry) Lookup(id deviceid.DeviceID) (Device, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.devices[id]
+	return d, ok
+}
+
+// Profile returns the EEP registered for id.
+func (r *Registry) Profile(id deviceid.DeviceID) (eep.EEP, bool) {
+	d, ok := r.Lookup(id)
+	return d.EEP, ok
+}
+
+// Devices returns the registered devices ordered by ID.
+func (r *Registry) Devices() []Device {
+	r.mu.RLock()
+	devices := make([]Device, 0, len(r.devices))
+	for _, d := range r.devices {
+		devices = append(devices, d)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(devices, func(a, b Device) int { return cmp.Compare(a.ID, b.ID) })
+	return devices
+}
+
+// replace swaps the registry contents for devices.
+func (r *Registry) replace(devices []Device) {
+	loaded := make(map[deviceid.DeviceID]Device, len(devices))
+	for _, d := range devices {
+		loaded[d.ID] = d
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.devices = loaded
+}
+
+type file struct {
+	Devices []fileDevice `json:"devices"`
+}
+
+type fileDevice struct {
+	ID       string `json:"id"`
+	EEP      string `json:"eep"`
+	Name     string `json:"name,omitempty"`
+	Location string `json:"location,omitempty"`
+	Cycle    string `json:"cycle,omitempty"`
+}
+
+// toFile converts devices to their file representation.
+func toFile(devices []Device) file {
+	f := file{Devices: make([]fileDevice, 0, len(devices))}
+	for _, d := range devices {
+		fd := fileDevice{ID: d.ID.String(), EEP: d.EEP.String(), Name: d.Name, Location: d.Location}
+		if d.Cycle > 0 {
+			fd.Cycle = d.Cycle.String()
+		}
+		f.Devices = append(f.Devices, fd)
+	}
+	return f
+}
+
+// fromFile validates f and converts it to devices.
+func fromFile(f file) ([]Device, error) {
+	devices := make([]Device, 0, len(f.Devices))
+	for i, fd := range f.Devices {
+		id, err := deviceid.FromHexString(fd.ID)
+		if err != nil {
+			return nil, fmt.Errorf("device %d: invalid id %q: %w", i, fd.ID, err)
+		}
+		profile, err := eep.FromString(fd.EEP)
+		if err != nil {
+			return nil, fmt.Errorf("device %d: invalid eep %q: %w", i, fd.EEP, err)
+		}
+		var cycle time.Duration
+		if fd.Cycle != "" {
+			if cycle, err = time.ParseDuration(fd.Cycle); err != nil || cycle < 0 {
+				return nil, fmt.Errorf("device %d: invalid cycle %q", i, fd.Cycle)
+			}
+		}
+		devices = append(devices, Device{ID: id, EEP: profile, Name: fd.Name, Location: fd.Location, Cycle: cycle})
+	}
+	return devices, nil
+}
+
+// LoadJSON replaces the registry contents with the devices read from rd.
+func (r *Registry) LoadJSON(rd io.Reader) error {
+	var f file
+	if err := json.NewDecoder(rd).Decode(&f); err != nil {
+		return err
+	}
+	devices, err := fromFile(f)
+	if err != nil {
+		return err
+	}
+	r.replace(devices)
+	return nil
+}
+
+// SaveJSON writes the registered devices to w.
+func (r *Registry) SaveJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(toFile(r.Devices()))
+}
+
+// LoadYAML replaces the registry contents with the devices read from rd.
+func (r *Registry) LoadYAML(rd io.Reader) error {
+	f, err := decodeYAML(rd)
+	if err != nil {
+		return err
+	}
+	devices, err := fromFile(f)
+	if err != nil {
+		return err
+	}
+	r.replace(devices)
+	return nil
+}
+
+// SaveYAML writes the registered devices to w.
+func (r *Registry) SaveYAML(w io.Writer) error {
+	return encodeYAML(w, toFile(r.Devices()))
+}
+
+// Load reads a registry file, choosing JSON or YAML by its extension.
+func Load(path string) (*Registry, error) {
+	load, _, err := codec(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := New()
+	if err := load(r, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Save writes the registry to path, choosing JSON or YAML by its extension.
+func (r *Registry) Save(path string) error {
+	_, save, err := codec(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := save(r, f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// codec returns the load and save methods matching the extension of path.
+func codec(path string) (func(*Registry, io.Reader) error, func(*Registry, io.Writer) error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return (*Registry).LoadJSON, (*Registry).SaveJSON, nil
+	case ".yaml", ".yml":
+		return (*Registry).LoadYAML, (*Registry).SaveYAML, nil
+	default:
+		return nil, nil, errors.New("unsupported registry file extension: use .json, .yaml or .yml")
+	}
+}
@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+)
+
+// mustEEP parses an EEP or fails the test.
+func mustEEP(t *testing.T, s string) eep.EEP {
+	t.Helper()
+	profile, err := eep.FromString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return profile
+}
+
+// TestRegistryAddLookupRemove verifies RegistryAddLookupRemove behavior.
+func TestRegistryAddLookupRemove(t *testing.T) {
+	rocker := mustEEP(t, "F6-01-01")
+	r := New(Device{ID: 2, EEP: rocker})
+	r.Add(Device{ID: 1, EEP: rocker, Name: "Switch"})
+
+	if d, ok := r.Lookup(1); !ok || d.Name != "Switch" {
+		t.Fatalf("lookup = %+v, %t", d, ok)
+	}
+	if profile, ok := r.Profile(2); !ok || profile != rocker {
+		t.Fatalf("profile = %s, %t", profile, ok)
+	}
+	if got := r.Devices(); len(got) != 2 || got[0].ID != 1 || got[1].ID != 2 {
+		t.Fatalf("devices = %+v", got)
+	}
+	if !r.Remove(1) || r.Remove(1) {
+		t.Fatal("Remove did not report presence")
+	}
+	if _, ok := r.Lookup(1); ok {
+		t.Fatal("removed device still registered")
+	}
+}
+
+// TestRegistryJSONRoundTrip verifies RegistryJSONRoundTrip behavior.
+func TestRegistryJSONRoundTrip(t *testing.T) {
+	r := New(Device{ID: 0x0100000a, EEP: mustEEP(t, "A5-02-01"), Name: "Office", Location: "Floor 1"})
+	var buf bytes.Buffer
+	if err := r.SaveJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"id": "0100000a"`) || !strings.Contains(buf.String(), `"eep": "A5-02-01"`) {
+		t.Fatalf("json = %s", buf.String())
+	}
+
+	loaded := New(Device{ID: 9})
+	if err := loaded.LoadJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Devices(), r.Devices()) {
+		t.Fatalf("loaded %+v, want %+v", loaded.Devices(), r.Devices())
+	}
+}
+
+// TestRegistryYAMLRoundTrip verifies RegistryYAMLRoundTrip behavior.
+func TestRegistryYAMLRoundTrip(t *testing.T) {
+	r := New(
+		Device{ID: 0x01000001, EEP: mustEEP(t, "F6-01-01"), Name: `Say "hi"`},
+		Device{ID: 0x0100000a, EEP: mustEEP(t, "A5-02-01"), Location: "Kitchen: north", Cycle: 15 * time.Minute},
+	)
+	var buf bytes.Buffer
+	if err := r.SaveYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := New()
+	if err := loaded.LoadYAML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Devices(), r.Devices()) {
+		t.Fatalf("loaded %+v, want %+v", loaded.Devices(), r.Devices())
+	}
+}
+
+// TestLoadYAMLHandWritten verifies LoadYAMLHandWritten behavior.
+func TestLoadYAMLHandWritten(t *testing.T) {
+	doc := `# devices taught in on site
+devices:
+  - id: 0x0100000a  # rocker by the door
+    eep: F6-01-01
+    name: 'Door''s switch'
+  -
+    id: "0100000b"  # sensor
+    eep: A5-02-01
+    name: "Kitchen \"A\"" # main room
+    location: 'Hall' # ground floor
+    cycle: 20m
+`
+	r := New()
+	if err := r.LoadYAML(strings.NewReader(doc)); err != nil {
+		t.Fatal(err)
+	}
+	want := []Device{
+		{ID: 0x0100000a, EEP: mustEEP(t, "F6-01-01"), Name: "Door's switch"},
+		{ID: 0x0100000b, EEP: mustEEP(t, "A5-02-01"), Name: `Kitchen "A"`, Location: "Hall", Cycle: 20 * time.Minute},
+	}
+	if !reflect.DeepEqual(r.Devices(), want) {
+		t.Fatalf("devices = %+v", r.Devices())
+	}
+
+	if err := New().LoadYAML(strings.NewReader("devices: []\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadRejectsInvalidDevices verifies LoadRejectsInvalidDevices behavior.
+func TestLoadRejectsInvalidDevices(t *testing.T) {
+	tests := map[string]string{
+		"bad id":       `{"devices":[{"id":"xyz","eep":"F6-01-01"}]}`,
+		"bad eep":      `{"devices":[{"id":"01","eep":"F6-01"}]}`,
+		"bad document": `[`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := New(Device{ID: 1})
+			if err := r.LoadJSON(strings.NewReader(doc)); err == nil {
+				t.Fatal("expected error")
+			}
+			if _, ok := r.Lookup(1); !ok {
+				t.Fatal("failed load modified the registry")
+			}
+		})
+	}
+
+	for name, doc := range map[string]string{
+		"missing key":  "- id: 01\n",
+		"unknown key":  "devices:\n  - id: 01\n    color: red\n",
+		"no item":      "devices:\n  id: 01\n",
+		"yaml escape":  "devices:\n  - id: 01\n    eep: F6-01-01\n    name: \"a\\0\"\n",
+		"after quote":  "devices:\n  - id: 01\n    eep: F6-01-01\n    name: \"a\" b\n",
+		"unterminated": "devices:\n  - id: 01\n    eep: F6-01-01\n    name: 'a\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := New().LoadYAML(strings.NewReader(doc)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+// TestSaveLoadByExtension verifies SaveLoadByExtension behavior.
+func TestSaveLoadByExtension(t *testing.T) {
+	r := New(Device{ID: 0x0100000a, EEP: mustEEP(t, "F6-01-01"), Name: "Switch"})
+	dir := t.TempDir()
+
+	for _, name := range []string{"devices.json", "devices.yaml", "devices.yml"} {
+		path := filepath.Join(dir, name)
+		if err := r.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loaded.Devices(), r.Devices()) {
+			t.Fatalf("%s: loaded %+v", name, loaded.Devices())
+		}
+	}
+
+	if err := r.Save(filepath.Join(dir, "devices.txt")); err == nil {
+		t.Fatal("expected error for unsupported extension")
+	}
+}
+
+// TestTeachIn verifies TeachIn behavior.
+func TestTeachIn(t *testing.T) {
+	tests := []struct {
+		name   string
+		packet erp1.Packet
+		want   string
+		ok     bool
+	}{
+		{"4BS with EEP", erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x08, 0x28, 0x0b, 0x80}}, "A5-02-05", true},
+		{"4BS without EEP", erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x00, 0x00, 0x00, 0x00}}, "", false},
+		{"4BS data", erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x08, 0x28, 0x0b, 0x88}}, "", false},
+		{"1BS", erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x00}}, "D5-00-01", true},
+		{"1BS data", erp1.Packet{Rorg: enums.Rorg1BS, UserData: []byte{0x09}}, "", false},
+		{"UTE query", erp1.Packet{Rorg: enums.RorgUTE, UserData: []byte{0xa0, 0x01, 0x46, 0x00, 0x0a, 0x01, 0xd2}}, "D2-01-0A", true},
+		{"UTE response", erp1.Packet{Rorg: enums.RorgUTE, UserData: []byte{0x91, 0x01, 0x46, 0x00, 0x0a, 0x01, 0xd2}}, "", false},
+		{"RPS", erp1.Packet{Rorg: enums.RorgRPS, UserData: []byte{0x10}}, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := TeachIn(tc.packet)
+			if ok != tc.ok || (ok && got.String() != tc.want) {
+				t.Fatalf("TeachIn = %s, %t; want %s, %t", got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+// TestLearnKeepsMetadata verifies LearnKeepsMetadata behavior.
+func TestLearnKeepsMetadata(t *testing.T) {
+	r := New(Device{ID: 7, EEP: mustEEP(t, "A5-02-01"), Name: "Office"})
+
+	d, ok := r.Learn(erp1.Packet{Rorg: enums.Rorg4BS, SenderID: 7, UserData: []byte{0x08, 0x28, 0x0b, 0x80}})
+	if !ok || d.Name != "Office" || d.EEP.String() != "A5-02-05" {
+		t.Fatalf("learned %+v, %t", d, ok)
+	}
+	if _, ok := r.Learn(erp1.Packet{Rorg: enums.RorgRPS, SenderID: 8, UserData: []byte{0x10}}); ok {
+		t.Fatal("learned from a data telegram")
+	}
+	if _, ok := r.Lookup(8); ok {
+		t.Fatal("data telegram registered a device")
+	}
+}
@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+)
+
+const (
+	lrnBit          = 0x08 // DB_0.BIT_3, cleared in teach-in telegrams
+	lrnTypeWithEEP  = 0x80 // DB_0.BIT_7 of a 4BS teach-in
+	uteCommandMask  = 0x0f
+	uteTeachInQuery = 0x00
+	uteDataLen      = 7
+)
+
+// TeachIn returns the EEP announced by a teach-in telegram: a 1BS teach-in,
+// a 4BS teach-in carrying its EEP (variant 2) or a UTE teach-in query.
+func TeachIn(p erp1.Packet) (eep.EEP, bool) {
+	data := p.UserData
+
+	switch p.Rorg {
+	case enums.Rorg1BS:
+		if len(data) != 1 || data[0]&lrnBit != 0 {
+			return eep.EEP{}, false
+		}
+		return fromTriplet(enums.Rorg1BS, 0x00, 0x01)
+	case enums.Rorg4BS:
+		if len(data) != 4 || data[3]&lrnBit != 0 || data[3]&lrnTypeWithEEP == 0 {
+			return eep.EEP{}, false
+		}
+		fn := data[0] >> 2
+		typ := (data[0]&0x03)<<5 | data[1]>>3
+		return fromTriplet(enums.Rorg4BS, fn, typ)
+	case enums.RorgUTE:
+		if len(data) != uteDataLen || data[0]&uteCommandMask != uteTeachInQuery {
+			return eep.EEP{}, false
+		}
+		return fromTriplet(enums.Rorg(data[6]), data[5], data[4])
+	default:
+		return eep.EEP{}, false
+	}
+}
+
+// IsTeachIn reports whether p is a teach-in telegram rather than data,
+// whether or not it announces its EEP.
+func IsTeachIn(p erp1.Packet) bool {
+	switch p.Rorg {
+	case enums.Rorg1BS, enums.Rorg4BS:
+		return len(p.UserData) > 0 && p.UserData[len(p.UserData)-1]&lrnBit == 0
+	case enums.RorgUTE:
+		return true
+	default:
+		return false
+	}
+}
+
+// Learn registers the sender of a teach-in telegram under the EEP it
+// announces. Name and location of an already known sender are kept.
+func (r *Registry) Learn(p erp1.Packet) (Device, bool) {
+	profile, ok := TeachIn(p)
+	if !ok {
+		return Device{}, false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.devices[p.SenderID]
+	d.ID = p.SenderID
+	d.EEP = profile
+	r.devices[d.ID] = d
+	return d, true
+}
+
+// fromTriplet adapts eep.FromTriplet to a found flag.
+func fromTriplet(rorg enums.Rorg, fn, typ byte) (eep.EEP, bool) {
+	profile, err := eep.FromTriplet(rorg, fn, typ)
+	return profile, err == nil
+}
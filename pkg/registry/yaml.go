@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The registry YAML format is a fixed subset of YAML: a top-level "devices"
+// key holding a sequence of flat mappings with scalar values.
+//
+//	devices:
+//	  - id: "0100000a"
+//	    eep: "F6-01-01"
+//	    name: "Kitchen switch"
+
+// encodeYAML writes f in the registry YAML format.
+func encodeYAML(w io.Writer, f file) error {
+	bw := bufio.NewWriter(w)
+	if len(f.Devices) == 0 {
+		fmt.Fprintln(bw, "devices: []")
+		return bw.Flush()
+	}
+
+	fmt.Fprintln(bw, "devices:")
+	for _, d := range f.Devices {
+		fmt.Fprintf(bw, "  - id: %s\n", strconv.Quote(d.ID))
+		fmt.Fprintf(bw, "    eep: %s\n", strconv.Quote(d.EEP))
+		if d.Name != "" {
+			fmt.Fprintf(bw, "    name: %s\n", strconv.Quote(d.Name))
+		}
+		if d.Location != "" {
+			fmt.Fprintf(bw, "    location: %s\n", strconv.Quote(d.Location))
+		}
+		if d.Cycle != "" {
+			fmt.Fprintf(bw, "    cycle: %s\n", strconv.Quote(d.Cycle))
+		}
+	}
+	return bw.Flush()
+}
+
+// decodeYAML reads the registry YAML format.
+func decodeYAML(r io.Reader) (file, error) {
+	var f file
+	var current *fileDevice
+	seenDevices := false
+
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+
+		if !seenDevices {
+			switch trimmed {
+			case "devices:":
+				seenDevices = true
+			case "devices: []":
+				seenDevices = true
+				f.Devices = []fileDevice{}
+			default:
+				return file{}, fmt.Errorf("line %d: expected \"devices:\"", lineNo)
+			}
+			continue
+		}
+		if line == trimmed {
+			return file{}, fmt.Errorf("line %d: unexpected top-level key", lineNo)
+		}
+
+		if rest, ok := strings.CutPrefix(trimmed, "-"); ok {
+			f.Devices = append(f.Devices, fileDevice{})
+			current = &f.Devices[len(f.Devices)-1]
+			trimmed = strings.TrimSpace(rest)
+			if trimmed == "" {
+				continue
+			}
+		}
+		if current == nil {
+			return file{}, fmt.Errorf("line %d: expected a \"- \" sequence item", lineNo)
+		}
+
+		key, raw, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return file{}, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		value, err := yamlScalar(strings.TrimSpace(raw))
+		if err != nil {
+			return file{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		switch strings.TrimSpace(key) {
+		case "id":
+			current.ID = value
+		case "eep":
+			current.EEP = value
+		case "name":
+			current.Name = value
+		case "location":
+			current.Location = value
+		case "cycle":
+			current.Cycle = value
+		default:
+			return file{}, fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return file{}, err
+	}
+	if !seenDevices {
+		return file{}, fmt.Errorf("missing \"devices\" key")
+	}
+	return f, nil
+}
+
+// yamlScalar decodes a plain, single-quoted or double-quoted scalar, with an
+// optional trailing comment. Double-quoted scalars accept the escapes YAML
+// shares with Go (\a \b \f \n \r \t \v \" \\ \x \u \U), the ones
+// encodeYAML writes; other escapes are rejected.
+func yamlScalar(raw string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := closingQuote(raw, '"')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", raw)
+		}
+		if err := checkEscapes(raw[1:end]); err != nil {
+			return "", err
+		}
+		unquoted, err := strconv.Unquote(raw[:end+1])
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted value %s", raw[:end+1])
+		}
+		value, rest = unquoted, raw[end+1:]
+	case strings.HasPrefix(raw, "'"):
+		end := closingQuote(raw, '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quoted value %s", raw)
+		}
+		value, rest = strings.ReplaceAll(raw[1:end], "''", "'"), raw[end+1:]
+	default:
+		if value, _, found := strings.Cut(raw, " #"); found {
+			raw = strings.TrimSpace(value)
+		}
+		return raw, nil
+	}
+
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after quoted value", rest)
+	}
+	return value, nil
+}
+
+// closingQuote returns the index of the quote closing the scalar raw opens,
+// or -1. Double quotes are escaped with a backslash, single quotes doubled.
+func closingQuote(raw string, quote byte) int {
+	for i := 1; i < len(raw); i++ {
+		switch {
+		case quote == '"' && raw[i] == '\\':
+			i++
+		case raw[i] == quote && quote == '\'' && i+1 < len(raw) && raw[i+1] == '\'':
+			i++
+		case raw[i] == quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// checkEscapes rejects the escapes of a double-quoted scalar that YAML and
+// Go read differently.
+func checkEscapes(quoted string) error {
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] != '\\' {
+			continue
+		}
+		i++
+		if i == len(quoted) || !strings.ContainsRune(`abfnrtv"\xuU`, rune(quoted[i])) {
+			return fmt.Errorf("unsupported escape in %q", quoted)
+		}
+	}
+	return nil
+}
@@ -64,7 +64,7 @@ func (m Message) Packets() ([]erp1.Packet, error) {
 	}
 	out := make([]erp1.Packet, len(parts))
 	for i, part := range parts {
-		out[i] = erp1.Packet{Rorg: enums.RorgSYS_EX, UserData: append([]byte{m.Seq<<6 | byte(i)}, part...), SenderID: m.SourceID, DestinationID: m.DestinationID, Status: DefaultStatus, SubTelNum: 1, SecurityLevel: 3, Rssi: 0xff}
+		out[i] = erp1.Packet{Rorg: enums.RorgSYS_EX, UserData: append([]byte{m.Seq<<6 | byte(i)}, part...), SenderID: m.SourceID, DestinationID: m.DestinationID, Status: DefaultStatus, SubTelNum: 1, SecurityLevel: 3, Rssi: erp1.RssiUnknown}
 	}
 	return out, nil
 }
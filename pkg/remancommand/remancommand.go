@@ -0,0 +1,93 @@
+// Package remancommand parses and builds REMOTE_MAN_COMMAND telegrams, which
+// carry Remote Management messages between the host and the transceiver
+// without the SYS_EX chaining of the radio.
+package remancommand
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+type Packet struct {
+	Function       uint16
+	ManufacturerID uint16
+	Data           []byte
+	DestinationID  deviceid.DeviceID
+	SourceID       deviceid.DeviceID
+	Dbm            byte
+	SendWithDelay  bool
+}
+
+// NewPacketFromEsp3 parses a Remote Management command from an ESP3
+// telegram. Without optional data the message is addressed to everyone.
+func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
+	const minDataLen = 4  // 2 function + 2 manufacturer ID
+	const optDataLen = 10 // 4 destination ID + 4 source ID + 1 dBm + 1 send with delay
+	const functionOffset = 0
+	const manufacturerIDOffset = 2
+	const destinationIDOffset = 0
+	const sourceIDOffset = 4
+	const dbmOffset = 8
+	const sendWithDelayOffset = 9
+
+	if telegram.PacketType != enums.PacketTypeREMOTE_MAN_COMMAND {
+		return Packet{}, errors.New("invalid packet type")
+	}
+
+	if len(telegram.Data) < minDataLen {
+		return Packet{}, errors.New("data too short")
+	}
+
+	p := Packet{
+		Function:       binary.BigEndian.Uint16(telegram.Data[functionOffset:]),
+		ManufacturerID: binary.BigEndian.Uint16(telegram.Data[manufacturerIDOffset:]),
+		Data:           telegram.Data[minDataLen:],
+		DestinationID:  deviceid.BroadcastId(),
+	}
+
+	switch {
+	case len(telegram.OptData) == 0:
+		return p, nil
+	case len(telegram.OptData) < optDataLen:
+		return Packet{}, errors.New("optData too short")
+	}
+
+	p.DestinationID = deviceid.DeviceID(binary.BigEndian.Uint32(telegram.OptData[destinationIDOffset:]))
+	p.SourceID = deviceid.DeviceID(binary.BigEndian.Uint32(telegram.OptData[sourceIDOffset:]))
+	p.Dbm = telegram.OptData[dbmOffset]
+	p.SendWithDelay = telegram.OptData[sendWithDelayOffset] != 0
+	return p, nil
+}
+
+// ToEsp3 converts the packet to an ESP3 telegram.
+func (p Packet) ToEsp3() esp3.Telegram {
+	data := make([]byte, 0, 4+len(p.Data))
+	data = binary.BigEndian.AppendUint16(data, p.Function)
+	data = binary.BigEndian.AppendUint16(data, p.ManufacturerID)
+	data = append(data, p.Data...)
+
+	optData := make([]byte, 0, 10)
+	optData = binary.BigEndian.AppendUint32(optData, uint32(p.DestinationID))
+	optData = binary.BigEndian.AppendUint32(optData, uint32(p.SourceID))
+	optData = append(optData, p.Dbm)
+	if p.SendWithDelay {
+		optData = append(optData, 0x01)
+	} else {
+		optData = append(optData, 0x00)
+	}
+
+	return esp3.Telegram{
+		PacketType: enums.PacketTypeREMOTE_MAN_COMMAND,
+		Data:       data,
+		OptData:    optData,
+	}
+}
+
+// Serialize encodes Packet into its wire representation.
+func (p Packet) Serialize() []byte {
+	return p.ToEsp3().Serialize()
+}
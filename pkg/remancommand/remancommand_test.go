@@ -0,0 +1,64 @@
+package remancommand
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestNewPacketFromEsp3 verifies NewPacketFromEsp3 behavior.
+func TestNewPacketFromEsp3(t *testing.T) {
+	t.Run("parses data and optional data", func(t *testing.T) {
+		telegram := esp3.NewTelegramFromData(enums.PacketTypeREMOTE_MAN_COMMAND,
+			[]byte{0x00, 0x04, 0x07, 0xff, 0x01, 0x02},
+			[]byte{0xff, 0xff, 0xff, 0xff, 0x01, 0x02, 0x03, 0x04, 0x30, 0x01})
+
+		p, err := NewPacketFromEsp3(telegram)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Packet{Function: 0x0004, ManufacturerID: 0x07ff, Data: []byte{0x01, 0x02}, DestinationID: deviceid.BroadcastId(), SourceID: 0x01020304, Dbm: 0x30, SendWithDelay: true}
+		if !reflect.DeepEqual(p, want) {
+			t.Errorf("packet = %+v", p)
+		}
+	})
+
+	t.Run("addresses everyone without optional data", func(t *testing.T) {
+		p, err := NewPacketFromEsp3(esp3.NewTelegramFromData(enums.PacketTypeREMOTE_MAN_COMMAND, []byte{0x00, 0x04, 0x07, 0xff}, nil))
+		if err != nil || p.DestinationID != deviceid.BroadcastId() || len(p.Data) != 0 {
+			t.Errorf("packet = %+v, err = %v", p, err)
+		}
+	})
+
+	t.Run("rejects invalid telegrams", func(t *testing.T) {
+		for _, telegram := range []esp3.Telegram{
+			esp3.NewTelegramFromData(enums.PacketTypeRADIO_ERP1, []byte{0x00, 0x04, 0x07, 0xff}, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeREMOTE_MAN_COMMAND, []byte{0x00, 0x04, 0x07}, nil),
+			esp3.NewTelegramFromData(enums.PacketTypeREMOTE_MAN_COMMAND, []byte{0x00, 0x04, 0x07, 0xff}, []byte{0xff}),
+		} {
+			if _, err := NewPacketFromEsp3(telegram); err == nil {
+				t.Errorf("%+v accepted", telegram)
+			}
+		}
+	})
+}
+
+// TestToEsp3 verifies ToEsp3 behavior.
+func TestToEsp3(t *testing.T) {
+	p := Packet{Function: 0x0220, ManufacturerID: 0x07ff, Data: []byte{0xaa}, DestinationID: 0x01020304, SourceID: 0x0a0b0c0d, Dbm: 0xff}
+	telegram := p.ToEsp3()
+	if telegram.PacketType != enums.PacketTypeREMOTE_MAN_COMMAND || !reflect.DeepEqual(telegram.Data, []byte{0x02, 0x20, 0x07, 0xff, 0xaa}) {
+		t.Fatalf("telegram = %+v", telegram)
+	}
+	if want := []byte{0x01, 0x02, 0x03, 0x04, 0x0a, 0x0b, 0x0c, 0x0d, 0xff, 0x00}; !reflect.DeepEqual(telegram.OptData, want) {
+		t.Fatalf("optData = % x", telegram.OptData)
+	}
+
+	parsed, err := NewPacketFromEsp3(telegram)
+	if err != nil || !reflect.DeepEqual(parsed, p) {
+		t.Fatalf("round trip = %+v, err = %v", parsed, err)
+	}
+}
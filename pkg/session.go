@@ -0,0 +1,515 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/linkstats"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// responseTimeout is the ESP3 window in which the module answers a command.
+const responseTimeout = 500 * time.Millisecond
+
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+var (
+	ErrResponseTimeout = errors.New("response timeout")
+	ErrSessionClosed   = errors.New("session closed")
+	ErrNotConnected    = errors.New("not connected")
+)
+
+// Command is implemented by every commoncommand builder.
+type Command interface {
+	Serialize() (esp3.Telegram, error)
+}
+
+// Dialer opens a fresh transport to the transceiver.
+type Dialer func(ctx context.Context) (Transport, error)
+
+// SessionConfig tunes a session. Zero fields keep their defaults.
+type SessionConfig struct {
+	// Setup is replayed in order after every (re)connect, e.g. the ID base,
+	// filters, repeater mode and learn mode the transceiver must run with.
+	Setup []Command
+
+	// MinBackoff and MaxBackoff bound the exponential delay between
+	// reconnect attempts.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Streams sizes the consumer channels and picks their overflow policy.
+	Streams StreamConfig
+
+	// Dispatcher, if set, receives every parsed message before the channels.
+	Dispatcher *Dispatcher
+
+	// Devices, if set, decodes the telegrams of registered senders onto
+	// Channels.Decoded. It may be updated while the session runs.
+	Devices *registry.Registry
+
+	// Tx tunes the queue behind Transmit.
+	Tx TxConfig
+
+	// Logger receives parser and session diagnostics; defaults to
+	// discarding them.
+	Logger *slog.Logger
+
+	// Hooks observes the raw byte stream in both directions.
+	Hooks Hooks
+
+	// Stats, if set, records the reception statistics of every ERP1 sender.
+	Stats *linkstats.Collector
+
+	// DedupWindow, if set, suppresses copies of an ERP1 telegram received
+	// within the window after the first one: subtelegrams and repeated
+	// telegrams. The merged telegram is published on Channels.Deduplicated
+	// once the window elapses.
+	DedupWindow time.Duration
+
+	// RadioMode is the radio mode the transceiver starts in, e.g.
+	// RadioModeERP2 for one switched to ERP2 beforehand. The session assumes
+	// it after every (re)connect and CO_READY, until a CO_WR_MODE or a
+	// received radio telegram shows otherwise. Defaults to ERP1.
+	RadioMode enums.RadioMode
+}
+
+// mergeSessionConfigs combines configs; non-zero fields of later configs win.
+func mergeSessionConfigs(configs []SessionConfig) SessionConfig {
+	merged := SessionConfig{
+		MinBackoff: defaultMinBackoff,
+		MaxBackoff: defaultMaxBackoff,
+		Tx:         TxConfig{TxDoneTimeout: defaultTxDoneTimeout, DutyCycleRetry: defaultDutyCycleRetry},
+	}
+
+	for _, cfg := range configs {
+		if cfg.Setup != nil {
+			merged.Setup = cfg.Setup
+		}
+		if cfg.MinBackoff > 0 {
+			merged.MinBackoff = cfg.MinBackoff
+		}
+		if cfg.MaxBackoff > 0 {
+			merged.MaxBackoff = cfg.MaxBackoff
+		}
+		if !cfg.Streams.isZero() {
+			merged.Streams = cfg.Streams
+		}
+		if cfg.Dispatcher != nil {
+			merged.Dispatcher = cfg.Dispatcher
+		}
+		if cfg.Devices != nil {
+			merged.Devices = cfg.Devices
+		}
+		if cfg.Tx.TxDoneTimeout > 0 {
+			merged.Tx.TxDoneTimeout = cfg.Tx.TxDoneTimeout
+		}
+		if cfg.Tx.DutyCycleRetry > 0 {
+			merged.Tx.DutyCycleRetry = cfg.Tx.DutyCycleRetry
+		}
+		if cfg.Tx.DutyCycleCheck > 0 {
+			merged.Tx.DutyCycleCheck = cfg.Tx.DutyCycleCheck
+		}
+		if cfg.Logger != nil {
+			merged.Logger = cfg.Logger
+		}
+		if cfg.Hooks != nil {
+			merged.Hooks = cfg.Hooks
+		}
+		if cfg.Stats != nil {
+			merged.Stats = cfg.Stats
+		}
+		if cfg.DedupWindow > 0 {
+			merged.DedupWindow = cfg.DedupWindow
+		}
+		if cfg.RadioMode != enums.RadioModeERP1 {
+			merged.RadioMode = cfg.RadioMode
+		}
+	}
+
+	return merged
+}
+
+// Session owns an open transport, runs the ESP3 parser and correlates
+// each command with its RESPONSE. ESP3 allows a single outstanding command,
+// so concurrent callers are served one at a time.
+//
+// Sessions created with a Dialer survive transport failures: the transport is
+// re-opened with backoff, the setup commands are replayed and the consumer
+// channels stay open throughout.
+type Session struct {
+	Channels *Channels
+	State    <-chan ConnEvent
+
+	cfg     SessionConfig
+	dial    Dialer
+	set     *channelSet
+	state   chan ConnEvent
+	timeout time.Duration
+	cancel  context.CancelFunc
+	done    chan struct{}
+	slot    chan struct{}
+	writeMu sync.Mutex
+	tx      *txQueue
+
+	// erp2 is set while the transceiver runs in ERP2 mode.
+	erp2 atomic.Bool
+
+	mu        sync.Mutex
+	transport Transport
+	waiting   chan response.Packet
+}
+
+// OpenSession opens a serial port and starts a session on it. The port is
+// re-opened if it fails, e.g. when the stick is unplugged and re-enumerated.
+func OpenSession(ctx context.Context, portPath string, cfg ...SessionConfig) (*Session, error) {
+	return DialSession(ctx, SerialDialer(portPath), cfg...)
+}
+
+// SerialDialer returns a Dialer opening portPath.
+func SerialDialer(portPath string) Dialer {
+	return func(context.Context) (Transport, error) {
+		return OpenSerialTransport(portPath)
+	}
+}
+
+// DialSession dials a transport, starts a session on it and re-dials with
+// backoff whenever the transport fails. Only the first dial error is
+// returned; later ones show up as reconnect attempts on State.
+func DialSession(ctx context.Context, dial Dialer, cfg ...SessionConfig) (*Session, error) {
+	t, err := dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return startSession(ctx, t, dial, cfg), nil
+}
+
+// NewSession starts the parser on t and returns the session owning it. The
+// session ends when t fails.
+func NewSession(ctx context.Context, t Transport, cfg ...SessionConfig) *Session {
+	return startSession(ctx, t, nil, cfg)
+}
+
+// startSession constructs a session and starts supervising t.
+func startSession(ctx context.Context, t Transport, dial Dialer, cfg []SessionConfig) *Session {
+	ctx, cancel := context.WithCancel(ctx)
+	merged := mergeSessionConfigs(cfg)
+	set, channels := newChannelSetFromConfig(merged.Streams)
+	state := make(chan ConnEvent, 16)
+	s := &Session{
+		Channels: channels,
+		State:    state,
+		cfg:      merged,
+		dial:     dial,
+		set:      set,
+		state:    state,
+		timeout:  responseTimeout,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		slot:     make(chan struct{}, 1),
+		tx:       newTxQueue(),
+
+		transport: t,
+	}
+	set.onResponse = s.deliver
+	set.devices = merged.Devices
+	set.stats = merged.Stats
+	if merged.DedupWindow > 0 {
+		set.dedup = newDedupStage(merged.DedupWindow)
+	}
+	set.onEvent = s.onEvent
+	set.onRadioMode = s.setRadioMode
+	s.setRadioMode(merged.RadioMode)
+	set.observe(merged.Logger, merged.Hooks)
+	if merged.Dispatcher != nil {
+		set.onMessage = merged.Dispatcher.Dispatch
+	}
+
+	go s.supervise(ctx, t)
+	go s.runTx(ctx)
+
+	return s
+}
+
+// supervise runs the parser on t and, if the session has a dialer, replaces
+// t whenever it fails.
+func (s *Session) supervise(ctx context.Context, t Transport) {
+	defer close(s.done)
+	defer close(s.state)
+	defer s.set.close()
+
+	remanMessages := newReManAssembler(remanChainPeriod)
+	for {
+		readErr := make(chan error, 1)
+		go func() { readErr <- parse(ctx, t, s.set, remanMessages) }()
+
+		s.emit(ConnEvent{State: ConnStateConnected, Err: s.setup(ctx)})
+
+		err := <-readErr
+		s.setTransport(nil)
+		_ = t.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		s.emit(ConnEvent{State: ConnStateLost, Err: err})
+
+		if s.dial == nil {
+			return
+		}
+		if t = s.redial(ctx); t == nil {
+			return
+		}
+		s.setTransport(t)
+		s.setRadioMode(s.cfg.RadioMode)
+	}
+}
+
+// redial dials with exponential backoff until it succeeds or ctx is cancelled.
+func (s *Session) redial(ctx context.Context) Transport {
+	backoff := s.cfg.MinBackoff
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		t, err := s.dial(ctx)
+		if err == nil {
+			return t
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		s.emit(ConnEvent{State: ConnStateReconnecting, Attempt: attempt, Err: err})
+
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+	}
+}
+
+// setup replays the setup commands and returns the first failure.
+func (s *Session) setup(ctx context.Context) error {
+	var firstErr error
+	for _, cmd := range s.cfg.Setup {
+		telegram, err := cmd.Serialize()
+		if err == nil {
+			var p response.Packet
+			p, err = s.Send(ctx, telegram)
+			if err == nil && p.Code != enums.ReturnCodeSUCCESS {
+				err = &commoncommand.ReturnCodeError{Command: enums.CommonCommand(telegram.Data[0]), Code: p.Code}
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// emit logs and publishes a connection event without blocking the
+// supervisor.
+func (s *Session) emit(e ConnEvent) {
+	level := slog.LevelInfo
+	if e.Err != nil {
+		level = slog.LevelWarn
+	}
+	s.set.logger.Log(context.Background(), level, "transceiver link "+strings.ToLower(e.State.String()), "attempt", e.Attempt, "err", e.Err)
+
+	select {
+	case s.state <- e:
+	default:
+	}
+}
+
+// setTransport replaces the transport used for writes.
+func (s *Session) setTransport(t Transport) {
+	s.mu.Lock()
+	s.transport = t
+	s.mu.Unlock()
+}
+
+// Exec sends cmd through s and returns its RESPONSE parsed into the
+// command's typed result. Non-SUCCESS return codes are reported as
+// *commoncommand.ReturnCodeError, or *smartackcommand.ReturnCodeError for
+// Smart Ack commands.
+func Exec[R any](ctx context.Context, s *Session, cmd commoncommand.Command[R]) (R, error) {
+	p, err := s.Do(ctx, cmd)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return cmd.ParseResponse(p)
+}
+
+// Do serializes cmd, writes it and waits for the matching response.
+func (s *Session) Do(ctx context.Context, cmd Command) (response.Packet, error) {
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		return response.Packet{}, err
+	}
+
+	return s.Send(ctx, telegram)
+}
+
+// Send writes telegram and waits for the matching response.
+func (s *Session) Send(ctx context.Context, telegram esp3.Telegram) (response.Packet, error) {
+	select {
+	case s.slot <- struct{}{}:
+	case <-ctx.Done():
+		return response.Packet{}, ctx.Err()
+	case <-s.done:
+		return response.Packet{}, ErrSessionClosed
+	}
+	defer func() { <-s.slot }()
+
+	select {
+	case <-s.done:
+		return response.Packet{}, ErrSessionClosed
+	default:
+	}
+
+	wait := make(chan response.Packet, 1)
+	s.mu.Lock()
+	s.waiting = wait
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.waiting = nil
+		s.mu.Unlock()
+	}()
+
+	if err := s.write(telegram); err != nil {
+		return response.Packet{}, err
+	}
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+
+	select {
+	case p := <-wait:
+		s.trackRadioMode(telegram, p)
+		return p, nil
+	case <-timer.C:
+		return response.Packet{}, ErrResponseTimeout
+	case <-ctx.Done():
+		return response.Packet{}, ctx.Err()
+	case <-s.done:
+		return response.Packet{}, ErrSessionClosed
+	}
+}
+
+// RadioMode returns the radio mode of the transceiver: the one last set with
+// CO_WR_MODE or seen on a received radio telegram, else
+// SessionConfig.RadioMode. In ERP2 mode, Transmit sends RADIO_ERP2 telegrams.
+func (s *Session) RadioMode() enums.RadioMode {
+	if s.erp2.Load() {
+		return enums.RadioModeERP2
+	}
+	return enums.RadioModeERP1
+}
+
+// setRadioMode records the radio mode of the transceiver.
+func (s *Session) setRadioMode(mode enums.RadioMode) {
+	s.erp2.Store(mode == enums.RadioModeERP2)
+}
+
+// onEvent forwards transceiver events to the transmit queue and returns to
+// the configured radio mode after a reset.
+func (s *Session) onEvent(e event.Event) {
+	if _, ok := e.(event.COReady); ok {
+		s.setRadioMode(s.cfg.RadioMode)
+	}
+	s.tx.onEvent(e)
+}
+
+// trackRadioMode records the radio mode written by a successful CO_WR_MODE.
+func (s *Session) trackRadioMode(telegram esp3.Telegram, p response.Packet) {
+	const dataLen = 2 // 1 command code + 1 mode
+
+	if telegram.PacketType != enums.PacketTypeCOMMON_COMMAND || len(telegram.Data) < dataLen ||
+		enums.CommonCommand(telegram.Data[0]) != enums.CommonCommandWR_MODE || p.Code != enums.ReturnCodeSUCCESS {
+		return
+	}
+	s.setRadioMode(enums.RadioMode(telegram.Data[1]))
+}
+
+// Done returns a channel closed once the session has stopped.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close stops the session and closes the transport.
+func (s *Session) Close() error {
+	s.cancel()
+
+	s.mu.Lock()
+	t := s.transport
+	s.mu.Unlock()
+
+	var err error
+	if t != nil {
+		err = t.Close()
+	}
+	<-s.done
+	return err
+}
+
+// deliver hands a response to the command waiting for it, if any.
+func (s *Session) deliver(p response.Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting == nil {
+		return
+	}
+	s.waiting <- p
+	s.waiting = nil
+}
+
+// write writes a whole telegram to the transport.
+func (s *Session) write(telegram esp3.Telegram) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	t := s.transport
+	s.mu.Unlock()
+	if t == nil {
+		return ErrNotConnected
+	}
+
+	b := telegram.Serialize()
+	s.set.hooks.Written(b)
+	for len(b) > 0 {
+		n, err := t.Write(b)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		b = b[n:]
+	}
+
+	if f, ok := t.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
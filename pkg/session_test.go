@@ -0,0 +1,258 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/linkstats"
+	"go.bug.st/serial"
+)
+
+type scriptedPort struct {
+	fakePort
+	in      chan []byte
+	written chan []byte
+	closes  atomic.Int32
+}
+
+// newScriptedPort constructs ScriptedPort.
+func newScriptedPort() *scriptedPort {
+	return &scriptedPort{in: make(chan []byte, 8), written: make(chan []byte, 8)}
+}
+
+// Read reads the value.
+func (p *scriptedPort) Read(b []byte) (int, error) {
+	select {
+	case chunk := <-p.in:
+		return copy(b, chunk), nil
+	case <-time.After(time.Millisecond):
+		return 0, nil
+	}
+}
+
+// Write writes the value.
+func (p *scriptedPort) Write(b []byte) (int, error) {
+	p.written <- append([]byte(nil), b...)
+	return len(b), nil
+}
+
+// Close closes the port.
+func (p *scriptedPort) Close() error {
+	p.closes.Add(1)
+	return nil
+}
+
+// respond answers the next written frame with a response telegram.
+func (p *scriptedPort) respond(code enums.ReturnCode, data ...byte) []byte {
+	frame := <-p.written
+	p.in <- esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, append([]byte{byte(code)}, data...), nil).Serialize()
+	return frame
+}
+
+// TestSessionDoCorrelatesResponse verifies SessionDoCorrelatesResponse behavior.
+func TestSessionDoCorrelatesResponse(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	cmd, err := commoncommand.NewRdIDBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go port.respond(enums.ReturnCodeSUCCESS, 0xff, 0x80, 0x00, 0x00)
+
+	got, err := s.Do(context.Background(), &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Code != enums.ReturnCodeSUCCESS || !reflect.DeepEqual(got.Data, []byte{0xff, 0x80, 0x00, 0x00}) {
+		t.Fatalf("response = %+v", got)
+	}
+
+	select {
+	case published := <-s.Channels.Response:
+		if published.Code != enums.ReturnCodeSUCCESS {
+			t.Fatalf("published response = %+v", published)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("response was not published")
+	}
+}
+
+// TestSessionDoWritesSerializedCommand verifies SessionDoWritesSerializedCommand behavior.
+func TestSessionDoWritesSerializedCommand(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	cmd, _ := commoncommand.NewRdVersion()
+	want, _ := cmd.Serialize()
+	frames := make(chan []byte, 1)
+	go func() { frames <- port.respond(enums.ReturnCodeSUCCESS) }()
+
+	if _, err := s.Do(context.Background(), &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-frames; !reflect.DeepEqual(got, want.Serialize()) {
+		t.Fatalf("written = %x, want %x", got, want.Serialize())
+	}
+}
+
+// TestExecReturnsTypedResponse verifies ExecReturnsTypedResponse behavior.
+func TestExecReturnsTypedResponse(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	cmd, _ := commoncommand.NewRdRepeater()
+	go port.respond(enums.ReturnCodeSUCCESS, byte(enums.RepeaterModeON), byte(enums.RepeaterLevel2_REPETITION))
+
+	got, err := Exec(context.Background(), s, &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RepeaterMode != enums.RepeaterModeON || got.RepeaterLevel != enums.RepeaterLevel2_REPETITION {
+		t.Fatalf("response = %+v", got)
+	}
+}
+
+// TestExecReportsReturnCode verifies ExecReportsReturnCode behavior.
+func TestExecReportsReturnCode(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	cmd, _ := commoncommand.NewWrReset()
+	go port.respond(enums.ReturnCodeNOT_SUPPORTED)
+
+	_, err := Exec(context.Background(), s, &cmd)
+	var rcErr *commoncommand.ReturnCodeError
+	if !errors.As(err, &rcErr) || rcErr.Code != enums.ReturnCodeNOT_SUPPORTED || rcErr.Command != enums.CommonCommandWR_RESET {
+		t.Fatalf("err = %v", err)
+	}
+
+	s.timeout = 10 * time.Millisecond
+	if _, err := Exec(context.Background(), s, &cmd); !errors.Is(err, ErrResponseTimeout) {
+		t.Fatalf("err = %v, want ErrResponseTimeout", err)
+	}
+}
+
+// TestSessionDoTimesOut verifies SessionDoTimesOut behavior.
+func TestSessionDoTimesOut(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+	s.timeout = 10 * time.Millisecond
+
+	cmd, _ := commoncommand.NewRdVersion()
+	if _, err := s.Do(context.Background(), &cmd); !errors.Is(err, ErrResponseTimeout) {
+		t.Fatalf("err = %v, want ErrResponseTimeout", err)
+	}
+}
+
+// TestSessionAllowsOneOutstandingCommand verifies SessionAllowsOneOutstandingCommand behavior.
+func TestSessionAllowsOneOutstandingCommand(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	var completed atomic.Int32
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			cmd, _ := commoncommand.NewRdVersion()
+			_, err := s.Do(context.Background(), &cmd)
+			completed.Add(1)
+			errs <- err
+		}()
+	}
+
+	<-port.written
+	select {
+	case frame := <-port.written:
+		t.Fatalf("second command written before first response: %x", frame)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if got := completed.Load(); got != 0 {
+		t.Fatalf("%d commands completed without a response", got)
+	}
+
+	port.in <- esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS)}, nil).Serialize()
+	port.respond(enums.ReturnCodeERROR)
+	for range 2 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestSessionDoHonoursContext verifies SessionDoHonoursContext behavior.
+func TestSessionDoHonoursContext(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cmd, _ := commoncommand.NewRdVersion()
+	s.slot <- struct{}{}
+	if _, err := s.Do(ctx, &cmd); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	<-s.slot
+}
+
+// TestSessionDoAfterClose verifies SessionDoAfterClose behavior.
+func TestSessionDoAfterClose(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if port.closes.Load() == 0 {
+		t.Fatal("port was not closed")
+	}
+
+	cmd, _ := commoncommand.NewRdVersion()
+	if _, err := s.Do(context.Background(), &cmd); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("err = %v, want ErrSessionClosed", err)
+	}
+}
+
+// TestOpenSessionPropagatesOpenError verifies OpenSessionPropagatesOpenError behavior.
+func TestOpenSessionPropagatesOpenError(t *testing.T) {
+	wantErr := errors.New("no such port")
+	oldOpen := serialOpen
+	serialOpen = func(string, *serial.Mode) (serial.Port, error) { return nil, wantErr }
+	t.Cleanup(func() { serialOpen = oldOpen })
+
+	if s, err := OpenSession(context.Background(), "fake"); !errors.Is(err, wantErr) || s != nil {
+		t.Fatalf("session=%v err=%v", s, err)
+	}
+}
+
+// TestSessionRecordsLinkStats verifies SessionRecordsLinkStats behavior.
+func TestSessionRecordsLinkStats(t *testing.T) {
+	port := newScriptedPort()
+	stats := linkstats.New()
+	s := NewSession(context.Background(), port, SessionConfig{Stats: stats})
+	defer s.Close()
+
+	port.in <- rockerPacket(0x0100000a, true).ToEsp3().Serialize()
+	select {
+	case <-s.Channels.ERP1:
+	case <-time.After(time.Second):
+		t.Fatal("telegram was not parsed")
+	}
+
+	if l, ok := stats.Link(0x0100000a); !ok || l.Telegrams != 1 {
+		t.Fatalf("link = %+v, %t", l, ok)
+	}
+}
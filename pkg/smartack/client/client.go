@@ -0,0 +1,346 @@
+// Package client plays the sensor side of Smart Ack: it learns a client in
+// at a controller through a postmaster, then reclaims the telegrams queued
+// in its mailbox.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/smartack"
+)
+
+const (
+	defaultLearnDelay   = 500 * time.Millisecond
+	defaultReplyTimeout = 100 * time.Millisecond
+	defaultReclaims     = 3
+	answerBufferSize    = 4
+)
+
+var (
+	// ErrNoAnswer is returned when no postmaster answered any reclaim.
+	ErrNoAnswer = errors.New("no answer from postmaster")
+	// ErrNotLearned is returned by Reclaim before the client is learned in.
+	ErrNotLearned = errors.New("Smart Ack client not learned in")
+	// ErrMailboxEmpty is returned by Reclaim when the mailbox holds nothing.
+	ErrMailboxEmpty = errors.New("mailbox empty")
+	// ErrMailboxMissing is returned by Reclaim when the postmaster no longer
+	// has a mailbox for the client, which is then no longer learned in.
+	ErrMailboxMissing = errors.New("mailbox missing")
+	// ErrResetRequested is returned by Reclaim when the controller resets
+	// the client, which is then no longer learned in.
+	ErrResetRequested = errors.New("reset requested by controller")
+	// ErrUnknownAckCode is returned by Learn for acknowledge codes outside
+	// the learn-in and learn-out classes; the client state is unchanged.
+	ErrUnknownAckCode = errors.New("unknown learn acknowledge code")
+)
+
+// RefusedError reports a learn request the controller refused.
+type RefusedError struct {
+	Code smartack.AckCode
+}
+
+// Error returns the error message.
+func (e *RefusedError) Error() string {
+	return fmt.Sprintf("learn-in refused with code 0x%02x (%s)", byte(e.Code), e.Code.Class())
+}
+
+// Transmitter sends radio telegrams. *pkg.Session implements it.
+type Transmitter interface {
+	Transmit(ctx context.Context, p erp1.Packet, opts ...pkg.TxOptions) error
+}
+
+// Config tunes a Client. Zero fields keep their defaults.
+type Config struct {
+	// ManufacturerID is the 11-bit manufacturer ID sent with the learn
+	// request.
+	ManufacturerID uint16
+
+	// LearnDelay is how long Learn waits between the learn request and the
+	// first learn reclaim, unless a LearnReply for the client tells the
+	// response time of the controller. Defaults to 500ms.
+	LearnDelay time.Duration
+
+	// ReplyTimeout is how long the client listens after each reclaim.
+	// Defaults to 100ms.
+	ReplyTimeout time.Duration
+
+	// Reclaims is how often a reclaim is sent before giving up. Defaults
+	// to 3.
+	Reclaims int
+}
+
+// Client is a Smart Ack client. Feed it every received ERP1 packet with
+// Observe; Learn and Reclaim wait for the answers of the postmaster.
+type Client struct {
+	id             deviceid.DeviceID
+	profile        eep.EEP
+	tx             Transmitter
+	manufacturerID uint16
+	learnDelay     time.Duration
+	replyTimeout   time.Duration
+	reclaims       int
+	answers        chan erp1.Packet
+
+	// opMu serializes Learn and Reclaim.
+	opMu sync.Mutex
+
+	mu      sync.Mutex
+	learned bool
+	mailbox uint8
+}
+
+// New constructs a Client sending as id with the EEP profile through tx.
+func New(tx Transmitter, id deviceid.DeviceID, profile eep.EEP, cfg ...Config) *Client {
+	c := Config{LearnDelay: defaultLearnDelay, ReplyTimeout: defaultReplyTimeout, Reclaims: defaultReclaims}
+	for _, o := range cfg {
+		if o.ManufacturerID != 0 {
+			c.ManufacturerID = o.ManufacturerID
+		}
+		if o.LearnDelay > 0 {
+			c.LearnDelay = o.LearnDelay
+		}
+		if o.ReplyTimeout > 0 {
+			c.ReplyTimeout = o.ReplyTimeout
+		}
+		if o.Reclaims > 0 {
+			c.Reclaims = o.Reclaims
+		}
+	}
+
+	return &Client{
+		id:             id,
+		profile:        profile,
+		tx:             tx,
+		manufacturerID: c.ManufacturerID,
+		learnDelay:     c.LearnDelay,
+		replyTimeout:   c.ReplyTimeout,
+		reclaims:       c.Reclaims,
+		answers:        make(chan erp1.Packet, answerBufferSize),
+	}
+}
+
+// Observe passes p to the pending Learn or Reclaim if it may answer the
+// client: a Smart Ack answer, or a telegram addressed to the client. It
+// never blocks, so it can be registered as a Dispatcher handler.
+func (c *Client) Observe(p erp1.Packet) {
+	if p.SenderID == c.id {
+		return
+	}
+	switch p.Rorg {
+	case enums.RorgSM_LRN_ANS, enums.RorgSIGNAL:
+		if p.DestinationID != c.id && p.DestinationID != deviceid.BroadcastId() {
+			return
+		}
+	case enums.RorgSM_LRN_REQ, enums.RorgSM_REC:
+		return
+	default:
+		if p.DestinationID != c.id {
+			return
+		}
+	}
+
+	select {
+	case c.answers <- p:
+	default:
+	}
+}
+
+// Learned returns the mailbox of the client and whether it is learned in.
+func (c *Client) Learned() (uint8, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.mailbox, c.learned
+}
+
+// Learn sends a learn request, then learn reclaims until the postmaster
+// acknowledges. A first learn-in or a repeated one leaves the client learned
+// in at the acknowledged mailbox, a learn-out leaves it learned out, a
+// failed learn-in is returned as *RefusedError and any other code as
+// ErrUnknownAckCode.
+func (c *Client) Learn(ctx context.Context) (smartack.LearnAcknowledge, error) {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	c.drain()
+	req := smartack.LearnRequest{RequestCode: smartack.RequestDefaultSensor, ManufacturerID: c.manufacturerID, EEP: c.profile}
+	if err := c.tx.Transmit(ctx, req.ERP1(c.id)); err != nil {
+		return smartack.LearnAcknowledge{}, err
+	}
+	if err := c.awaitReply(ctx); err != nil {
+		return smartack.LearnAcknowledge{}, err
+	}
+
+	for range c.reclaims {
+		p, err := c.reclaim(ctx, smartack.LearnReclaim{}, isLearnAcknowledge)
+		if errors.Is(err, ErrNoAnswer) {
+			continue
+		}
+		if err != nil {
+			return smartack.LearnAcknowledge{}, err
+		}
+
+		ack := mustParse(p).(smartack.LearnAcknowledge)
+		switch {
+		case ack.AckCode <= 0x0f:
+			c.mu.Lock()
+			c.learned, c.mailbox = true, ack.MailboxIndex
+			c.mu.Unlock()
+		case ack.AckCode <= 0x1f:
+			return ack, &RefusedError{Code: ack.AckCode}
+		case ack.AckCode <= 0x2f:
+			c.forget()
+		default:
+			return ack, fmt.Errorf("%w 0x%02x", ErrUnknownAckCode, byte(ack.AckCode))
+		}
+		return ack, nil
+	}
+	return smartack.LearnAcknowledge{}, ErrNoAnswer
+}
+
+// Reclaim asks the postmaster for the next telegram in the mailbox of the
+// client. The signals of the postmaster are returned as ErrMailboxEmpty,
+// ErrMailboxMissing and ErrResetRequested.
+func (c *Client) Reclaim(ctx context.Context) (erp1.Packet, error) {
+	c.opMu.Lock()
+	defer c.opMu.Unlock()
+
+	mailbox, learned := c.Learned()
+	if !learned {
+		return erp1.Packet{}, ErrNotLearned
+	}
+
+	for range c.reclaims {
+		p, err := c.reclaim(ctx, smartack.DataReclaim{MailboxIndex: mailbox}, isMailboxAnswer)
+		if errors.Is(err, ErrNoAnswer) {
+			continue
+		}
+		if err != nil {
+			return erp1.Packet{}, err
+		}
+		if p.Rorg != enums.RorgSIGNAL {
+			return p, nil
+		}
+
+		switch mustParse(p).(smartack.Signal).Index {
+		case smartack.SignalMailboxEmpty:
+			return erp1.Packet{}, ErrMailboxEmpty
+		case smartack.SignalMailboxMissing:
+			c.forget()
+			return erp1.Packet{}, ErrMailboxMissing
+		default:
+			c.forget()
+			return erp1.Packet{}, ErrResetRequested
+		}
+	}
+	return erp1.Packet{}, ErrNoAnswer
+}
+
+// awaitReply waits for the LearnReply of the controller for the client and
+// then its response time, or LearnDelay if no reply comes.
+func (c *Client) awaitReply(ctx context.Context) error {
+	timer := time.NewTimer(c.learnDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case p := <-c.answers:
+			msg, err := smartack.Parse(p)
+			if reply, ok := msg.(smartack.LearnReply); err == nil && ok && reply.SensorID == c.id {
+				return sleep(ctx, time.Duration(reply.ResponseTime)*time.Millisecond)
+			}
+		}
+	}
+}
+
+// reclaim sends msg and returns the first answer accepted by match, or
+// ErrNoAnswer after ReplyTimeout.
+func (c *Client) reclaim(ctx context.Context, msg smartack.Message, match func(erp1.Packet) bool) (erp1.Packet, error) {
+	c.drain()
+	if err := c.tx.Transmit(ctx, msg.ERP1(c.id)); err != nil {
+		return erp1.Packet{}, err
+	}
+
+	timer := time.NewTimer(c.replyTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return erp1.Packet{}, ctx.Err()
+		case <-timer.C:
+			return erp1.Packet{}, ErrNoAnswer
+		case p := <-c.answers:
+			if match(p) {
+				return p, nil
+			}
+		}
+	}
+}
+
+// drain drops the answers left from earlier exchanges.
+func (c *Client) drain() {
+	for {
+		select {
+		case <-c.answers:
+		default:
+			return
+		}
+	}
+}
+
+// forget marks the client as no longer learned in.
+func (c *Client) forget() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.learned, c.mailbox = false, 0
+}
+
+// isLearnAcknowledge reports whether p is a LearnAcknowledge.
+func isLearnAcknowledge(p erp1.Packet) bool {
+	msg, err := smartack.Parse(p)
+	_, ok := msg.(smartack.LearnAcknowledge)
+	return err == nil && ok
+}
+
+// isMailboxAnswer reports whether p answers a data reclaim: a valid Signal
+// or a telegram from the mailbox.
+func isMailboxAnswer(p erp1.Packet) bool {
+	switch p.Rorg {
+	case enums.RorgSIGNAL:
+		_, err := smartack.Parse(p)
+		return err == nil
+	case enums.RorgSM_LRN_ANS:
+		return false
+	default:
+		return true
+	}
+}
+
+// mustParse parses a packet already accepted by a matcher.
+func mustParse(p erp1.Packet) smartack.Message {
+	msg, _ := smartack.Parse(p)
+	return msg
+}
+
+// sleep waits for d or until ctx is cancelled.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/smartack"
+)
+
+const (
+	clientID     deviceid.DeviceID = 0x01020304
+	postmasterID deviceid.DeviceID = 0x0a0b0c0d
+)
+
+var temperature = eep.EEP{Rorg: enums.Rorg4BS, Func: 0x02, Type: 0x05}
+
+// postmaster is a scripted postmaster answering the telegrams of a client.
+type postmaster struct {
+	client *Client
+
+	mu      sync.Mutex
+	sent    []smartack.Message
+	answers map[enums.Rorg][]erp1.Packet
+}
+
+// Transmit records the telegram and answers it with the next scripted
+// packet for its RORG.
+func (pm *postmaster) Transmit(_ context.Context, p erp1.Packet, _ ...pkg.TxOptions) error {
+	msg, err := smartack.Parse(p)
+	if err != nil {
+		return err
+	}
+
+	pm.mu.Lock()
+	pm.sent = append(pm.sent, msg)
+	var answer *erp1.Packet
+	if queue := pm.answers[p.Rorg]; len(queue) > 0 {
+		answer, pm.answers[p.Rorg] = &queue[0], queue[1:]
+	}
+	pm.mu.Unlock()
+
+	if answer != nil {
+		pm.client.Observe(*answer)
+	}
+	return nil
+}
+
+// newClient returns a client talking to a postmaster scripted with answers.
+func newClient(answers map[enums.Rorg][]erp1.Packet) (*Client, *postmaster) {
+	pm := &postmaster{answers: answers}
+	pm.client = New(pm, clientID, temperature, Config{ManufacturerID: 0x00b, LearnDelay: time.Second, ReplyTimeout: 10 * time.Millisecond})
+	return pm.client, pm
+}
+
+// TestClientLearnAndReclaim verifies ClientLearnAndReclaim behavior.
+func TestClientLearnAndReclaim(t *testing.T) {
+	data := erp1.Packet{Rorg: enums.Rorg4BS, UserData: []byte{0x00, 0x00, 0x80, 0x08}, SenderID: postmasterID, DestinationID: clientID}
+	c, pm := newClient(map[enums.Rorg][]erp1.Packet{
+		enums.RorgSM_LRN_REQ: {smartack.LearnReply{ResponseTime: 5, SensorID: clientID}.ERP1(postmasterID)},
+		enums.RorgSM_REC: {
+			{}, // lost: the learn reclaim is repeated
+			smartack.LearnAcknowledge{ResponseTime: 5, AckCode: 0x00, MailboxIndex: 3}.ERP1(postmasterID),
+			data,
+			smartack.Signal{Index: smartack.SignalMailboxEmpty}.ERP1(postmasterID),
+			smartack.Signal{Index: smartack.SignalMailboxMissing}.ERP1(postmasterID),
+		},
+	})
+	ctx := context.Background()
+
+	if _, err := c.Reclaim(ctx); !errors.Is(err, ErrNotLearned) {
+		t.Fatalf("err = %v", err)
+	}
+
+	start := time.Now()
+	ack, err := c.Learn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) >= time.Second {
+		t.Fatal("learn reply ignored")
+	}
+	if mailbox, ok := c.Learned(); !ok || mailbox != 3 || ack.MailboxIndex != 3 {
+		t.Fatalf("learned = %d, %v", mailbox, ok)
+	}
+	req, ok := pm.sent[0].(smartack.LearnRequest)
+	if !ok || req.EEP != temperature || req.ManufacturerID != 0x00b || req.RequestCode != smartack.RequestDefaultSensor {
+		t.Fatalf("learn request = %+v", pm.sent[0])
+	}
+
+	p, err := c.Reclaim(ctx)
+	if err != nil || p.Rorg != enums.Rorg4BS || p.SenderID != postmasterID {
+		t.Fatalf("reclaimed %+v, %v", p, err)
+	}
+	if last := pm.sent[len(pm.sent)-1]; last != (smartack.DataReclaim{MailboxIndex: 3}) {
+		t.Fatalf("reclaim = %+v", last)
+	}
+	if _, err := c.Reclaim(ctx); !errors.Is(err, ErrMailboxEmpty) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := c.Reclaim(ctx); !errors.Is(err, ErrMailboxMissing) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok := c.Learned(); ok {
+		t.Fatal("client still learned in after mailbox missing")
+	}
+}
+
+// TestClientLearnFailures verifies ClientLearnFailures behavior.
+func TestClientLearnFailures(t *testing.T) {
+	c, _ := newClient(map[enums.Rorg][]erp1.Packet{
+		enums.RorgSM_LRN_REQ: {smartack.LearnReply{SensorID: clientID}.ERP1(postmasterID)},
+		enums.RorgSM_REC:     {smartack.LearnAcknowledge{AckCode: 0x11}.ERP1(postmasterID)},
+	})
+	var refused *RefusedError
+	if _, err := c.Learn(context.Background()); !errors.As(err, &refused) || refused.Code != 0x11 {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok := c.Learned(); ok {
+		t.Fatal("refused client learned in")
+	}
+
+	c, _ = newClient(map[enums.Rorg][]erp1.Packet{
+		enums.RorgSM_LRN_REQ: {smartack.LearnReply{SensorID: clientID}.ERP1(postmasterID)},
+		enums.RorgSM_REC:     {smartack.LearnAcknowledge{AckCode: 0x40, MailboxIndex: 2}.ERP1(postmasterID)},
+	})
+	if _, err := c.Learn(context.Background()); !errors.Is(err, ErrUnknownAckCode) {
+		t.Fatalf("err = %v", err)
+	}
+	if _, ok := c.Learned(); ok {
+		t.Fatal("client learned in with an unknown code")
+	}
+
+	c, pm := newClient(map[enums.Rorg][]erp1.Packet{
+		enums.RorgSM_LRN_REQ: {smartack.LearnReply{SensorID: clientID}.ERP1(postmasterID)},
+	})
+	if _, err := c.Learn(context.Background()); !errors.Is(err, ErrNoAnswer) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(pm.sent) != 1+defaultReclaims {
+		t.Fatalf("sent %d telegrams", len(pm.sent))
+	}
+}
+
+// TestClientObserve verifies ClientObserve behavior.
+func TestClientObserve(t *testing.T) {
+	c := New(nil, clientID, temperature)
+	for _, p := range []erp1.Packet{
+		smartack.Signal{Index: smartack.SignalReset}.ERP1(postmasterID),
+		{Rorg: enums.Rorg4BS, UserData: make([]byte, 4), SenderID: postmasterID, DestinationID: clientID},
+		{Rorg: enums.Rorg4BS, UserData: make([]byte, 4), SenderID: postmasterID, DestinationID: deviceid.BroadcastId()},
+		smartack.LearnReclaim{}.ERP1(0x05060708),
+		smartack.Signal{Index: smartack.SignalReset}.ERP1(clientID),
+	} {
+		c.Observe(p)
+	}
+	if n := len(c.answers); n != 2 {
+		t.Fatalf("queued %d answers, want 2", n)
+	}
+}
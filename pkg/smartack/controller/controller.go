@@ -0,0 +1,428 @@
+// Package controller runs the learn flow of a Smart Ack controller: it opens
+// Smart Ack learn mode on the transceiver, answers the learn requests the
+// transceiver reports with SA_CONFIRM_LEARN and tracks the clients learned in
+// together with their postmaster and mailbox.
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+	"github.com/edlundin/enocean-esp3/pkg/smartackcommand"
+)
+
+const (
+	defaultResponseTime = 150 * time.Millisecond
+	defaultBufferSize   = 16
+)
+
+// manufacturerIDMask keeps the 11-bit manufacturer ID of a learn request.
+const manufacturerIDMask = 0x07ff
+
+var (
+	// ErrUnknownClient is returned by Unlearn for clients not learned in.
+	ErrUnknownClient = errors.New("unknown Smart Ack client")
+	// ErrNotLearned reports a client the transceiver did not add to its
+	// learned clients table after LRN_IN.
+	ErrNotLearned = errors.New("client missing from the Smart Ack learned clients table")
+)
+
+// Sender writes a telegram to the transceiver and waits for its RESPONSE.
+// *pkg.Session implements it.
+type Sender interface {
+	Send(ctx context.Context, telegram esp3.Telegram) (response.Packet, error)
+}
+
+// EventKind tells what happened to a client.
+type EventKind uint8
+
+const (
+	ClientLearned EventKind = iota
+	ClientUnlearned
+	ClientRejected
+	LearnFailed
+)
+
+// String returns the string representation of EventKind.
+func (kind EventKind) String() string {
+	switch kind {
+	case ClientLearned:
+		return "LEARNED"
+	case ClientUnlearned:
+		return "UNLEARNED"
+	case ClientRejected:
+		return "REJECTED"
+	case LearnFailed:
+		return "FAILED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Client is a Smart Ack client learned in by the controller.
+//
+// EEP and ManufacturerID are zero for clients only read from the transceiver
+// by Sync.
+type Client struct {
+	ID             deviceid.DeviceID
+	PostmasterID   deviceid.DeviceID
+	MailboxIndex   uint8
+	EEP            eep.EEP
+	ManufacturerID uint16
+}
+
+// Event reports the answer to a learn request, or a client learned out with
+// Unlearn.
+//
+// Code is the confirm code sent to the client. Err is set for LearnFailed,
+// when the transceiver did not take the answer or, after LRN_IN, its
+// learned clients table could not be read.
+type Event struct {
+	Kind   EventKind
+	Client Client
+	Code   enums.LearnAckConfirmCode
+	Err    error
+}
+
+// Decide answers a learn request with LRN_IN, or with the code of a refusal
+// such as EEP_NOT_ACCEPTED or RSSI_NOT_GOOD_ENOUGH.
+type Decide func(req event.SAConfirmLearn) enums.LearnAckConfirmCode
+
+// Config tunes a Controller. Zero fields keep their defaults.
+type Config struct {
+	// Allow, if set, accepts only clients with these EEPs; the others are
+	// refused with EEP_NOT_ACCEPTED.
+	Allow []eep.EEP
+
+	// Decide, if set, is asked about every learn request Allow accepts.
+	Decide Decide
+
+	// MaxClients, if set, refuses further clients with
+	// NO_PLACE_IN_CONTROLLER once that many are learned in.
+	MaxClients int
+
+	// ResponseTime is the time the controller needs to answer a client,
+	// sent along with LRN_IN. Defaults to 150ms.
+	ResponseTime time.Duration
+
+	// LearnMode is the extended learn mode opened by StartLearning.
+	// Defaults to SIMPLE.
+	LearnMode enums.SmartAckLearnMode
+
+	// BufferSize sizes Events. Defaults to 16.
+	BufferSize int
+}
+
+// Controller answers Smart Ack learn requests. Feed it every event of the
+// session with Observe and start it with Run.
+//
+// A learn request of a client already learned in learns it out, as the
+// Smart Ack learn button toggles.
+type Controller struct {
+	// Events receives the outcome of every learn request. It is closed when
+	// Run returns.
+	Events <-chan Event
+
+	events       chan Event
+	sender       Sender
+	allow        []eep.EEP
+	decide       Decide
+	maxClients   int
+	responseTime time.Duration
+	learnMode    enums.SmartAckLearnMode
+	wake         chan struct{}
+
+	mu       sync.Mutex
+	requests []event.SAConfirmLearn
+	pending  []Event
+	clients  map[deviceid.DeviceID]Client
+}
+
+// New constructs a Controller sending its commands through s.
+func New(s Sender, cfg ...Config) *Controller {
+	c := Config{ResponseTime: defaultResponseTime, BufferSize: defaultBufferSize}
+	for _, o := range cfg {
+		if o.Allow != nil {
+			c.Allow = o.Allow
+		}
+		if o.Decide != nil {
+			c.Decide = o.Decide
+		}
+		if o.MaxClients > 0 {
+			c.MaxClients = o.MaxClients
+		}
+		if o.ResponseTime > 0 {
+			c.ResponseTime = o.ResponseTime
+		}
+		if o.LearnMode != enums.SmartAckLearnModeSIMPLE {
+			c.LearnMode = o.LearnMode
+		}
+		if o.BufferSize > 0 {
+			c.BufferSize = o.BufferSize
+		}
+	}
+
+	events := make(chan Event, c.BufferSize)
+	return &Controller{
+		Events:       events,
+		events:       events,
+		sender:       s,
+		allow:        slices.Clone(c.Allow),
+		decide:       c.Decide,
+		maxClients:   c.MaxClients,
+		responseTime: c.ResponseTime,
+		learnMode:    c.LearnMode,
+		wake:         make(chan struct{}, 1),
+		clients:      map[deviceid.DeviceID]Client{},
+	}
+}
+
+// StartLearning opens Smart Ack learn mode for timeout; zero keeps the
+// transceiver default of 60 s.
+func (c *Controller) StartLearning(ctx context.Context, timeout time.Duration) error {
+	cmd, err := smartackcommand.NewWrLearnMode(true, c.learnMode, uint32(timeout.Milliseconds()))
+	if err != nil {
+		return err
+	}
+	_, err = exec(ctx, c.sender, &cmd)
+	return err
+}
+
+// StopLearning closes Smart Ack learn mode.
+func (c *Controller) StopLearning(ctx context.Context) error {
+	cmd, err := smartackcommand.NewWrLearnMode(false, c.learnMode, 0)
+	if err != nil {
+		return err
+	}
+	_, err = exec(ctx, c.sender, &cmd)
+	return err
+}
+
+// Observe queues the learn request of an SA_CONFIRM_LEARN event and ignores
+// the other events. It never blocks, so it can be registered as a Dispatcher
+// handler.
+func (c *Controller) Observe(e event.Event) {
+	req, ok := e.(event.SAConfirmLearn)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.mu.Unlock()
+	c.notify()
+}
+
+// Sync replaces the tracked clients with the learned clients table of the
+// transceiver, keeping what is known about clients learned in by c.
+func (c *Controller) Sync(ctx context.Context) error {
+	cmd, _ := smartackcommand.NewRdLearnedClients()
+	table, err := exec(ctx, c.sender, &cmd)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clients := make(map[deviceid.DeviceID]Client, len(table.Clients))
+	for _, entry := range table.Clients {
+		client := c.clients[entry.ClientID]
+		client.ID, client.PostmasterID, client.MailboxIndex = entry.ClientID, entry.PostmasterID, entry.MailboxIndex
+		clients[entry.ClientID] = client
+	}
+	c.clients = clients
+	return nil
+}
+
+// Clients returns the clients learned in, sorted by ID.
+func (c *Controller) Clients() []Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clients := slices.Collect(maps.Values(c.clients))
+	slices.SortFunc(clients, func(a, b Client) int { return cmp.Compare(a.ID, b.ID) })
+	return clients
+}
+
+// Unlearn learns id out and reports it with ClientUnlearned.
+func (c *Controller) Unlearn(ctx context.Context, id deviceid.DeviceID) error {
+	c.mu.Lock()
+	client, ok := c.clients[id]
+	c.mu.Unlock()
+	if !ok {
+		return ErrUnknownClient
+	}
+
+	if err := c.confirm(ctx, client, enums.LearnAckConfirmCodeLRN_OUT); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	delete(c.clients, id)
+	c.pending = append(c.pending, Event{Kind: ClientUnlearned, Client: client, Code: enums.LearnAckConfirmCodeLRN_OUT})
+	c.mu.Unlock()
+	c.notify()
+	return nil
+}
+
+// Run answers the queued learn requests until ctx is cancelled, then
+// closes Events.
+func (c *Controller) Run(ctx context.Context) {
+	defer close(c.events)
+	for {
+		for _, e := range c.process(ctx) {
+			select {
+			case c.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		}
+	}
+}
+
+// process answers the queued learn requests and returns the pending events.
+func (c *Controller) process(ctx context.Context) []Event {
+	c.mu.Lock()
+	requests := c.requests
+	c.requests = nil
+	c.mu.Unlock()
+
+	for _, req := range requests {
+		c.answer(ctx, req)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	events := c.pending
+	c.pending = nil
+	return events
+}
+
+// answer decides about req, sends the answer and records its outcome.
+func (c *Controller) answer(ctx context.Context, req event.SAConfirmLearn) {
+	client := Client{
+		ID:             deviceid.DeviceID(req.SmartACKClientID),
+		PostmasterID:   deviceid.DeviceID(req.PostmasterCandidateID),
+		EEP:            req.EEP,
+		ManufacturerID: req.ManufacturerID & manufacturerIDMask,
+	}
+
+	c.mu.Lock()
+	known, learned := c.clients[client.ID]
+	full := c.maxClients > 0 && len(c.clients) >= c.maxClients
+	c.mu.Unlock()
+
+	var code enums.LearnAckConfirmCode
+	switch {
+	case learned:
+		client, code = known, enums.LearnAckConfirmCodeLRN_OUT
+	case len(c.allow) > 0 && !slices.Contains(c.allow, client.EEP):
+		code = enums.LearnAckConfirmCodeEEP_NOT_ACCEPTED
+	case full:
+		code = enums.LearnAckConfirmCodeNO_PLACE_IN_CONTROLLER
+	case c.decide != nil:
+		code = c.decide(req)
+	default:
+		code = enums.LearnAckConfirmCodeLRN_IN
+	}
+
+	e := Event{Client: client, Code: code}
+	err := c.confirm(ctx, client, code)
+	if err == nil && code == enums.LearnAckConfirmCodeLRN_IN {
+		e.Client, err = c.learned(ctx, client)
+	}
+	if err != nil {
+		e.Kind, e.Err = LearnFailed, err
+	} else {
+		c.mu.Lock()
+		switch code {
+		case enums.LearnAckConfirmCodeLRN_IN:
+			c.clients[client.ID] = e.Client
+			e.Kind = ClientLearned
+		case enums.LearnAckConfirmCodeLRN_OUT:
+			delete(c.clients, client.ID)
+			e.Kind = ClientUnlearned
+		default:
+			e.Kind = ClientRejected
+		}
+		c.mu.Unlock()
+	}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, e)
+	c.mu.Unlock()
+}
+
+// confirm sends code to client through its postmaster.
+func (c *Controller) confirm(ctx context.Context, client Client, code enums.LearnAckConfirmCode) error {
+	var responseTime uint16
+	if code == enums.LearnAckConfirmCodeLRN_IN {
+		responseTime = uint16(min(c.responseTime.Milliseconds(), 0xffff))
+	}
+
+	cmd, err := smartackcommand.NewWrLearnConfirm(responseTime, code, client.PostmasterID, client.ID)
+	if err != nil {
+		return err
+	}
+	_, err = exec(ctx, c.sender, &cmd)
+	return err
+}
+
+// learned completes client with the postmaster and mailbox the transceiver
+// assigned it, read from its learned clients table.
+func (c *Controller) learned(ctx context.Context, client Client) (Client, error) {
+	cmd, _ := smartackcommand.NewRdLearnedClients()
+	table, err := exec(ctx, c.sender, &cmd)
+	if err != nil {
+		return client, err
+	}
+	for _, entry := range table.Clients {
+		if entry.ClientID == client.ID {
+			client.PostmasterID, client.MailboxIndex = entry.PostmasterID, entry.MailboxIndex
+			return client, nil
+		}
+	}
+	return client, ErrNotLearned
+}
+
+// notify wakes Run without blocking.
+func (c *Controller) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// exec sends cmd through s and parses its RESPONSE.
+func exec[R any](ctx context.Context, s Sender, cmd commoncommand.Command[R]) (R, error) {
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+
+	p, err := s.Send(ctx, telegram)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return cmd.ParseResponse(p)
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/emulator"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/smartackcommand"
+)
+
+const chipID = 0x01807ab3
+
+var (
+	temperature = eep.EEP{Rorg: enums.Rorg4BS, Func: 0x02, Type: 0x05}
+	occupancy   = eep.EEP{Rorg: enums.Rorg4BS, Func: 0x07, Type: 0x01}
+)
+
+// newController runs a controller on a session with an emulated transceiver.
+func newController(t *testing.T, cfg Config) (*emulator.Emulator, *pkg.Session, *Controller) {
+	t.Helper()
+	e := emulator.New()
+	d := pkg.NewDispatcher()
+	s := pkg.NewSession(context.Background(), e.Host(), pkg.SessionConfig{Dispatcher: d})
+	c := New(s, cfg)
+	d.On(pkg.MessageKindEvent, func(msg pkg.Message) { c.Observe(msg.Data.(event.Event)) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		s.Close()
+		e.Close()
+	})
+	return e, s, c
+}
+
+// nextEvent returns the next event or fails the test.
+func nextEvent(t *testing.T, c *Controller) Event {
+	t.Helper()
+	select {
+	case e := <-c.Events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+// request sends a learn request of id through the emulator.
+func request(t *testing.T, e *emulator.Emulator, id deviceid.DeviceID, profile eep.EEP) {
+	t.Helper()
+	if ok, err := e.SmartAckLearnRequest(id, 0x00b, profile, 0x40); !ok || err != nil {
+		t.Fatalf("learn request of %s not sent: %v", id, err)
+	}
+}
+
+// TestControllerLearnFlow verifies ControllerLearnFlow behavior.
+func TestControllerLearnFlow(t *testing.T) {
+	e, _, c := newController(t, Config{LearnMode: enums.SmartAckLearnModeADVANCED})
+	ctx := context.Background()
+
+	if err := c.StartLearning(ctx, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if !e.SmartAckLearning() {
+		t.Fatal("learn mode not opened")
+	}
+
+	request(t, e, 0x01020304, temperature)
+	learned := nextEvent(t, c)
+	want := Client{ID: 0x01020304, PostmasterID: chipID, EEP: temperature, ManufacturerID: 0x00b}
+	if learned.Kind != ClientLearned || learned.Code != enums.LearnAckConfirmCodeLRN_IN || learned.Client != want {
+		t.Fatalf("event = %+v", learned)
+	}
+
+	request(t, e, 0x01020305, occupancy)
+	if second := nextEvent(t, c); second.Kind != ClientLearned || second.Client.MailboxIndex != 1 {
+		t.Fatalf("event = %+v", second)
+	}
+	if got := e.SmartAckClients(); !reflect.DeepEqual(got, []deviceid.DeviceID{0x01020304, 0x01020305}) {
+		t.Fatalf("emulator clients = %v", got)
+	}
+
+	request(t, e, 0x01020304, temperature)
+	if again := nextEvent(t, c); again.Kind != ClientUnlearned || again.Code != enums.LearnAckConfirmCodeLRN_OUT || again.Client.ID != 0x01020304 {
+		t.Fatalf("event = %+v", again)
+	}
+
+	if err := c.Unlearn(ctx, 0x01020305); err != nil {
+		t.Fatal(err)
+	}
+	if out := nextEvent(t, c); out.Kind != ClientUnlearned || out.Client.EEP != occupancy {
+		t.Fatalf("event = %+v", out)
+	}
+	if err := c.Unlearn(ctx, 0x01020305); !errors.Is(err, ErrUnknownClient) {
+		t.Fatalf("err = %v", err)
+	}
+	if len(c.Clients()) != 0 || len(e.SmartAckClients()) != 0 {
+		t.Fatalf("clients left: %v, %v", c.Clients(), e.SmartAckClients())
+	}
+
+	if err := c.StopLearning(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if e.SmartAckLearning() {
+		t.Fatal("learn mode not closed")
+	}
+}
+
+// TestControllerRejects verifies ControllerRejects behavior.
+func TestControllerRejects(t *testing.T) {
+	e, _, c := newController(t, Config{
+		Allow:      []eep.EEP{temperature, occupancy},
+		MaxClients: 1,
+		Decide: func(req event.SAConfirmLearn) enums.LearnAckConfirmCode {
+			if req.Rssi > 0x50 {
+				return enums.LearnAckConfirmCodeRSSI_NOT_GOOD_ENOUGH
+			}
+			return enums.LearnAckConfirmCodeLRN_IN
+		},
+	})
+	if err := c.StartLearning(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.SmartAckLearnRequest(0x01020303, 0x00b, temperature, 0x60); err != nil {
+		t.Fatal(err)
+	}
+	request(t, e, 0x01020304, eep.EEP{Rorg: enums.RorgVLD, Func: 0x01, Type: 0x01})
+	request(t, e, 0x01020305, temperature)
+	request(t, e, 0x01020306, occupancy)
+
+	for _, want := range []struct {
+		kind EventKind
+		id   deviceid.DeviceID
+		code enums.LearnAckConfirmCode
+	}{
+		{ClientRejected, 0x01020303, enums.LearnAckConfirmCodeRSSI_NOT_GOOD_ENOUGH},
+		{ClientRejected, 0x01020304, enums.LearnAckConfirmCodeEEP_NOT_ACCEPTED},
+		{ClientLearned, 0x01020305, enums.LearnAckConfirmCodeLRN_IN},
+		{ClientRejected, 0x01020306, enums.LearnAckConfirmCodeNO_PLACE_IN_CONTROLLER},
+	} {
+		if got := nextEvent(t, c); got.Kind != want.kind || got.Client.ID != want.id || got.Code != want.code {
+			t.Errorf("event = %+v, want %s %s %s", got, want.kind, want.id, want.code)
+		}
+	}
+	if got := e.SmartAckClients(); !reflect.DeepEqual(got, []deviceid.DeviceID{0x01020305}) {
+		t.Fatalf("emulator clients = %v", got)
+	}
+}
+
+// TestControllerSync verifies ControllerSync behavior.
+func TestControllerSync(t *testing.T) {
+	e, s, c := newController(t, Config{})
+	ctx := context.Background()
+	if err := c.StartLearning(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	request(t, e, 0x01020304, temperature)
+	nextEvent(t, c)
+
+	confirm, _ := smartackcommand.NewWrLearnConfirm(0, enums.LearnAckConfirmCodeLRN_IN, 0x0a0b0c0d, 0x01020305)
+	if _, err := pkg.Exec(ctx, s, &confirm); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := []Client{
+		{ID: 0x01020304, PostmasterID: chipID, EEP: temperature, ManufacturerID: 0x00b},
+		{ID: 0x01020305, PostmasterID: 0x0a0b0c0d},
+	}
+	if got := c.Clients(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("clients = %+v", got)
+	}
+}
+
+// TestControllerMailboxFromTransceiver verifies ControllerMailboxFromTransceiver behavior.
+func TestControllerMailboxFromTransceiver(t *testing.T) {
+	e, s, c := newController(t, Config{})
+	ctx := context.Background()
+	if err := c.StartLearning(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A client the controller never saw takes the first mailbox.
+	confirm, _ := smartackcommand.NewWrLearnConfirm(0, enums.LearnAckConfirmCodeLRN_IN, chipID, 0x01020303)
+	if _, err := pkg.Exec(ctx, s, &confirm); err != nil {
+		t.Fatal(err)
+	}
+	request(t, e, 0x01020304, temperature)
+	want := Client{ID: 0x01020304, PostmasterID: chipID, MailboxIndex: 1, EEP: temperature, ManufacturerID: 0x00b}
+	if learned := nextEvent(t, c); learned.Kind != ClientLearned || learned.Client != want {
+		t.Fatalf("event = %+v", learned)
+	}
+}
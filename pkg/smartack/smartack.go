@@ -195,5 +195,5 @@ func (m Signal) ERP1(sender deviceid.DeviceID) erp1.Packet {
 
 // packet constructs an ERP1 packet.
 func packet(r enums.Rorg, data []byte, sender deviceid.DeviceID, subTel byte) erp1.Packet {
-	return erp1.Packet{Rorg: r, UserData: data, SenderID: sender, SubTelNum: subTel, SecurityLevel: 3, Rssi: 0xff, DestinationID: deviceid.BroadcastId()}
+	return erp1.Packet{Rorg: r, UserData: data, SenderID: sender, SubTelNum: subTel, SecurityLevel: 3, Rssi: erp1.RssiUnknown, DestinationID: deviceid.BroadcastId()}
 }
@@ -0,0 +1,70 @@
+package smartackcommand
+
+import (
+	"errors"
+
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// manufacturerIDPrefix fills the five bits above the 11-bit manufacturer ID
+// of a client learn request.
+const manufacturerIDPrefix = 0xf800
+
+// WrClientLearnRequest makes a Smart Ack client send a learn request.
+type WrClientLearnRequest struct {
+	CommandCode    enums.SmartAckCommand `enocean-esp3:"data"`
+	ManufacturerID uint16                `enocean-esp3:"data"`
+	EEP            eep.EEP               `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrClientLearnRequest into its wire representation.
+func (cmd *WrClientLearnRequest) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrClientLearnRequest.
+func (cmd *WrClientLearnRequest) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrClientLearnRequest constructs WrClientLearnRequest for the 11-bit
+// manufacturerID and the EEP of the client.
+func NewWrClientLearnRequest(manufacturerID uint16, profile eep.EEP) (WrClientLearnRequest, error) {
+	if manufacturerID > 0x07ff {
+		return WrClientLearnRequest{}, errors.New("manufacturer ID must be between 0 and 0x7ff")
+	}
+
+	return WrClientLearnRequest{
+		CommandCode:    enums.SmartAckCommandWR_CLIENT_LEARN_RQ,
+		ManufacturerID: manufacturerIDPrefix | manufacturerID,
+		EEP:            profile,
+	}, nil
+}
+
+// WrReclaims sets how often a Smart Ack client reclaims its mailbox before
+// giving up.
+type WrReclaims struct {
+	CommandCode enums.SmartAckCommand `enocean-esp3:"data"`
+	Count       uint8                 `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrReclaims into its wire representation.
+func (cmd *WrReclaims) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrReclaims.
+func (cmd *WrReclaims) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrReclaims constructs WrReclaims.
+func NewWrReclaims(count uint8) (WrReclaims, error) {
+	return WrReclaims{
+		CommandCode: enums.SmartAckCommandWR_RECLAIMS,
+		Count:       count,
+	}, nil
+}
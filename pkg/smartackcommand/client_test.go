@@ -0,0 +1,48 @@
+package smartackcommand
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// TestWrClientLearnRequest_Serialize verifies WrClientLearnRequest_Serialize behavior.
+func TestWrClientLearnRequest_Serialize(t *testing.T) {
+	profile, _ := eep.FromString("A5-02-05")
+	cmd, err := NewWrClientLearnRequest(0x00b, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x04, 0xf8, 0x0b, 0xa5, 0x02, 0x05}; !bytes.Equal(telegram.Data, want) {
+		t.Errorf("data = % x, want % x", telegram.Data, want)
+	}
+
+	if _, err := NewWrClientLearnRequest(0x800, profile); err == nil {
+		t.Error("manufacturer ID beyond 11 bits accepted")
+	}
+}
+
+// TestWrReclaims_Serialize verifies WrReclaims_Serialize behavior.
+func TestWrReclaims_Serialize(t *testing.T) {
+	cmd, _ := NewWrReclaims(5)
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if telegram.PacketType != enums.PacketTypeSMART_ACK_COMMAND || !bytes.Equal(telegram.Data, []byte{0x07, 0x05}) {
+		t.Errorf("telegram = %+v", telegram)
+	}
+
+	var codeErr *ReturnCodeError
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeWRONG_ARGUMENT}); !errors.As(err, &codeErr) || codeErr.Command != enums.SmartAckCommandWR_RECLAIMS {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+// Package smartackcommand builds the ESP3 SMART_ACK_COMMAND telegrams that
+// configure the Smart Ack controller, postmaster and client roles of a
+// transceiver, and parses their RESPONSEs.
+package smartackcommand
+
+import (
+	"fmt"
+
+	"github.com/edlundin/enocean-esp3/internal/serializer"
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// Ack is the result of commands whose RESPONSE only carries a return code.
+type Ack = commoncommand.Ack
+
+// ReturnCodeError reports a RESPONSE whose return code is not SUCCESS.
+type ReturnCodeError struct {
+	Command enums.SmartAckCommand
+	Code    enums.ReturnCode
+}
+
+// Error returns the error message.
+func (e *ReturnCodeError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.Command, e.Code)
+}
+
+// serialize encodes cmd as a SMART_ACK_COMMAND telegram.
+func serialize(cmd any) (esp3.Telegram, error) {
+	return serializer.CommandToTelegram(cmd, serializer.SerializerConfig{PacketType: enums.PacketTypeSMART_ACK_COMMAND})
+}
+
+// ack checks that p reports SUCCESS for command.
+func ack(command enums.SmartAckCommand, p response.Packet) (Ack, error) {
+	if p.Code != enums.ReturnCodeSUCCESS {
+		return Ack{}, &ReturnCodeError{Command: command, Code: p.Code}
+	}
+	return Ack{}, nil
+}
+
+// parseOK checks the return code of p before handing it to parse.
+func parseOK[R any](command enums.SmartAckCommand, p response.Packet, parse func(response.Packet) (R, error)) (R, error) {
+	if _, err := ack(command, p); err != nil {
+		var zero R
+		return zero, err
+	}
+	return parse(p)
+}
@@ -0,0 +1,207 @@
+package smartackcommand
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/edlundin/enocean-esp3/internal/serializer"
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// WrLearnMode starts or stops the learn mode of a Smart Ack controller.
+type WrLearnMode struct {
+	CommandCode enums.SmartAckCommand   `enocean-esp3:"data"`
+	Enable      bool                    `enocean-esp3:"data"`
+	Extended    enums.SmartAckLearnMode `enocean-esp3:"data"`
+	Timeout     uint32                  `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrLearnMode into its wire representation.
+func (cmd *WrLearnMode) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrLearnMode.
+func (cmd *WrLearnMode) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrLearnMode constructs WrLearnMode. timeout is in milliseconds; zero
+// keeps the default of 60 s.
+func NewWrLearnMode(enable bool, extended enums.SmartAckLearnMode, timeout uint32) (WrLearnMode, error) {
+	if !extended.Valid() {
+		return WrLearnMode{}, errors.New("invalid learn mode")
+	}
+
+	return WrLearnMode{
+		CommandCode: enums.SmartAckCommandWR_LEARN_MODE,
+		Enable:      enable,
+		Extended:    extended,
+		Timeout:     timeout,
+	}, nil
+}
+
+// RdLearnMode reads whether the learn mode of a Smart Ack controller is active.
+type RdLearnMode struct {
+	CommandCode enums.SmartAckCommand `enocean-esp3:"data"`
+}
+
+// Serialize encodes RdLearnMode into its wire representation.
+func (cmd *RdLearnMode) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse parses the RESPONSE to RdLearnMode.
+func (cmd *RdLearnMode) ParseResponse(p response.Packet) (RdLearnModeResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdLearnModeResponseOK)
+}
+
+// NewRdLearnMode constructs RdLearnMode.
+func NewRdLearnMode() (RdLearnMode, error) {
+	return RdLearnMode{
+		CommandCode: enums.SmartAckCommandRD_LEARN_MODE,
+	}, nil
+}
+
+// RdLearnModeResponse is the learn mode state reported by the controller.
+type RdLearnModeResponse struct {
+	Enabled  bool
+	Extended enums.SmartAckLearnMode
+}
+
+// ParseRdLearnModeResponseOK parses RdLearnModeResponseOK.
+func ParseRdLearnModeResponseOK(response response.Packet) (RdLearnModeResponse, error) {
+	if response.Code != enums.ReturnCodeSUCCESS {
+		return RdLearnModeResponse{}, errors.New("invalid return code")
+	}
+
+	var result RdLearnModeResponse
+	if err := serializer.BytesToStruct(response.Data, &result); err != nil {
+		return RdLearnModeResponse{}, errors.New("failed to deserialize response")
+	}
+
+	if !result.Extended.Valid() {
+		return RdLearnModeResponse{}, errors.New("invalid learn mode")
+	}
+
+	return result, nil
+}
+
+// WrLearnConfirm answers the learn request of a Smart Ack client, learning
+// it in or out through the given postmaster.
+type WrLearnConfirm struct {
+	CommandCode           enums.SmartAckCommand     `enocean-esp3:"data"`
+	ResponseTime          uint16                    `enocean-esp3:"data"`
+	ConfirmCode           enums.LearnAckConfirmCode `enocean-esp3:"data"`
+	PostmasterCandidateID deviceid.DeviceID         `enocean-esp3:"data"`
+	ClientID              deviceid.DeviceID         `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrLearnConfirm into its wire representation.
+func (cmd *WrLearnConfirm) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrLearnConfirm.
+func (cmd *WrLearnConfirm) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrLearnConfirm constructs WrLearnConfirm. responseTime is in
+// milliseconds; confirmCode is LRN_IN, LRN_OUT or the reason of a refusal.
+func NewWrLearnConfirm(responseTime uint16, confirmCode enums.LearnAckConfirmCode, postmasterCandidateID deviceid.DeviceID, clientID deviceid.DeviceID) (WrLearnConfirm, error) {
+	if !confirmCode.Valid() {
+		return WrLearnConfirm{}, errors.New("invalid confirm code")
+	}
+
+	return WrLearnConfirm{
+		CommandCode:           enums.SmartAckCommandWR_LEARN_CONFIRM,
+		ResponseTime:          responseTime,
+		ConfirmCode:           confirmCode,
+		PostmasterCandidateID: postmasterCandidateID,
+		ClientID:              clientID,
+	}, nil
+}
+
+// WrReset sends a reset to a learned-in Smart Ack client.
+type WrReset struct {
+	CommandCode enums.SmartAckCommand `enocean-esp3:"data"`
+	ClientID    deviceid.DeviceID     `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrReset into its wire representation.
+func (cmd *WrReset) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrReset.
+func (cmd *WrReset) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrReset constructs WrReset.
+func NewWrReset(clientID deviceid.DeviceID) (WrReset, error) {
+	return WrReset{
+		CommandCode: enums.SmartAckCommandWR_RESET,
+		ClientID:    clientID,
+	}, nil
+}
+
+// RdLearnedClients reads the clients learned in by a controller or
+// postmaster, with the mailbox each is assigned.
+type RdLearnedClients struct {
+	CommandCode enums.SmartAckCommand `enocean-esp3:"data"`
+}
+
+// Serialize encodes RdLearnedClients into its wire representation.
+func (cmd *RdLearnedClients) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse parses the RESPONSE to RdLearnedClients.
+func (cmd *RdLearnedClients) ParseResponse(p response.Packet) (RdLearnedClientsResponse, error) {
+	return parseOK(cmd.CommandCode, p, ParseRdLearnedClientsResponseOK)
+}
+
+// NewRdLearnedClients constructs RdLearnedClients.
+func NewRdLearnedClients() (RdLearnedClients, error) {
+	return RdLearnedClients{
+		CommandCode: enums.SmartAckCommandWR_RD_LEARNED_CLIENTS,
+	}, nil
+}
+
+// LearnedClient is an entry of the learned clients table.
+type LearnedClient struct {
+	ClientID     deviceid.DeviceID
+	PostmasterID deviceid.DeviceID
+	MailboxIndex uint8
+}
+
+// RdLearnedClientsResponse holds the learned clients table.
+type RdLearnedClientsResponse struct {
+	Clients []LearnedClient
+}
+
+// learnedClientSize is the size of a LearnedClient record.
+const learnedClientSize = 9
+
+// ParseRdLearnedClientsResponseOK parses RdLearnedClientsResponseOK.
+func ParseRdLearnedClientsResponseOK(response response.Packet) (RdLearnedClientsResponse, error) {
+	if response.Code != enums.ReturnCodeSUCCESS {
+		return RdLearnedClientsResponse{}, errors.New("invalid return code")
+	}
+
+	if len(response.Data)%learnedClientSize != 0 {
+		return RdLearnedClientsResponse{}, fmt.Errorf("learned clients length %d is not a multiple of %d", len(response.Data), learnedClientSize)
+	}
+
+	var result RdLearnedClientsResponse
+	if err := serializer.BytesToStruct(response.Data, &result); err != nil {
+		return RdLearnedClientsResponse{}, fmt.Errorf("failed to deserialize response: %w", err)
+	}
+
+	return result, nil
+}
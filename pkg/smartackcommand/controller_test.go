@@ -0,0 +1,136 @@
+package smartackcommand
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// TestWrLearnMode_Serialize verifies WrLearnMode_Serialize behavior.
+func TestWrLearnMode_Serialize(t *testing.T) {
+	cmd, err := NewWrLearnMode(true, enums.SmartAckLearnModeADVANCED, 30000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if telegram.PacketType != enums.PacketTypeSMART_ACK_COMMAND {
+		t.Errorf("packet type = %s", telegram.PacketType)
+	}
+	if want := []byte{0x01, 0x01, 0x01, 0x00, 0x00, 0x75, 0x30}; !bytes.Equal(telegram.Data, want) {
+		t.Errorf("data = % x, want % x", telegram.Data, want)
+	}
+	if telegram.OptData != nil {
+		t.Errorf("optdata = % x", telegram.OptData)
+	}
+
+	if _, err := NewWrLearnMode(true, enums.SmartAckLearnMode(3), 0); err == nil {
+		t.Error("invalid learn mode accepted")
+	}
+}
+
+// TestRdLearnMode_ParseResponse verifies RdLearnMode_ParseResponse behavior.
+func TestRdLearnMode_ParseResponse(t *testing.T) {
+	cmd, _ := NewRdLearnMode()
+	telegram, _ := cmd.Serialize()
+	if !bytes.Equal(telegram.Data, []byte{0x02}) {
+		t.Errorf("data = % x", telegram.Data)
+	}
+
+	result, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{0x01, 0x02}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Enabled || result.Extended != enums.SmartAckLearnModeADVANCED_SELECT_REPEATER {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{0x01, 0x07}}); err == nil {
+		t.Error("invalid learn mode accepted")
+	}
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{0x01}}); err == nil {
+		t.Error("short response accepted")
+	}
+
+	var codeErr *ReturnCodeError
+	_, err = cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeNOT_SUPPORTED})
+	if !errors.As(err, &codeErr) || codeErr.Command != enums.SmartAckCommandRD_LEARN_MODE || codeErr.Code != enums.ReturnCodeNOT_SUPPORTED {
+		t.Errorf("err = %v", err)
+	}
+	if err.Error() != "RD_LEARN_MODE returned NOT_SUPPORTED" {
+		t.Errorf("message = %q", err.Error())
+	}
+}
+
+// TestWrLearnConfirm_Serialize verifies WrLearnConfirm_Serialize behavior.
+func TestWrLearnConfirm_Serialize(t *testing.T) {
+	cmd, err := NewWrLearnConfirm(500, enums.LearnAckConfirmCodeLRN_OUT, 0x01020304, 0x0a0b0c0d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x03, 0x01, 0xf4, 0x20, 0x01, 0x02, 0x03, 0x04, 0x0a, 0x0b, 0x0c, 0x0d}
+	if !bytes.Equal(telegram.Data, want) {
+		t.Errorf("data = % x, want % x", telegram.Data, want)
+	}
+
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS}); err != nil {
+		t.Error(err)
+	}
+	if _, err := NewWrLearnConfirm(0, enums.LearnAckConfirmCode(0x42), 0, 0); err == nil {
+		t.Error("invalid confirm code accepted")
+	}
+}
+
+// TestWrReset_Serialize verifies WrReset_Serialize behavior.
+func TestWrReset_Serialize(t *testing.T) {
+	cmd, _ := NewWrReset(0x0a0b0c0d)
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x05, 0x0a, 0x0b, 0x0c, 0x0d}; !bytes.Equal(telegram.Data, want) {
+		t.Errorf("data = % x, want % x", telegram.Data, want)
+	}
+}
+
+// TestRdLearnedClients_ParseResponse verifies RdLearnedClients_ParseResponse behavior.
+func TestRdLearnedClients_ParseResponse(t *testing.T) {
+	cmd, _ := NewRdLearnedClients()
+	telegram, _ := cmd.Serialize()
+	if !bytes.Equal(telegram.Data, []byte{0x06}) {
+		t.Errorf("data = % x", telegram.Data)
+	}
+
+	result, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: []byte{
+		0x0a, 0x0b, 0x0c, 0x0d, 0x01, 0x02, 0x03, 0x04, 0x00,
+		0x0a, 0x0b, 0x0c, 0x0e, 0x01, 0x02, 0x03, 0x04, 0x01,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LearnedClient{
+		{ClientID: 0x0a0b0c0d, PostmasterID: 0x01020304, MailboxIndex: 0},
+		{ClientID: 0x0a0b0c0e, PostmasterID: 0x01020304, MailboxIndex: 1},
+	}
+	if !reflect.DeepEqual(result.Clients, want) {
+		t.Errorf("clients = %+v", result.Clients)
+	}
+
+	if result, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS}); err != nil || len(result.Clients) != 0 {
+		t.Errorf("empty table = %+v, %v", result, err)
+	}
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS, Data: make([]byte, 10)}); err == nil {
+		t.Error("truncated record accepted")
+	}
+}
@@ -0,0 +1,32 @@
+package smartackcommand
+
+import (
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// WrPostmaster enables the postmaster function with the given number of
+// mailboxes; zero disables it.
+type WrPostmaster struct {
+	CommandCode  enums.SmartAckCommand `enocean-esp3:"data"`
+	MailboxCount uint8                 `enocean-esp3:"data"`
+}
+
+// Serialize encodes WrPostmaster into its wire representation.
+func (cmd *WrPostmaster) Serialize() (esp3.Telegram, error) {
+	return serialize(cmd)
+}
+
+// ParseResponse checks the return code of the RESPONSE to WrPostmaster.
+func (cmd *WrPostmaster) ParseResponse(p response.Packet) (Ack, error) {
+	return ack(cmd.CommandCode, p)
+}
+
+// NewWrPostmaster constructs WrPostmaster.
+func NewWrPostmaster(mailboxCount uint8) (WrPostmaster, error) {
+	return WrPostmaster{
+		CommandCode:  enums.SmartAckCommandWR_WR_POSTMASTER,
+		MailboxCount: mailboxCount,
+	}, nil
+}
@@ -0,0 +1,24 @@
+package smartackcommand
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/response"
+)
+
+// TestWrPostmaster_Serialize verifies WrPostmaster_Serialize behavior.
+func TestWrPostmaster_Serialize(t *testing.T) {
+	cmd, _ := NewWrPostmaster(20)
+	telegram, err := cmd.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if telegram.PacketType != enums.PacketTypeSMART_ACK_COMMAND || !bytes.Equal(telegram.Data, []byte{0x08, 0x14}) {
+		t.Errorf("telegram = %+v", telegram)
+	}
+	if _, err := cmd.ParseResponse(response.Packet{Code: enums.ReturnCodeSUCCESS}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// defaultBufferSize is the buffer size of every stream unless configured.
+const defaultBufferSize = 64
+
+// Stream identifies one of the output streams of Channels.
+type Stream uint8
+
+const (
+	StreamAll Stream = iota
+	StreamESP3
+	StreamERP1
+	StreamResponse
+	StreamEvent
+	StreamSmartAck
+	StreamReMan
+	StreamReManPart
+	StreamGPHeader
+	StreamUnparsed
+	StreamParseError
+	StreamDecoded
+	StreamDeduplicated
+	StreamSubTel
+	StreamReManCommand
+	StreamRadioMessage
+	StreamRadioERP2
+	StreamConfigCommand
+	StreamCommandAccepted
+	StreamRadio802154
+	StreamCommand24
+	StreamERP2
+	streamCount
+)
+
+// String returns the string representation of Stream.
+func (stream Stream) String() string {
+	switch stream {
+	case StreamAll:
+		return "ALL"
+	case StreamESP3:
+		return "ESP3"
+	case StreamERP1:
+		return "ERP1"
+	case StreamResponse:
+		return "RESPONSE"
+	case StreamEvent:
+		return "EVENT"
+	case StreamSmartAck:
+		return "SMART_ACK"
+	case StreamReMan:
+		return "REMAN"
+	case StreamReManPart:
+		return "REMAN_PART"
+	case StreamGPHeader:
+		return "GP_HEADER"
+	case StreamUnparsed:
+		return "UNPARSED"
+	case StreamParseError:
+		return "PARSE_ERROR"
+	case StreamDecoded:
+		return "DECODED"
+	case StreamDeduplicated:
+		return "DEDUPLICATED"
+	case StreamSubTel:
+		return "SUB_TEL"
+	case StreamReManCommand:
+		return "REMAN_COMMAND"
+	case StreamRadioMessage:
+		return "RADIO_MESSAGE"
+	case StreamRadioERP2:
+		return "RADIO_ERP2"
+	case StreamConfigCommand:
+		return "CONFIG_COMMAND"
+	case StreamCommandAccepted:
+		return "COMMAND_ACCEPTED"
+	case StreamRadio802154:
+		return "RADIO_802_15_4"
+	case StreamCommand24:
+		return "COMMAND_2_4"
+	case StreamERP2:
+		return "ERP2"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Overflow selects what a stream does when its buffer is full.
+type Overflow uint8
+
+const (
+	// OverflowDropNewest discards the message being delivered. Slow
+	// consumers never stall the parser.
+	OverflowDropNewest Overflow = iota
+	// OverflowDropOldest evicts the oldest buffered message to make room,
+	// so the buffer always holds the most recent traffic.
+	OverflowDropOldest
+	// OverflowBlock waits until the consumer makes room. The parser, and
+	// every other stream, stalls meanwhile, so the stream must be drained.
+	OverflowBlock
+)
+
+// String returns the string representation of Overflow.
+func (overflow Overflow) String() string {
+	switch overflow {
+	case OverflowDropNewest:
+		return "DROP_NEWEST"
+	case OverflowDropOldest:
+		return "DROP_OLDEST"
+	case OverflowBlock:
+		return "BLOCK"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// StreamConfig sizes the output streams and chooses their overflow policy.
+type StreamConfig struct {
+	// BufferSize applies to every stream not listed in BufferSizes. Zero
+	// selects the default of 64.
+	BufferSize  int
+	BufferSizes map[Stream]int
+
+	// Overflow applies to every stream not listed in Overflows.
+	Overflow  Overflow
+	Overflows map[Stream]Overflow
+}
+
+// bufferSize returns the buffer size of stream.
+func (c StreamConfig) bufferSize(stream Stream) int {
+	if size, ok := c.BufferSizes[stream]; ok {
+		return size
+	}
+	if c.BufferSize > 0 {
+		return c.BufferSize
+	}
+	return defaultBufferSize
+}
+
+// overflow returns the overflow policy of stream.
+func (c StreamConfig) overflow(stream Stream) Overflow {
+	if overflow, ok := c.Overflows[stream]; ok {
+		return overflow
+	}
+	return c.Overflow
+}
+
+// isZero reports whether c leaves every stream at its defaults.
+func (c StreamConfig) isZero() bool {
+	return c.BufferSize == 0 && c.BufferSizes == nil && c.Overflow == OverflowDropNewest && c.Overflows == nil
+}
+
+type dropCounters [streamCount]atomic.Uint64
+
+// Dropped returns how many messages each stream has discarded so far.
+func (c *Channels) Dropped() map[Stream]uint64 {
+	counts := make(map[Stream]uint64, streamCount)
+	for stream := range streamCount {
+		counts[stream] = c.drops[stream].Load()
+	}
+	return counts
+}
+
+// deliver sends v on ch following the overflow policy of stream. It returns
+// false once ctx is cancelled. While the channel set is closing, blocking
+// streams drop instead and ctx is ignored.
+func deliver[T any](ctx context.Context, c *channelSet, stream Stream, ch chan T, v T) bool {
+	overflow := c.overflows[stream]
+	if c.closing {
+		ctx = context.Background()
+		if overflow == OverflowBlock {
+			overflow = OverflowDropNewest
+		}
+	}
+	switch overflow {
+	case OverflowBlock:
+		select {
+		case ch <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	case OverflowDropOldest:
+		return sendDropOldest(ctx, ch, v, &c.drops[stream])
+	default:
+		return send(ctx, ch, v, &c.drops[stream])
+	}
+}
+
+// sendDropOldest delivers v, evicting buffered values until it fits.
+func sendDropOldest[T any](ctx context.Context, ch chan T, v T, dropped *atomic.Uint64) bool {
+	for {
+		select {
+		case ch <- v:
+			return true
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
+		select {
+		case <-ch:
+			dropped.Add(1)
+		default:
+			if cap(ch) == 0 {
+				dropped.Add(1)
+				return true
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+// TestStreamConfigBufferSizes verifies StreamConfigBufferSizes behavior.
+func TestStreamConfigBufferSizes(t *testing.T) {
+	set, _ := newChannelSetFromConfig(StreamConfig{BufferSize: 4, BufferSizes: map[Stream]int{StreamERP1: 256, StreamUnparsed: 0}})
+
+	if got := cap(set.all); got != 4 {
+		t.Fatalf("all cap = %d, want 4", got)
+	}
+	if got := cap(set.erp1); got != 256 {
+		t.Fatalf("erp1 cap = %d, want 256", got)
+	}
+	if got := cap(set.unparsed); got != 0 {
+		t.Fatalf("unparsed cap = %d, want 0", got)
+	}
+
+	set, _ = newChannelSetFromConfig(StreamConfig{})
+	if got := cap(set.event); got != defaultBufferSize {
+		t.Fatalf("default cap = %d, want %d", got, defaultBufferSize)
+	}
+}
+
+// TestDeliverDropNewestCountsDrops verifies DeliverDropNewestCountsDrops behavior.
+func TestDeliverDropNewestCountsDrops(t *testing.T) {
+	set, channels := newChannelSetFromConfig(StreamConfig{BufferSize: 1})
+	for _, msg := range []Message{{Data: "a"}, {Data: "b"}, {Data: "c"}} {
+		if !deliver(context.Background(), set, StreamAll, set.all, msg) {
+			t.Fatal("deliver stopped")
+		}
+	}
+
+	if got := (<-channels.All).Data; got != "a" {
+		t.Fatalf("kept %v, want a", got)
+	}
+	dropped := channels.Dropped()
+	if dropped[StreamAll] != 2 || dropped[StreamERP1] != 0 {
+		t.Fatalf("dropped = %v", dropped)
+	}
+}
+
+// TestDeliverDropOldestKeepsNewest verifies DeliverDropOldestKeepsNewest behavior.
+func TestDeliverDropOldestKeepsNewest(t *testing.T) {
+	set, channels := newChannelSetFromConfig(StreamConfig{BufferSize: 2, Overflows: map[Stream]Overflow{StreamAll: OverflowDropOldest}})
+	for _, msg := range []Message{{Data: "a"}, {Data: "b"}, {Data: "c"}} {
+		deliver(context.Background(), set, StreamAll, set.all, msg)
+	}
+
+	if a, b := (<-channels.All).Data, (<-channels.All).Data; a != "b" || b != "c" {
+		t.Fatalf("kept %v, %v, want b, c", a, b)
+	}
+	if got := channels.Dropped()[StreamAll]; got != 1 {
+		t.Fatalf("dropped = %d, want 1", got)
+	}
+}
+
+// TestDeliverBlockWaitsForConsumer verifies DeliverBlockWaitsForConsumer behavior.
+func TestDeliverBlockWaitsForConsumer(t *testing.T) {
+	set, channels := newChannelSetFromConfig(StreamConfig{BufferSize: 1, Overflow: OverflowBlock})
+	deliver(context.Background(), set, StreamAll, set.all, Message{Data: "a"})
+
+	delivered := make(chan bool, 1)
+	go func() { delivered <- deliver(context.Background(), set, StreamAll, set.all, Message{Data: "b"}) }()
+	select {
+	case <-delivered:
+		t.Fatal("deliver did not block on a full stream")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	<-channels.All
+	if !<-delivered {
+		t.Fatal("deliver reported cancellation")
+	}
+	if got := (<-channels.All).Data; got != "b" {
+		t.Fatalf("got %v, want b", got)
+	}
+	if got := channels.Dropped()[StreamAll]; got != 0 {
+		t.Fatalf("dropped = %d, want 0", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	deliver(ctx, set, StreamAll, set.all, Message{Data: "c"})
+	cancel()
+	if deliver(ctx, set, StreamAll, set.all, Message{Data: "d"}) {
+		t.Fatal("blocked deliver ignored cancellation")
+	}
+}
+
+// TestParserBlockingStreamIsLossless verifies ParserBlockingStreamIsLossless behavior.
+func TestParserBlockingStreamIsLossless(t *testing.T) {
+	device, host := NewLoopback()
+	defer device.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	channels := StartParser(ctx, host, ParserConfig{Streams: StreamConfig{BufferSize: 1, Overflows: map[Stream]Overflow{StreamResponse: OverflowBlock}}})
+
+	const count = 20
+	go func() {
+		for i := range count {
+			frame := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS), byte(i)}, nil).Serialize()
+			if _, err := device.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+
+	for i := range count {
+		select {
+		case p := <-channels.Response:
+			if p.Data[0] != byte(i) {
+				t.Fatalf("response %d carried %d", i, p.Data[0])
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("response %d not delivered", i)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := channels.Dropped()[StreamResponse]; got != 0 {
+		t.Fatalf("dropped = %d, want 0", got)
+	}
+}
+
+// TestStreamString verifies StreamString behavior.
+func TestStreamString(t *testing.T) {
+	if got := StreamReManPart.String(); got != "REMAN_PART" {
+		t.Fatalf("got %q", got)
+	}
+	if got := OverflowDropOldest.String(); got != "DROP_OLDEST" {
+		t.Fatalf("got %q", got)
+	}
+	if got := Stream(200).String(); got != "UNKNOWN" {
+		t.Fatalf("got %q", got)
+	}
+}
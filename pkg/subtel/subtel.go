@@ -6,6 +6,7 @@ import (
 
 	"github.com/edlundin/enocean-esp3/pkg/deviceid"
 	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
 	"github.com/edlundin/enocean-esp3/pkg/esp3"
 )
 
@@ -28,7 +29,8 @@ type Packet struct {
 	UserData      []byte
 }
 
-// NewPacketFromEsp3 parses a subtelegram packet from an ESP3 telegram.
+// NewPacketFromEsp3 parses a subtelegram packet from a RADIO_SUB_TEL
+// telegram, or from a RADIO_ERP1 telegram carrying the subtelegram info.
 func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
 	const minDataLen = 6    // 1 rorg + 4 sender ID + 1 status
 	const minOptDataLen = 9 // 1 subTelNum + 4 destination ID + 1 rssi + 1 security level + 2 timestamp
@@ -45,7 +47,7 @@ func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
 	statusOffset := len(telegram.Data) - 1
 	senderIdOffset := statusOffset - deviceid.DeviceIDSize
 
-	if telegram.PacketType != enums.PacketTypeRADIO_ERP1 {
+	if telegram.PacketType != enums.PacketTypeRADIO_SUB_TEL && telegram.PacketType != enums.PacketTypeRADIO_ERP1 {
 		return Packet{}, errors.New("invalid packet type")
 	}
 
@@ -94,7 +96,8 @@ func NewPacketFromEsp3(telegram esp3.Telegram) (Packet, error) {
 	}, nil
 }
 
-// ToEsp3 converts the packet to an ESP3 telegram.
+// ToEsp3 converts the packet to an ESP3 telegram. It is a RADIO_ERP1
+// telegram, as the transceiver only sends RADIO_SUB_TEL to the host.
 func (p Packet) ToEsp3() esp3.Telegram {
 	senderID := p.SenderID.ToArray()
 	destinationID := p.DestinationID.ToArray()
@@ -108,7 +111,7 @@ func (p Packet) ToEsp3() esp3.Telegram {
 	optData := make([]byte, 0, 3+deviceid.DeviceIDSize)
 	optData = append(optData, p.SubTelNum)
 	optData = append(optData, destinationID[:]...)
-	optData = append(optData, 0xff) // RSSI is unknown when transmitting.
+	optData = append(optData, erp1.RssiUnknown) // RSSI is unknown when transmitting.
 	optData = append(optData, 0x03)
 	optData = append(optData, byte(p.Timestamp>>8), byte(p.Timestamp&0xFF))
 
@@ -123,6 +126,20 @@ func (p Packet) ToEsp3() esp3.Telegram {
 	}
 }
 
+// ERP1 returns the radio telegram without its subtelegram info.
+func (p Packet) ERP1() erp1.Packet {
+	return erp1.Packet{
+		DestinationID: p.DestinationID,
+		Rorg:          p.Rorg,
+		Rssi:          p.Rssi,
+		SecurityLevel: p.SecurityLevel,
+		Status:        p.Status,
+		SubTelNum:     p.SubTelNum,
+		SenderID:      p.SenderID,
+		UserData:      p.UserData,
+	}
+}
+
 // Serialize encodes Packet into its wire representation.
 func (p Packet) Serialize() []byte {
 	return p.ToEsp3().Serialize()
@@ -0,0 +1,366 @@
+package pkg
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/erp2"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"github.com/edlundin/enocean-esp3/pkg/event"
+	"github.com/edlundin/enocean-esp3/pkg/radioerp2"
+)
+
+const (
+	defaultTxDoneTimeout  = time.Second
+	defaultDutyCycleRetry = 10 * time.Second
+)
+
+var ErrTxDoneTimeout = errors.New("no TX done event")
+
+// Priority orders queued telegrams; higher priorities are sent first and
+// equal priorities in submission order.
+type Priority int8
+
+const (
+	PriorityBulk   Priority = -1 // e.g. ReMan transfers
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1 // e.g. actuator commands
+)
+
+// TxOptions tunes a single Transmit call.
+type TxOptions struct {
+	Priority Priority
+
+	// WaitTxDone waits for CO_TX_DONE or CO_TRANSMIT_FAILED after the
+	// RESPONSE. The transceiver only emits them when configured to.
+	WaitTxDone bool
+}
+
+// TxConfig tunes the transmit queue of a session. Zero fields keep their
+// defaults.
+type TxConfig struct {
+	// TxDoneTimeout bounds the wait for CO_TX_DONE / CO_TRANSMIT_FAILED.
+	TxDoneTimeout time.Duration
+
+	// DutyCycleRetry is how long a paused queue waits before asking the
+	// transceiver again when RD_DUTYCYCLE_LIMIT gives no better estimate.
+	DutyCycleRetry time.Duration
+
+	// DutyCycleCheck, if set, reads RD_DUTYCYCLE_LIMIT before transmitting
+	// whenever the last reading is older, pausing once the budget is gone.
+	DutyCycleCheck time.Duration
+}
+
+// TransmitError reports a telegram the transceiver refused or failed to send.
+type TransmitError struct {
+	// Code is the RESPONSE return code when the telegram was refused.
+	Code enums.ReturnCode
+	// Failed is set, with Cause, when CO_TRANSMIT_FAILED was received.
+	Failed bool
+	Cause  enums.TransmitFailedCause
+}
+
+// Error returns the error message.
+func (e *TransmitError) Error() string {
+	if e.Failed {
+		return fmt.Sprintf("transmit failed: %s", e.Cause)
+	}
+	return fmt.Sprintf("transmit refused: %s", e.Code)
+}
+
+type txRequest struct {
+	ctx    context.Context
+	packet erp1.Packet
+	opts   TxOptions
+	seq    uint64
+	result chan error
+}
+
+type txHeap []*txRequest
+
+func (h txHeap) Len() int { return len(h) }
+func (h txHeap) Less(i, j int) bool {
+	if h[i].opts.Priority != h[j].opts.Priority {
+		return h[i].opts.Priority > h[j].opts.Priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h txHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *txHeap) Push(x any)   { *h = append(*h, x.(*txRequest)) }
+func (h *txHeap) Pop() any {
+	old := *h
+	req := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return req
+}
+
+// txQueue serializes radio telegrams and tracks the duty cycle budget.
+type txQueue struct {
+	mu      sync.Mutex
+	pending txHeap
+	seq     uint64
+	wake    chan struct{}
+
+	paused    atomic.Bool
+	released  chan struct{}
+	checkedAt time.Time
+
+	doneMu sync.Mutex
+	txDone chan event.Event
+
+	checkMu     sync.Mutex
+	senderCheck func(erp1.Packet) error
+}
+
+// newTxQueue constructs an empty txQueue.
+func newTxQueue() *txQueue {
+	return &txQueue{wake: make(chan struct{}, 1), released: make(chan struct{}, 1)}
+}
+
+// Transmit queues p for transmission and waits until the transceiver has
+// accepted it, or, with WaitTxDone, until it reports the transmission.
+// Cancelling ctx withdraws a telegram that has not been sent yet.
+func (s *Session) Transmit(ctx context.Context, p erp1.Packet, opts ...TxOptions) error {
+	var o TxOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if err := s.tx.checkSender(p); err != nil {
+		return err
+	}
+
+	req := &txRequest{ctx: ctx, packet: p, opts: o, result: make(chan error, 1)}
+	s.tx.push(req)
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrSessionClosed
+	}
+}
+
+// DutyCyclePaused reports whether the transmit queue is holding telegrams
+// back because the duty cycle budget is exhausted.
+func (s *Session) DutyCyclePaused() bool {
+	return s.tx.paused.Load()
+}
+
+// setSenderCheck installs the sender ID validation applied by Transmit.
+func (q *txQueue) setSenderCheck(check func(erp1.Packet) error) {
+	q.checkMu.Lock()
+	defer q.checkMu.Unlock()
+	q.senderCheck = check
+}
+
+// checkSender validates the sender ID of p, if a check is installed.
+func (q *txQueue) checkSender(p erp1.Packet) error {
+	q.checkMu.Lock()
+	check := q.senderCheck
+	q.checkMu.Unlock()
+	if check == nil {
+		return nil
+	}
+	return check(p)
+}
+
+// push queues req and wakes the worker.
+func (q *txQueue) push(req *txRequest) {
+	q.mu.Lock()
+	q.seq++
+	req.seq = q.seq
+	heap.Push(&q.pending, req)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// wait blocks until a request is queued. It returns false once ctx is
+// cancelled.
+func (q *txQueue) wait(ctx context.Context) bool {
+	for {
+		q.mu.Lock()
+		queued := q.pending.Len() > 0
+		q.mu.Unlock()
+		if queued {
+			return true
+		}
+
+		select {
+		case <-q.wake:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// pop removes the most urgent request.
+func (q *txQueue) pop() *txRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(&q.pending).(*txRequest)
+}
+
+// runTx sends queued telegrams one at a time until ctx is cancelled. The
+// duty cycle is checked once a telegram is waiting, so that the most urgent
+// one is picked when the budget returns.
+func (s *Session) runTx(ctx context.Context) {
+	for s.tx.wait(ctx) && s.awaitDutyCycle(ctx) {
+		req := s.tx.pop()
+		if req == nil {
+			continue
+		}
+		if err := req.ctx.Err(); err != nil {
+			req.result <- err
+			continue
+		}
+		req.result <- s.transmit(req)
+	}
+}
+
+// transmit sends one telegram and waits for its outcome.
+func (s *Session) transmit(req *txRequest) error {
+	var txDone chan event.Event
+	if req.opts.WaitTxDone {
+		txDone = make(chan event.Event, 1)
+		s.tx.doneMu.Lock()
+		s.tx.txDone = txDone
+		s.tx.doneMu.Unlock()
+		defer func() {
+			s.tx.doneMu.Lock()
+			s.tx.txDone = nil
+			s.tx.doneMu.Unlock()
+		}()
+	}
+
+	telegram, err := s.radioTelegram(req.packet)
+	if err != nil {
+		return err
+	}
+	p, err := s.Send(req.ctx, telegram)
+	if err != nil {
+		return err
+	}
+	if p.Code != enums.ReturnCodeSUCCESS {
+		return &TransmitError{Code: p.Code}
+	}
+	if txDone == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(s.cfg.Tx.TxDoneTimeout)
+	defer timer.Stop()
+
+	select {
+	case e := <-txDone:
+		if failed, ok := e.(event.COTransmitFailed); ok {
+			return &TransmitError{Code: enums.ReturnCodeSUCCESS, Failed: true, Cause: failed.Cause}
+		}
+		return nil
+	case <-timer.C:
+		return ErrTxDoneTimeout
+	case <-req.ctx.Done():
+		return req.ctx.Err()
+	case <-s.done:
+		return ErrSessionClosed
+	}
+}
+
+// radioTelegram encodes p for the radio mode of the transceiver.
+func (s *Session) radioTelegram(p erp1.Packet) (esp3.Telegram, error) {
+	if !s.erp2.Load() {
+		return p.ToEsp3(), nil
+	}
+	telegram, err := erp2.FromERP1(p)
+	if err != nil {
+		return esp3.Telegram{}, err
+	}
+	data, err := telegram.Encode()
+	if err != nil {
+		return esp3.Telegram{}, err
+	}
+	return radioerp2.Packet{Data: data, SubTelNum: p.SubTelNum, Dbm: 0xff}.ToEsp3(), nil
+}
+
+// awaitDutyCycle blocks while the duty cycle budget is exhausted. It returns
+// false once ctx is cancelled.
+func (s *Session) awaitDutyCycle(ctx context.Context) bool {
+	if check := s.cfg.Tx.DutyCycleCheck; check > 0 && !s.tx.paused.Load() && time.Since(s.tx.checkedAt) >= check {
+		if limit, err := s.readDutyCycle(ctx); err == nil && limit.AvailableDutyCycle == 0 {
+			s.tx.paused.Store(true)
+		}
+	}
+
+	for s.tx.paused.Load() {
+		wait := s.cfg.Tx.DutyCycleRetry
+		if limit, err := s.readDutyCycle(ctx); err == nil {
+			if limit.AvailableDutyCycle > 0 {
+				s.tx.paused.Store(false)
+				break
+			}
+			if left := time.Duration(limit.TimeLeftInCurrentSlot) * time.Second; left > 0 {
+				wait = left
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-s.tx.released:
+			timer.Stop()
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
+
+// readDutyCycle reads the transceiver's duty cycle budget.
+func (s *Session) readDutyCycle(ctx context.Context) (commoncommand.RdDutyCycleLimitResponse, error) {
+	cmd, _ := commoncommand.NewRdDutyCycleLimit()
+	limit, err := Exec(ctx, s, &cmd)
+	if err == nil {
+		s.tx.checkedAt = time.Now()
+	}
+	return limit, err
+}
+
+// onEvent updates the transmit queue from transceiver events.
+func (q *txQueue) onEvent(e event.Event) {
+	switch e := e.(type) {
+	case event.CODutyCycleLimit:
+		if e.Cause == enums.DutyCycleLimitCauseREACHED {
+			q.paused.Store(true)
+			return
+		}
+		q.paused.Store(false)
+		select {
+		case q.released <- struct{}{}:
+		default:
+		}
+	case event.COTxDone, event.COTransmitFailed:
+		q.doneMu.Lock()
+		defer q.doneMu.Unlock()
+		if q.txDone != nil {
+			q.txDone <- e
+			q.txDone = nil
+		}
+	}
+}
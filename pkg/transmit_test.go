@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+)
+
+const (
+	framePacketTypeOffset = 4
+	frameDataOffset       = 6
+	rockerSenderLSBOffset = frameDataOffset + 5
+)
+
+// sendEvent queues an EVENT telegram on port.
+func (p *scriptedPort) sendEvent(code enums.EventCode, data ...byte) {
+	p.in <- esp3.NewTelegramFromData(enums.PacketTypeEVENT, append([]byte{byte(code)}, data...), nil).Serialize()
+}
+
+// transmitAsync runs Transmit in a goroutine and returns its result channel.
+func transmitAsync(ctx context.Context, s *Session, p erp1.Packet, opts ...TxOptions) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- s.Transmit(ctx, p, opts...) }()
+	return result
+}
+
+// waitQueued waits until n telegrams are queued behind the one in flight.
+func waitQueued(t *testing.T, s *Session, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.tx.mu.Lock()
+		queued := s.tx.pending.Len()
+		s.tx.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("%d telegrams never queued", n)
+}
+
+// TestTransmitWritesPacket verifies TransmitWritesPacket behavior.
+func TestTransmitWritesPacket(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	p := rockerPacket(0xff800001, true)
+	result := transmitAsync(context.Background(), s, p)
+	if frame := port.respond(enums.ReturnCodeSUCCESS); !reflect.DeepEqual(frame, p.Serialize()) {
+		t.Fatalf("written = %x, want %x", frame, p.Serialize())
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	result = transmitAsync(context.Background(), s, p)
+	port.respond(enums.ReturnCodeOPERATION_DENIED)
+	var txErr *TransmitError
+	if err := <-result; !errors.As(err, &txErr) || txErr.Failed || txErr.Code != enums.ReturnCodeOPERATION_DENIED {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+// TestTransmitWaitsForTxDone verifies TransmitWaitsForTxDone behavior.
+func TestTransmitWaitsForTxDone(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port, SessionConfig{Tx: TxConfig{TxDoneTimeout: 50 * time.Millisecond}})
+	defer s.Close()
+	opts := TxOptions{WaitTxDone: true}
+
+	result := transmitAsync(context.Background(), s, rockerPacket(1, true), opts)
+	port.respond(enums.ReturnCodeSUCCESS)
+	select {
+	case err := <-result:
+		t.Fatalf("returned %v before TX done", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	port.sendEvent(enums.EventCodeCO_TX_DONE)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	result = transmitAsync(context.Background(), s, rockerPacket(1, true), opts)
+	port.respond(enums.ReturnCodeSUCCESS)
+	port.sendEvent(enums.EventCodeCO_TRANSMIT_FAILED, byte(enums.TransmitFailedCauseNO_ACK_RECEIVED))
+	var txErr *TransmitError
+	if err := <-result; !errors.As(err, &txErr) || !txErr.Failed || txErr.Cause != enums.TransmitFailedCauseNO_ACK_RECEIVED {
+		t.Fatalf("err = %v", err)
+	}
+
+	result = transmitAsync(context.Background(), s, rockerPacket(1, true), opts)
+	port.respond(enums.ReturnCodeSUCCESS)
+	if err := <-result; !errors.Is(err, ErrTxDoneTimeout) {
+		t.Fatalf("err = %v, want ErrTxDoneTimeout", err)
+	}
+}
+
+// TestTransmitOrdersByPriority verifies TransmitOrdersByPriority behavior.
+func TestTransmitOrdersByPriority(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	first := transmitAsync(context.Background(), s, rockerPacket(1, true))
+	inFlight := <-port.written
+
+	results := []<-chan error{
+		transmitAsync(context.Background(), s, rockerPacket(2, true), TxOptions{Priority: PriorityBulk}),
+	}
+	waitQueued(t, s, 1)
+	results = append(results, transmitAsync(context.Background(), s, rockerPacket(3, true)))
+	waitQueued(t, s, 2)
+	results = append(results, transmitAsync(context.Background(), s, rockerPacket(4, true), TxOptions{Priority: PriorityHigh}))
+	waitQueued(t, s, 3)
+	results = append(results, transmitAsync(context.Background(), s, rockerPacket(5, true)))
+	waitQueued(t, s, 4)
+
+	if inFlight[rockerSenderLSBOffset] != 0x01 {
+		t.Fatalf("first frame = %x", inFlight)
+	}
+	port.in <- esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, []byte{byte(enums.ReturnCodeSUCCESS)}, nil).Serialize()
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+
+	var order []byte
+	for range results {
+		frame := port.respond(enums.ReturnCodeSUCCESS)
+		order = append(order, frame[rockerSenderLSBOffset])
+	}
+	if want := []byte{4, 3, 5, 2}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("sender order = %v, want %v", order, want)
+	}
+	for _, result := range results {
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestTransmitHonoursContext verifies TransmitHonoursContext behavior.
+func TestTransmitHonoursContext(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port)
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Transmit(ctx, rockerPacket(1, true)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	select {
+	case frame := <-port.written:
+		t.Fatalf("cancelled telegram written: %x", frame)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+// TestTransmitPausesOnDutyCycleEvent verifies TransmitPausesOnDutyCycleEvent behavior.
+func TestTransmitPausesOnDutyCycleEvent(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port, SessionConfig{Tx: TxConfig{DutyCycleRetry: time.Hour}})
+	defer s.Close()
+
+	port.sendEvent(enums.EventCodeCO_DUTYCYCLE_LIMIT, byte(enums.DutyCycleLimitCauseREACHED))
+	deadline := time.Now().Add(time.Second)
+	for !s.DutyCyclePaused() {
+		if time.Now().After(deadline) {
+			t.Fatal("queue did not pause")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	result := transmitAsync(context.Background(), s, rockerPacket(1, true))
+	probe := port.respond(enums.ReturnCodeSUCCESS, 0, 10, 0, 60, 0, 0, 0)
+	if probe[framePacketTypeOffset] != byte(enums.PacketTypeCOMMON_COMMAND) || probe[frameDataOffset] != byte(enums.CommonCommandRD_DUTYCYCLE_LIMIT) {
+		t.Fatalf("probe = %x", probe)
+	}
+	select {
+	case frame := <-port.written:
+		t.Fatalf("telegram written while paused: %x", frame)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	port.sendEvent(enums.EventCodeCO_DUTYCYCLE_LIMIT, byte(enums.DutyCycleLimitCauseNOT_YET_REACHED))
+	if frame := port.respond(enums.ReturnCodeSUCCESS); frame[framePacketTypeOffset] != byte(enums.PacketTypeRADIO_ERP1) {
+		t.Fatalf("frame = %x", frame)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if s.DutyCyclePaused() {
+		t.Fatal("queue still paused")
+	}
+}
+
+// TestTransmitChecksDutyCycleBudget verifies TransmitChecksDutyCycleBudget behavior.
+func TestTransmitChecksDutyCycleBudget(t *testing.T) {
+	port := newScriptedPort()
+	s := NewSession(context.Background(), port, SessionConfig{Tx: TxConfig{DutyCycleCheck: time.Hour, DutyCycleRetry: time.Millisecond}})
+	defer s.Close()
+
+	result := transmitAsync(context.Background(), s, rockerPacket(1, true))
+	for _, available := range []byte{0, 0, 5} {
+		probe := port.respond(enums.ReturnCodeSUCCESS, available, 10, 0, 60, 0, 0, 0)
+		if probe[frameDataOffset] != byte(enums.CommonCommandRD_DUTYCYCLE_LIMIT) {
+			t.Fatalf("probe = %x", probe)
+		}
+	}
+	if frame := port.respond(enums.ReturnCodeSUCCESS); frame[framePacketTypeOffset] != byte(enums.PacketTypeRADIO_ERP1) {
+		t.Fatalf("frame = %x", frame)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	result = transmitAsync(context.Background(), s, rockerPacket(1, true))
+	if frame := port.respond(enums.ReturnCodeSUCCESS); frame[framePacketTypeOffset] != byte(enums.PacketTypeRADIO_ERP1) {
+		t.Fatalf("budget re-read within the check interval: %x", frame)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
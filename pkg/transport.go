@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// readTimeout bounds every read so the parser notices cancellation.
+const readTimeout = 2 * time.Second
+
+// Transport carries ESP3 bytes between the host and a transceiver. Serial
+// ports, TCP bridges such as ser2net and in-memory pipes all qualify.
+type Transport interface {
+	io.ReadWriteCloser
+}
+
+// Deadliner is implemented by transports whose reads block until data arrives,
+// such as net.Conn. The parser sets a read deadline before every read.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// Flusher is implemented by transports that can wait until written bytes have
+// left the host. Sessions flush after every telegram.
+type Flusher interface {
+	Flush() error
+}
+
+type serialTransport struct {
+	serial.Port
+}
+
+// Flush waits for pending serial writes to complete.
+func (t serialTransport) Flush() error {
+	return t.Drain()
+}
+
+// OpenSerialTransport opens a serial port with the ESP3 line settings.
+func OpenSerialTransport(portPath string) (Transport, error) {
+	port, err := openSerial(portPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return serialTransport{port}, nil
+}
+
+// DialTCP connects to an ESP3-over-TCP bridge such as ser2net in raw mode.
+func DialTCP(ctx context.Context, address string) (Transport, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", address)
+}
+
+// NewLoopback returns the two ends of an in-memory transport. Bytes written
+// to one end are read from the other.
+func NewLoopback() (Transport, Transport) {
+	return net.Pipe()
+}
+
+// StartParser starts ESP3 parsing on r and returns its output streams. The
+// streams are closed once ctx is cancelled or r fails. An optional
+// ParserConfig replaces the default buffering, logger and hooks.
+func StartParser(ctx context.Context, r io.Reader, cfg ...ParserConfig) *Channels {
+	var c ParserConfig
+	if len(cfg) > 0 {
+		c = cfg[0]
+	}
+	set, channels := newChannelSetFromConfig(c.Streams)
+	set.observe(c.Logger, c.Hooks)
+	go parser(ctx, r, set)
+	return channels
+}
+
+// readChunk reads from r, treating an expired read deadline as an empty read.
+func readChunk(r io.Reader, buf []byte) (int, error) {
+	if d, ok := r.(Deadliner); ok {
+		if err := d.SetReadDeadline(time.Now().Add(readTimeout)); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.Read(buf)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, nil
+	}
+	return n, err
+}
@@ -0,0 +1,215 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/commoncommand"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/esp3"
+	"go.bug.st/serial"
+)
+
+type deadlineReader struct {
+	deadline time.Time
+	err      error
+}
+
+// SetReadDeadline updates ReadDeadline.
+func (r *deadlineReader) SetReadDeadline(t time.Time) error {
+	r.deadline = t
+	return nil
+}
+
+// Read reads the value.
+func (r *deadlineReader) Read([]byte) (int, error) { return 0, r.err }
+
+// answerNext reads one frame from device and answers it with a SUCCESS response.
+func answerNext(device Transport, data ...byte) (esp3.Telegram, error) {
+	buf := make([]byte, 256)
+	n, err := device.Read(buf)
+	if err != nil {
+		return esp3.Telegram{}, err
+	}
+	frame := buf[:n]
+	resp := esp3.NewTelegramFromData(enums.PacketTypeRESPONSE, append([]byte{byte(enums.ReturnCodeSUCCESS)}, data...), nil)
+	if _, err := device.Write(resp.Serialize()); err != nil {
+		return esp3.Telegram{}, err
+	}
+	return esp3.NewEsp3TelegramFromHexString(hex.EncodeToString(frame))
+}
+
+// TestSessionOverLoopback verifies SessionOverLoopback behavior.
+func TestSessionOverLoopback(t *testing.T) {
+	host, device := NewLoopback()
+	s := NewSession(context.Background(), host)
+	defer s.Close()
+
+	frames := make(chan esp3.Telegram, 1)
+	errs := make(chan error, 1)
+	go func() {
+		frame, err := answerNext(device, byte(enums.RepeaterModeOFF), byte(enums.RepeaterLevelNO_REPETITION))
+		frames <- frame
+		errs <- err
+	}()
+
+	cmd, _ := commoncommand.NewRdRepeater()
+	got, err := Exec(context.Background(), s, &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.RepeaterMode != enums.RepeaterModeOFF {
+		t.Fatalf("response = %+v", got)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	want, _ := cmd.Serialize()
+	if frame := <-frames; !reflect.DeepEqual(frame.Data, want.Data) {
+		t.Fatalf("device received %+v, want %+v", frame, want)
+	}
+}
+
+// TestSessionOverTCP verifies SessionOverTCP behavior.
+func TestSessionOverTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("tcp listener unavailable: %v", err)
+	}
+	defer ln.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer conn.Close()
+		_, err = answerNext(conn)
+		errs <- err
+	}()
+
+	transport, err := DialTCP(context.Background(), ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(context.Background(), transport)
+	defer s.Close()
+
+	cmd, _ := commoncommand.NewWrReset()
+	if _, err := Exec(context.Background(), s, &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+type bufferedTransport struct {
+	Transport
+	w       *bufio.Writer
+	flushed int
+}
+
+// Write writes the value.
+func (t *bufferedTransport) Write(b []byte) (int, error) { return t.w.Write(b) }
+
+// Flush flushes buffered writes.
+func (t *bufferedTransport) Flush() error {
+	t.flushed++
+	return t.w.Flush()
+}
+
+// TestSessionFlushesAfterWrite verifies SessionFlushesAfterWrite behavior.
+func TestSessionFlushesAfterWrite(t *testing.T) {
+	host, device := NewLoopback()
+	buffered := &bufferedTransport{Transport: host, w: bufio.NewWriterSize(host, 4096)}
+	s := NewSession(context.Background(), buffered)
+	defer s.Close()
+
+	go func() { _, _ = answerNext(device) }()
+
+	cmd, _ := commoncommand.NewWrReset()
+	if _, err := Exec(context.Background(), s, &cmd); err != nil {
+		t.Fatal(err)
+	}
+	if buffered.flushed != 1 {
+		t.Fatalf("flushed %d times, want 1", buffered.flushed)
+	}
+}
+
+// TestStartParserOverLoopback verifies StartParserOverLoopback behavior.
+func TestStartParserOverLoopback(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	host, device := NewLoopback()
+	defer host.Close()
+	channels := StartParser(ctx, host)
+
+	want := esp3.NewTelegramFromData(enums.PacketTypeEVENT, []byte{byte(enums.EventCodeCO_TX_DONE)}, []byte{})
+	go func() { _, _ = device.Write(want.Serialize()) }()
+
+	select {
+	case got := <-channels.ESP3:
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("telegram mismatch: got %+v want %+v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for telegram")
+	}
+
+	_ = device.Close()
+	select {
+	case _, ok := <-channels.All:
+		for ok {
+			_, ok = <-channels.All
+		}
+	case <-time.After(time.Second):
+		t.Fatal("parser did not stop after transport closed")
+	}
+}
+
+// TestReadChunkTreatsDeadlineAsEmptyRead verifies ReadChunkTreatsDeadlineAsEmptyRead behavior.
+func TestReadChunkTreatsDeadlineAsEmptyRead(t *testing.T) {
+	r := &deadlineReader{err: os.ErrDeadlineExceeded}
+	n, err := readChunk(r, make([]byte, 1))
+	if n != 0 || err != nil {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if r.deadline.IsZero() {
+		t.Fatal("read deadline was not set")
+	}
+
+	r.err = net.ErrClosed
+	if _, err := readChunk(r, make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("err = %v, want net.ErrClosed", err)
+	}
+}
+
+// TestOpenSerialTransportFlushDrains verifies OpenSerialTransportFlushDrains behavior.
+func TestOpenSerialTransportFlushDrains(t *testing.T) {
+	port := &fakePort{}
+	oldOpen := serialOpen
+	serialOpen = func(string, *serial.Mode) (serial.Port, error) { return port, nil }
+	t.Cleanup(func() { serialOpen = oldOpen })
+
+	transport, err := OpenSerialTransport("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := transport.(Flusher)
+	if !ok {
+		t.Fatal("serial transport does not implement Flusher")
+	}
+	if err := f.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
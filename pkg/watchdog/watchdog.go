@@ -0,0 +1,329 @@
+package watchdog
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+)
+
+const (
+	defaultMissedCycles = 3
+	defaultInterval     = time.Second
+	defaultBufferSize   = 16
+)
+
+// AnyType in a ProfileCycles key matches every TYPE of the RORG and FUNC.
+const AnyType = 0xff
+
+// DefaultProfileCycles holds the heartbeat of common self-powered sensors,
+// which send at least every 1000 seconds even when nothing changes.
+var DefaultProfileCycles = map[eep.EEP]time.Duration{
+	{Rorg: enums.Rorg4BS, Func: 0x02, Type: AnyType}: 1000 * time.Second,
+	{Rorg: enums.Rorg4BS, Func: 0x04, Type: AnyType}: 1000 * time.Second,
+	{Rorg: enums.Rorg1BS, Func: 0x00, Type: 0x01}:    1000 * time.Second,
+}
+
+// EventKind tells whether a device went offline or came back.
+type EventKind uint8
+
+const (
+	DeviceOffline EventKind = iota
+	DeviceBackOnline
+)
+
+// String returns the string representation of EventKind.
+func (kind EventKind) String() string {
+	switch kind {
+	case DeviceOffline:
+		return "OFFLINE"
+	case DeviceBackOnline:
+		return "BACK_ONLINE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// Event reports a liveness change of a device.
+//
+// Device holds the registry entry, or only the ID of devices watched without
+// one. LastSeen is zero when nothing was received since the watchdog started.
+type Event struct {
+	Kind     EventKind
+	Device   registry.Device
+	LastSeen time.Time
+	Cycle    time.Duration
+}
+
+// Clock tells the time. Tests substitute a fake one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Config tunes a Watchdog. Zero fields keep their defaults.
+type Config struct {
+	// Devices, if set, watches every registered device whose cycle is
+	// known, from Device.Cycle or else ProfileCycles. It may be updated
+	// while the watchdog runs.
+	Devices *registry.Registry
+
+	// Cycles sets the expected cycle of individual devices, overriding
+	// the registry.
+	Cycles map[deviceid.DeviceID]time.Duration
+
+	// ProfileCycles sets the expected cycle per EEP; use AnyType for a
+	// whole FUNC. Defaults to DefaultProfileCycles.
+	ProfileCycles map[eep.EEP]time.Duration
+
+	// MissedCycles is how many cycles may pass without a telegram before
+	// a device is reported offline. Defaults to 3.
+	MissedCycles int
+
+	// Interval is how often devices are checked. Defaults to 1s.
+	Interval time.Duration
+
+	// Clock defaults to the system clock.
+	Clock Clock
+
+	// BufferSize sizes Events. Defaults to 16.
+	BufferSize int
+}
+
+type deviceState struct {
+	lastSeen time.Time
+	offline  bool
+}
+
+// Watchdog reports devices that stop sending, and devices that resume.
+// Feed it every received packet with Observe and start it with Run.
+type Watchdog struct {
+	// Events receives liveness changes. It is closed when Run returns.
+	Events <-chan Event
+
+	events        chan Event
+	devices       *registry.Registry
+	profileCycles map[eep.EEP]time.Duration
+	missed        int
+	interval      time.Duration
+	clock         Clock
+	started       time.Time
+	wake          chan struct{}
+
+	mu     sync.Mutex
+	cycles map[deviceid.DeviceID]time.Duration
+	states map[deviceid.DeviceID]*deviceState
+}
+
+// New constructs a Watchdog. Devices count as last seen when it is created.
+func New(cfg ...Config) *Watchdog {
+	c := Config{ProfileCycles: DefaultProfileCycles, MissedCycles: defaultMissedCycles, Interval: defaultInterval, Clock: systemClock{}, BufferSize: defaultBufferSize}
+	for _, o := range cfg {
+		if o.Devices != nil {
+			c.Devices = o.Devices
+		}
+		if o.Cycles != nil {
+			c.Cycles = o.Cycles
+		}
+		if o.ProfileCycles != nil {
+			c.ProfileCycles = o.ProfileCycles
+		}
+		if o.MissedCycles > 0 {
+			c.MissedCycles = o.MissedCycles
+		}
+		if o.Interval > 0 {
+			c.Interval = o.Interval
+		}
+		if o.Clock != nil {
+			c.Clock = o.Clock
+		}
+		if o.BufferSize > 0 {
+			c.BufferSize = o.BufferSize
+		}
+	}
+
+	events := make(chan Event, c.BufferSize)
+	return &Watchdog{
+		Events:        events,
+		events:        events,
+		devices:       c.Devices,
+		profileCycles: maps.Clone(c.ProfileCycles),
+		missed:        c.MissedCycles,
+		interval:      c.Interval,
+		clock:         c.Clock,
+		started:       c.Clock.Now(),
+		wake:          make(chan struct{}, 1),
+		cycles:        maps.Clone(c.Cycles),
+		states:        map[deviceid.DeviceID]*deviceState{},
+	}
+}
+
+// Watch sets the expected cycle of id. A zero cycle stops watching id
+// unless the registry knows its cycle.
+func (w *Watchdog) Watch(id deviceid.DeviceID, cycle time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cycles == nil {
+		w.cycles = map[deviceid.DeviceID]time.Duration{}
+	}
+	if cycle <= 0 {
+		delete(w.cycles, id)
+		return
+	}
+	w.cycles[id] = cycle
+}
+
+// Observe records a telegram from p's sender. It never blocks, so it can
+// be registered as a Dispatcher handler.
+func (w *Watchdog) Observe(p erp1.Packet) {
+	w.Seen(p.SenderID)
+}
+
+// Seen records a telegram from id.
+func (w *Watchdog) Seen(id deviceid.DeviceID) {
+	now := w.clock.Now()
+
+	w.mu.Lock()
+	st, ok := w.states[id]
+	if !ok {
+		st = &deviceState{}
+		w.states[id] = st
+	}
+	st.lastSeen = now
+	offline := st.offline
+	w.mu.Unlock()
+
+	if offline {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Offline returns the devices currently reported offline, sorted by ID.
+func (w *Watchdog) Offline() []deviceid.DeviceID {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ids []deviceid.DeviceID
+	for id, st := range w.states {
+		if st.offline {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Run checks the watched devices every interval until ctx is cancelled,
+// then closes Events. A device that resumes is reported without waiting
+// for the next check.
+func (w *Watchdog) Run(ctx context.Context) {
+	defer close(w.events)
+	for {
+		for _, e := range w.check(w.clock.Now()) {
+			select {
+			case w.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.clock.After(w.interval):
+		case <-w.wake:
+		}
+	}
+}
+
+// check updates every watched device at now and returns the changes.
+func (w *Watchdog) check(now time.Time) []Event {
+	watched := w.watched()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []Event
+	for _, d := range watched {
+		st, ok := w.states[d.device.ID]
+		if !ok {
+			st = &deviceState{}
+			w.states[d.device.ID] = st
+		}
+
+		since := st.lastSeen
+		if since.IsZero() {
+			since = w.started
+		}
+		silent := now.Sub(since) >= time.Duration(w.missed)*d.cycle
+
+		switch {
+		case !st.offline && silent:
+			st.offline = true
+			events = append(events, Event{Kind: DeviceOffline, Device: d.device, LastSeen: st.lastSeen, Cycle: d.cycle})
+		case st.offline && !silent:
+			st.offline = false
+			events = append(events, Event{Kind: DeviceBackOnline, Device: d.device, LastSeen: st.lastSeen, Cycle: d.cycle})
+		}
+	}
+	return events
+}
+
+type watchedDevice struct {
+	device registry.Device
+	cycle  time.Duration
+}
+
+// watched returns every device with a known cycle, sorted by ID.
+func (w *Watchdog) watched() []watchedDevice {
+	devices := map[deviceid.DeviceID]watchedDevice{}
+	if w.devices != nil {
+		for _, d := range w.devices.Devices() {
+			if cycle := w.profileCycle(d); cycle > 0 {
+				devices[d.ID] = watchedDevice{device: d, cycle: cycle}
+			}
+		}
+	}
+
+	w.mu.Lock()
+	for id, cycle := range w.cycles {
+		d, ok := devices[id]
+		if !ok && w.devices != nil {
+			d.device, _ = w.devices.Lookup(id)
+		}
+		d.device.ID = id
+		d.cycle = cycle
+		devices[id] = d
+	}
+	w.mu.Unlock()
+
+	sorted := slices.Collect(maps.Values(devices))
+	slices.SortFunc(sorted, func(a, b watchedDevice) int { return cmp.Compare(a.device.ID, b.device.ID) })
+	return sorted
+}
+
+// profileCycle returns the cycle of a registered device.
+func (w *Watchdog) profileCycle(d registry.Device) time.Duration {
+	if d.Cycle > 0 {
+		return d.Cycle
+	}
+	if cycle, ok := w.profileCycles[d.EEP]; ok {
+		return cycle
+	}
+	return w.profileCycles[eep.EEP{Rorg: d.EEP.Rorg, Func: d.EEP.Func, Type: AnyType}]
+}
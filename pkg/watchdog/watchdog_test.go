@@ -0,0 +1,130 @@
+package watchdog
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edlundin/enocean-esp3/pkg/deviceid"
+	"github.com/edlundin/enocean-esp3/pkg/eep"
+	"github.com/edlundin/enocean-esp3/pkg/enums"
+	"github.com/edlundin/enocean-esp3/pkg/erp1"
+	"github.com/edlundin/enocean-esp3/pkg/registry"
+)
+
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []chan time.Time
+}
+
+// Now returns the fake time.
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel fired by the next Advance.
+func (c *fakeClock) After(time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, ch)
+	return ch
+}
+
+// Advance moves the fake time forward and fires pending After channels.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	for _, ch := range c.waiters {
+		ch <- c.now
+	}
+	c.waiters = nil
+}
+
+// nextEvent returns the next event or fails the test.
+func nextEvent(t *testing.T, w *Watchdog) Event {
+	t.Helper()
+	select {
+	case e := <-w.Events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return Event{}
+	}
+}
+
+// TestWatchdogCheckResolvesCycles verifies WatchdogCheckResolvesCycles behavior.
+func TestWatchdogCheckResolvesCycles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	temperature, _ := eep.FromString("A5-02-05")
+	rocker, _ := eep.FromString("F6-01-01")
+	devices := registry.New(
+		registry.Device{ID: 1, EEP: temperature, Name: "Office"},
+		registry.Device{ID: 2, EEP: rocker},
+		registry.Device{ID: 3, EEP: rocker, Cycle: time.Minute},
+	)
+	clock := &fakeClock{now: start}
+	w := New(Config{Devices: devices, Cycles: map[deviceid.DeviceID]time.Duration{4: 10 * time.Minute}, Clock: clock})
+
+	if events := w.check(start.Add(2 * time.Minute)); len(events) != 0 {
+		t.Fatalf("early events = %+v", events)
+	}
+
+	clock.Advance(3 * time.Minute)
+	w.Observe(erp1.Packet{SenderID: 3, Rorg: enums.RorgRPS})
+	w.Observe(erp1.Packet{SenderID: 2, Rorg: enums.RorgRPS})
+
+	var offline []deviceid.DeviceID
+	for _, e := range w.check(start.Add(50 * time.Minute)) {
+		if e.Kind != DeviceOffline {
+			t.Fatalf("event = %+v", e)
+		}
+		offline = append(offline, e.Device.ID)
+	}
+	if !reflect.DeepEqual(offline, []deviceid.DeviceID{1, 3, 4}) {
+		t.Fatalf("offline = %v, want [1 3 4]", offline)
+	}
+	if got := w.Offline(); !reflect.DeepEqual(got, offline) {
+		t.Fatalf("Offline() = %v", got)
+	}
+	if events := w.check(start.Add(50 * time.Minute)); len(events) != 0 {
+		t.Fatalf("repeated events = %+v", events)
+	}
+}
+
+// TestWatchdogRunReportsOfflineAndBackOnline verifies WatchdogRunReportsOfflineAndBackOnline behavior.
+func TestWatchdogRunReportsOfflineAndBackOnline(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	w := New(Config{Cycles: map[deviceid.DeviceID]time.Duration{7: time.Minute}, MissedCycles: 2, Clock: clock})
+	ctx, cancel := context.WithCancel(context.Background())
+	go w.Run(ctx)
+
+	w.Seen(7)
+	seen := clock.Now()
+	clock.Advance(time.Minute)
+	clock.Advance(time.Minute)
+
+	e := nextEvent(t, w)
+	if e.Kind != DeviceOffline || e.Device.ID != 7 || !e.LastSeen.Equal(seen) || e.Cycle != time.Minute {
+		t.Fatalf("event = %+v", e)
+	}
+
+	w.Seen(7)
+	if e := nextEvent(t, w); e.Kind != DeviceBackOnline || !e.LastSeen.Equal(clock.Now()) {
+		t.Fatalf("event = %+v", e)
+	}
+	if len(w.Offline()) != 0 {
+		t.Fatalf("still offline: %v", w.Offline())
+	}
+
+	cancel()
+	if _, ok := <-w.Events; ok {
+		t.Fatal("Events not closed")
+	}
+}